@@ -0,0 +1,69 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+var (
+	checkCycles         = expvar.NewInt("check_cycles")
+	feedsChecked        = expvar.NewInt("feeds_checked")
+	lastCheckDuration   = expvar.NewFloat("last_check_seconds")
+	lastCheckFinishedAt = expvar.NewString("last_check_finished_at")
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+}
+
+func recordCheckCycle(sites int, elapsed time.Duration) {
+	checkCycles.Add(1)
+	feedsChecked.Add(int64(sites))
+	lastCheckDuration.Set(elapsed.Seconds())
+	lastCheckFinishedAt.Set(time.Now().UTC().Format(time.RFC3339))
+}
+
+// The admin endpoints expose internals, so they may only listen on loopback.
+func validateAdminAddr(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid admin address '%s': %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("admin address must be on localhost, got '%s'", addr)
+}
+
+func adminRoutes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+func startAdminServer(addr string) {
+	fmt.Printf("Admin endpoints on http://%s/debug/pprof/ and /debug/vars\n", addr)
+	go func() {
+		server := &http.Server{
+			Addr:              addr,
+			Handler:           adminRoutes(),
+			ReadHeaderTimeout: TLS_READ_HEADER_TIMEOUT,
+		}
+		if err := server.ListenAndServe(); err != nil {
+			fmt.Printf("Admin server → ERROR: %v\n", err)
+		}
+	}()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// siteForAlias finds the site that has alias, ignoring case.
+func siteForAlias(sites SiteData, alias string) (string, bool) {
+	for name, site := range sites {
+		if containsFold(site.Aliases, alias) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func runAlias(sites SiteData, args []string) error {
+	fs := flag.NewFlagSet("alias", flag.ExitOnError)
+	remove := fs.Bool("remove", false, "Remove the given aliases.")
+	rest := parseInterspersed(fs, args)
+
+	if *remove {
+		if len(rest) == 0 {
+			return fmt.Errorf("usage: alias -remove <alias>...")
+		}
+		return removeAliases(sites, rest)
+	}
+
+	switch len(rest) {
+	case 0:
+		listAliases(sites)
+		return nil
+	case 1:
+		return fmt.Errorf("usage: alias <site> <alias>... | alias -remove <alias>...")
+	}
+
+	name, err := resolveSiteName(sites, rest[0], bufio.NewReader(os.Stdin))
+	if err != nil {
+		return err
+	}
+
+	site := sites[name]
+	for _, alias := range rest[1:] {
+		alias = strings.TrimSpace(alias)
+		if alias == "" {
+			continue
+		}
+		if _, exists := sites[alias]; exists && alias != name {
+			return fmt.Errorf("'%s' is already a site name", alias)
+		}
+		if owner, exists := siteForAlias(sites, alias); exists {
+			if owner == name {
+				continue
+			}
+			return fmt.Errorf("'%s' is already an alias of '%s'", alias, owner)
+		}
+		site.Aliases = append(site.Aliases, alias)
+	}
+	sites[name] = site
+
+	if err := saveSites(sites); err != nil {
+		return fmt.Errorf("saving sites: %w", err)
+	}
+
+	fmt.Printf("✓ %s → %s\n", name, strings.Join(site.Aliases, ", "))
+	return nil
+}
+
+func removeAliases(sites SiteData, aliases []string) error {
+	for _, alias := range aliases {
+		name, exists := siteForAlias(sites, alias)
+		if !exists {
+			return fmt.Errorf("no site has the alias '%s'", alias)
+		}
+
+		site := sites[name]
+		kept := site.Aliases[:0]
+		for _, existing := range site.Aliases {
+			if !strings.EqualFold(existing, alias) {
+				kept = append(kept, existing)
+			}
+		}
+		site.Aliases = kept
+		if len(site.Aliases) == 0 {
+			site.Aliases = nil
+		}
+		sites[name] = site
+		fmt.Printf("✓ Removed alias '%s' from '%s'\n", alias, name)
+	}
+
+	if err := saveSites(sites); err != nil {
+		return fmt.Errorf("saving sites: %w", err)
+	}
+	return nil
+}
+
+func listAliases(sites SiteData) {
+	var names []string
+	for name, site := range sites {
+		if len(site.Aliases) > 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		fmt.Println("No aliases defined")
+		return
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s → %s\n", strings.Join(sites[name].Aliases, ", "), name)
+	}
+}
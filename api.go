@@ -0,0 +1,403 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	API_DEFAULT_LIMIT = 50
+	API_MAX_LIMIT     = 500
+)
+
+type addSiteRequest struct {
+	URL  string   `json:"url"`
+	Name string   `json:"name,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+func parseAddSiteRequest(r *http.Request) (addSiteRequest, error) {
+	var req addSiteRequest
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return req, fmt.Errorf("invalid JSON: %w", err)
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return req, fmt.Errorf("invalid form: %w", err)
+		}
+		req.URL = r.Form.Get("url")
+		req.Name = r.Form.Get("name")
+		req.Tags = parseTags(r.Form.Get("tags"))
+	}
+
+	if strings.TrimSpace(req.URL) == "" {
+		return req, fmt.Errorf("missing url")
+	}
+	return req, nil
+}
+
+func (s *Server) handleSites(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleListSites(w, r)
+	case http.MethodPost:
+		s.handleAddSite(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *Server) handleAddSite(w http.ResponseWriter, r *http.Request) {
+	req, err := parseAddSiteRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	feed, err := discoverFeed(req.URL)
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		return
+	}
+
+	name, created, err := s.addDiscoveredSite(feed, req.Name, req.Tags)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !created {
+		writeJSON(w, http.StatusOK, map[string]any{"name": name, "url": feed.URL, "created": false})
+		return
+	}
+
+	fmt.Printf("✓ Added '%s' via API (%s)\n", name, feed.URL)
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"name":    name,
+		"url":     feed.URL,
+		"type":    feedTypeString(feed.FeedType),
+		"created": true,
+	})
+}
+
+// subscribedAs returns the name of the site already following feedURL.
+func (s *Server) subscribedAs(feedURL string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for name, site := range s.sites.Snapshot() {
+		if site.RSSUrl == feedURL {
+			return name
+		}
+	}
+	return ""
+}
+
+// addDiscoveredSite subscribes to a feed unless a site already follows it, in
+// which case that site's name is returned with created false.
+func (s *Server) addDiscoveredSite(feed DiscoveredFeed, name string, tags []string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for existing, site := range s.sites.Snapshot() {
+		if site.RSSUrl == feed.URL {
+			return existing, false, nil
+		}
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = feed.Title
+	}
+	if name == "" {
+		if u, err := url.Parse(feed.URL); err == nil {
+			name = u.Host
+		}
+	}
+	if _, taken := s.sites.Get(name); taken {
+		name = fmt.Sprintf("%s (%s)", name, feed.URL)
+	}
+
+	site := Site{RSSUrl: feed.URL, Tags: tags}
+	site.Tags = append(site.Tags, s.config.autoTags(site, nil)...)
+	s.sites.Put(name, site)
+	if err := s.store.SaveSites(s.sites.Snapshot()); err != nil {
+		s.sites.Delete(name)
+		return "", false, err
+	}
+	return name, true, nil
+}
+
+// apiPage holds the paging and sorting parameters shared by the list
+// endpoints: limit, offset, sort and order.
+type apiPage struct {
+	Limit  int
+	Offset int
+	Sort   string
+	Desc   bool
+}
+
+func parseAPIPage(query url.Values, sorts []string, defaultSort string, defaultDesc bool) (apiPage, error) {
+	page := apiPage{Limit: API_DEFAULT_LIMIT, Sort: defaultSort, Desc: defaultDesc}
+
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return page, fmt.Errorf("invalid limit '%s'", value)
+		}
+		page.Limit = min(n, API_MAX_LIMIT)
+	}
+	if value := query.Get("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return page, fmt.Errorf("invalid offset '%s'", value)
+		}
+		page.Offset = n
+	}
+	if value := query.Get("sort"); value != "" {
+		if !containsFold(sorts, value) {
+			return page, fmt.Errorf("invalid sort '%s' (use %s)", value, strings.Join(sorts, ", "))
+		}
+		page.Sort = strings.ToLower(value)
+	}
+	switch strings.ToLower(query.Get("order")) {
+	case "":
+	case "asc":
+		page.Desc = false
+	case "desc":
+		page.Desc = true
+	default:
+		return page, fmt.Errorf("invalid order '%s' (use asc or desc)", query.Get("order"))
+	}
+	return page, nil
+}
+
+// bounds returns the slice range of a page over total items.
+func (p apiPage) bounds(total int) (int, int) {
+	start := min(p.Offset, total)
+	return start, min(start+p.Limit, total)
+}
+
+func (p apiPage) response(key string, total int, items any) map[string]any {
+	response := map[string]any{
+		"total":  total,
+		"offset": p.Offset,
+		"limit":  p.Limit,
+		key:      items,
+	}
+	if p.Offset+p.Limit < total {
+		response["next_offset"] = p.Offset + p.Limit
+	}
+	return response
+}
+
+// parseAPISince accepts an RFC 3339 time or a duration back from now ("36h", "7d").
+func parseAPISince(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	d, err := parseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since '%s' (use RFC 3339 or a duration such as 7d)", value)
+	}
+	return time.Now().Add(-d), nil
+}
+
+func parseAPIBool(query url.Values, key string) (bool, bool, error) {
+	value := query.Get(key)
+	if value == "" {
+		return false, false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, false, fmt.Errorf("invalid %s '%s' (use true or false)", key, value)
+	}
+	return b, true, nil
+}
+
+type apiSite struct {
+	Name          string     `json:"name"`
+	URL           string     `json:"url"`
+	SiteURL       string     `json:"site_url,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+	Muted         bool       `json:"muted,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorKind string     `json:"last_error_kind,omitempty"`
+	LastPublished *time.Time `json:"last_published,omitempty"`
+	Unread        int        `json:"unread"`
+	Health        int        `json:"health"`
+}
+
+// handleListSites serves GET /api/sites. Filters: tag, failing; sorts: name,
+// unread, last_published, health.
+func (s *Server) handleListSites(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, err := parseAPIPage(query, []string{"name", "unread", "last_published", "health"}, "name", false)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	failing, filterFailing, err := parseAPIBool(query, "failing")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	tag := query.Get("tag")
+
+	unread := make(map[string]int)
+	for _, entry := range s.entries.list(func(e Entry) bool { return !e.Read }) {
+		unread[entry.Site]++
+	}
+
+	s.mu.RLock()
+	var sites []apiSite
+	for name, site := range s.sites.Snapshot() {
+		if tag != "" && !containsFold(site.Tags, tag) {
+			continue
+		}
+		if filterFailing && (site.LastError != "") != failing {
+			continue
+		}
+		sites = append(sites, apiSite{
+			Name:          name,
+			URL:           s.feedURL(site),
+			SiteURL:       site.SiteURL,
+			Tags:          site.Tags,
+			Muted:         site.Muted,
+			LastError:     site.LastError,
+			LastErrorKind: site.LastErrorKind,
+			LastPublished: site.LastPublished,
+			Unread:        unread[name],
+			Health:        computeHealth(name, site, s.config).Score,
+		})
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(sites, func(i, j int) bool {
+		a, b := sites[i], sites[j]
+		if page.Desc {
+			a, b = b, a
+		}
+		switch page.Sort {
+		case "unread":
+			if a.Unread != b.Unread {
+				return a.Unread < b.Unread
+			}
+		case "health":
+			if a.Health != b.Health {
+				return a.Health < b.Health
+			}
+		case "last_published":
+			at, bt := time.Time{}, time.Time{}
+			if a.LastPublished != nil {
+				at = *a.LastPublished
+			}
+			if b.LastPublished != nil {
+				bt = *b.LastPublished
+			}
+			if !at.Equal(bt) {
+				return at.Before(bt)
+			}
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+
+	start, end := page.bounds(len(sites))
+	writeJSON(w, http.StatusOK, page.response("sites", len(sites), append([]apiSite{}, sites[start:end]...)))
+}
+
+// handleListEntries serves GET /api/entries. Filters: site, tag, unread,
+// starred, since; sorts: discovered, published, site, title.
+func (s *Server) handleListEntries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	query := r.URL.Query()
+	page, err := parseAPIPage(query, []string{"discovered", "published", "site", "title"}, "discovered", true)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	unread, filterUnread, err := parseAPIBool(query, "unread")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	starred, filterStarred, err := parseAPIBool(query, "starred")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	var since time.Time
+	if value := query.Get("since"); value != "" {
+		if since, err = parseAPISince(value); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	siteName, tag := query.Get("site"), query.Get("tag")
+
+	var tagged map[string]bool
+	if tag != "" {
+		tagged = make(map[string]bool)
+		s.mu.RLock()
+		for name, site := range s.sites.Snapshot() {
+			if containsFold(site.Tags, tag) {
+				tagged[name] = true
+			}
+		}
+		s.mu.RUnlock()
+	}
+
+	entries := s.entries.list(func(e Entry) bool {
+		switch {
+		case siteName != "" && e.Site != siteName,
+			tagged != nil && !tagged[e.Site],
+			filterUnread && e.Read == unread,
+			filterStarred && e.Saved != starred,
+			!since.IsZero() && e.Discovered.Before(since):
+			return false
+		}
+		return true
+	})
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if page.Desc {
+			a, b = b, a
+		}
+		switch page.Sort {
+		case "published":
+			if !a.Published.Equal(b.Published) {
+				return a.Published.Before(b.Published)
+			}
+		case "site":
+			if a.Site != b.Site {
+				return strings.ToLower(a.Site) < strings.ToLower(b.Site)
+			}
+		case "title":
+			if a.Title != b.Title {
+				return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+			}
+		default:
+			if !a.Discovered.Equal(b.Discovered) {
+				return a.Discovered.Before(b.Discovered)
+			}
+		}
+		return a.ID < b.ID
+	})
+
+	start, end := page.bounds(len(entries))
+	writeJSON(w, http.StatusOK, page.response("entries", len(entries), append([]Entry{}, entries[start:end]...)))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const ARXIV_RSS_URL = "https://rss.arxiv.org/rss/"
+
+var arxivCategoryPattern = regexp.MustCompile(`^[a-z-]+(\.[A-Za-z-]+)?$`)
+
+func addArxivSite(sites SiteData, config Config, args []string) error {
+	fs := flag.NewFlagSet("add-arxiv", flag.ExitOnError)
+	keywords := fs.String("keywords", "", "Comma separated keywords; only matching papers are reported.")
+	exclude := fs.String("exclude", "", "Comma separated keywords; matching papers are skipped.")
+	name := fs.String("name", "", "Site name (default: \"arXiv <categories>\").")
+	tags := fs.String("tags", "", "Comma separated tags.")
+	categories := parseInterspersed(fs, args)
+
+	if len(categories) == 0 {
+		return fmt.Errorf("usage: add-arxiv <category> [category...] [--keywords k1,k2] [--exclude k3] [--name NAME]")
+	}
+	for _, category := range categories {
+		if !arxivCategoryPattern.MatchString(category) {
+			return fmt.Errorf("invalid arXiv category '%s' (expected e.g. cs.CR)", category)
+		}
+	}
+
+	siteName := *name
+	if siteName == "" {
+		siteName = "arXiv " + strings.Join(categories, "+")
+		if *keywords != "" {
+			siteName += " (" + *keywords + ")"
+		}
+	}
+	if _, exists := sites[siteName]; exists {
+		return fmt.Errorf("site '%s' already exists", siteName)
+	}
+
+	site := Site{
+		RSSUrl: ARXIV_RSS_URL + strings.Join(categories, "+"),
+		Tags:   parseTags(*tags),
+	}
+	if *keywords != "" || *exclude != "" {
+		site.Filter = &EntryFilter{
+			Keywords: parseTags(*keywords),
+			Exclude:  parseTags(*exclude),
+		}
+	}
+
+	site.Tags = append(site.Tags, config.autoTags(site, nil)...)
+	sites[siteName] = site
+	if err := saveSites(sites); err != nil {
+		return fmt.Errorf("saving site: %w", err)
+	}
+
+	fmt.Printf("✓ Successfully added '%s' (%s)\n", siteName, site.RSSUrl)
+	if site.Filter != nil {
+		fmt.Printf("  keywords: %s\n", strings.Join(site.Filter.Keywords, ", "))
+		if len(site.Filter.Exclude) > 0 {
+			fmt.Printf("  exclude: %s\n", strings.Join(site.Filter.Exclude, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OIDC_KEYS_TTL = time.Hour
+	// Tokens signed with a key that isn't known refetch the keys at most
+	// this often, so they can't make every request go to the provider.
+	OIDC_KEYS_REFETCH_INTERVAL = time.Minute
+)
+
+type ServeAuthConfig struct {
+	Username string      `json:"username,omitempty"`
+	Password string      `json:"password,omitempty"`
+	Tokens   []string    `json:"tokens,omitempty"`
+	OIDC     *OIDCConfig `json:"oidc,omitempty"`
+}
+
+type OIDCConfig struct {
+	Issuer   string `json:"issuer"`
+	ClientID string `json:"client_id"`
+}
+
+type oidcVerifier struct {
+	mu        sync.Mutex
+	config    OIDCConfig
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+	// triedAt is when the keys were last fetched, or tried to be.
+	triedAt time.Time
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt int64           `json:"exp"`
+	NotBefore int64           `json:"nbf"`
+}
+
+func (c *ServeAuthConfig) enabled() bool {
+	return c != nil && ((c.Username != "" && c.Password != "") || len(c.Tokens) > 0 || c.OIDC != nil)
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func (s *Server) authenticate(r *http.Request) bool {
+	auth := s.config.ServeAuth
+
+	var bearer string
+	if value := r.Header.Get("Authorization"); strings.HasPrefix(value, "Bearer ") {
+		bearer = strings.TrimPrefix(value, "Bearer ")
+	} else if value := r.URL.Query().Get("token"); value != "" {
+		bearer = value
+	}
+
+	if bearer != "" {
+		if s.config.APIToken != "" && secureEqual(bearer, s.config.APIToken) {
+			return true
+		}
+		if auth != nil {
+			for _, token := range auth.Tokens {
+				if token != "" && secureEqual(bearer, token) {
+					return true
+				}
+			}
+			if s.oidc != nil && strings.Count(bearer, ".") == 2 && s.oidc.verify(bearer) == nil {
+				return true
+			}
+		}
+	}
+
+	if username, password, ok := r.BasicAuth(); ok && auth != nil && auth.Username != "" && auth.Password != "" {
+		userOK := secureEqual(username, auth.Username)
+		passOK := secureEqual(password, auth.Password)
+		return userOK && passOK
+	}
+
+	return false
+}
+
+func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.config.ServeAuth.enabled() && s.config.APIToken == "" {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "authentication is not configured (set serve_auth or api_token)"})
+			return
+		}
+		if !s.authenticate(r) {
+			if s.config.ServeAuth != nil && s.config.ServeAuth.Username != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="RSS Tracker"`)
+			}
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing credentials"})
+			return
+		}
+		if !s.forgeryProof(r) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "cross-origin requests are not allowed; send JSON with Content-Type: application/json"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// forgeryProof tells whether a request can't have been forged by another
+// site. Browsers attach basic auth credentials to any site's requests, and a
+// page can post a form or a text/plain body anywhere without asking first.
+// So a request that changes something on basic auth must come from an
+// allowed origin and be JSON, which no page can send cross-site without CORS
+// letting it. A form is only taken from serve's own pages, whose browser
+// says so in Origin. Tokens aren't sent by the browser by itself, so
+// requests carrying one, like the bookmarklet's, are left alone.
+func (s *Server) forgeryProof(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	if _, _, ok := r.BasicAuth(); !ok {
+		return true
+	}
+	if !originAllowed(r, s.config.CORSOrigins) {
+		return false
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mediaType == "application/json" {
+		return true
+	}
+	origin, err := url.Parse(r.Header.Get("Origin"))
+	return err == nil && origin.Host != "" && strings.EqualFold(origin.Host, r.Host)
+}
+
+func newOIDCVerifier(config *OIDCConfig) (*oidcVerifier, error) {
+	if config == nil {
+		return nil, nil
+	}
+	if config.Issuer == "" || config.ClientID == "" {
+		return nil, fmt.Errorf("oidc requires issuer and client_id")
+	}
+	return &oidcVerifier{config: *config}, nil
+}
+
+// fetchKeys fetches the provider's signing keys. It doesn't touch v's keys,
+// so it is called without holding the lock.
+func (v *oidcVerifier) fetchKeys() (map[string]*rsa.PublicKey, error) {
+	client := &http.Client{Timeout: httpTimeout}
+
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	discoveryURL := strings.TrimSuffix(v.config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(client, discoveryURL, &discovery); err != nil {
+		return nil, fmt.Errorf("OIDC discovery: %w", err)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := getJSON(client, discovery.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("OIDC keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, key := range jwks.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(key.N)
+		e, errE := base64.RawURLEncoding.DecodeString(key.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	return keys, nil
+}
+
+// key returns the signing key kid, fetching the keys again when they are
+// out of date or kid isn't among them, though no more often than
+// OIDC_KEYS_REFETCH_INTERVAL. Until then the keys already known are used.
+func (v *oidcVerifier) key(kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	key, ok := v.keys[kid]
+	if (ok && time.Since(v.fetchedAt) < OIDC_KEYS_TTL) || time.Since(v.triedAt) < OIDC_KEYS_REFETCH_INTERVAL {
+		v.mu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key '%s'", kid)
+		}
+		return key, nil
+	}
+	v.triedAt = time.Now()
+	v.mu.Unlock()
+
+	keys, err := v.fetchKeys()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err == nil {
+		v.keys, v.fetchedAt = keys, time.Now()
+	}
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("unknown signing key '%s'", kid)
+}
+
+func (v *oidcVerifier) verify(token string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return err
+	}
+	if header.Alg != "RS256" {
+		return fmt.Errorf("unsupported algorithm '%s'", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("malformed signature")
+	}
+
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return fmt.Errorf("invalid signature")
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	switch {
+	case strings.TrimSuffix(claims.Issuer, "/") != strings.TrimSuffix(v.config.Issuer, "/"):
+		return fmt.Errorf("unexpected issuer '%s'", claims.Issuer)
+	case !claims.hasAudience(v.config.ClientID):
+		return fmt.Errorf("token not issued for '%s'", v.config.ClientID)
+	case claims.ExpiresAt == 0 || now >= claims.ExpiresAt:
+		return fmt.Errorf("token expired")
+	case claims.NotBefore != 0 && now < claims.NotBefore:
+		return fmt.Errorf("token not yet valid")
+	}
+
+	return nil
+}
+
+func (c jwtClaims) hasAudience(clientID string) bool {
+	var single string
+	if err := json.Unmarshal(c.Audience, &single); err == nil {
+		return single == clientID
+	}
+
+	var list []string
+	if err := json.Unmarshal(c.Audience, &list); err == nil {
+		for _, aud := range list {
+			if aud == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("malformed token")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("malformed token: %w", err)
+	}
+	return nil
+}
+
+func getJSON(client *http.Client, target string, v any) error {
+	resp, err := client.Get(target)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
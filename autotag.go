@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// TagRule tags sites automatically when all of its criteria hold. Domain
+// matches the host of the feed or site URL: ".edu" matches any host ending
+// in it, "example.com" the host and its subdomains. Keywords match the title
+// or content of any of the site's entries.
+type TagRule struct {
+	Tag      string   `json:"tag"`
+	Domain   []string `json:"domain,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+func (r TagRule) validate() error {
+	if strings.TrimSpace(r.Tag) == "" {
+		return fmt.Errorf("tag rule has no tag")
+	}
+	if len(r.Domain)+len(r.Keywords) == 0 {
+		return fmt.Errorf("tag rule '%s' has no domain or keywords", r.Tag)
+	}
+	return nil
+}
+
+func matchesDomain(rawURL string, domains []string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		switch {
+		case domain == "":
+		case strings.HasPrefix(domain, "."):
+			if strings.HasSuffix(host, domain) {
+				return true
+			}
+		case host == domain || strings.HasSuffix(host, "."+domain):
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the rule applies to a site whose entries read
+// texts.
+func (r TagRule) matches(site Site, feedURL string, texts []string) bool {
+	if len(r.Domain) > 0 && !matchesDomain(feedURL, r.Domain) && !matchesDomain(site.SiteURL, r.Domain) {
+		return false
+	}
+	if len(r.Keywords) > 0 {
+		found := false
+		for _, text := range texts {
+			if containsAnyFold(text, r.Keywords) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// autoTags returns the tags the tag rules give a site that it doesn't have
+// yet.
+func (c Config) autoTags(site Site, texts []string) []string {
+	feedURL, err := resolveFeedURL(site, c)
+	if err != nil {
+		feedURL = site.RSSUrl
+	}
+
+	var tags []string
+	for _, rule := range c.TagRules {
+		tag := strings.TrimSpace(rule.Tag)
+		if containsFold(site.Tags, tag) || containsFold(tags, tag) || !rule.matches(site, feedURL, texts) {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+func feedEntryTexts(entries []FeedEntry) []string {
+	texts := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		texts = append(texts, entry.Title+"\n"+htmlToText(entry.Content))
+	}
+	return texts
+}
+
+// runRetag applies the tag rules to sites already tracked, matching keywords
+// against their stored entries. Tags are only ever added.
+func runRetag(sites SiteData, config Config, args []string) error {
+	fs := flag.NewFlagSet("retag", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Show the tags that would be added without saving them.")
+	rest := parseInterspersed(fs, args)
+
+	if len(config.TagRules) == 0 {
+		return fmt.Errorf("no tag rules (add tag_rules to config.json)")
+	}
+
+	var names []string
+	if len(rest) == 0 {
+		for name := range sites {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	} else {
+		reader := bufio.NewReader(os.Stdin)
+		for _, arg := range rest {
+			name, err := resolveSiteName(sites, arg, reader)
+			if err != nil {
+				return err
+			}
+			names = append(names, name)
+		}
+	}
+
+	entries, err := readEntries()
+	if err != nil {
+		return err
+	}
+	texts := make(map[string][]string)
+	for _, entry := range entries.list(func(Entry) bool { return true }) {
+		texts[entry.Site] = append(texts[entry.Site], entry.Title+"\n"+htmlToText(entry.Content))
+	}
+
+	tagged := 0
+	for _, name := range names {
+		site := sites[name]
+		tags := config.autoTags(site, texts[name])
+		if len(tags) == 0 {
+			continue
+		}
+		fmt.Printf("→ %s: +%s\n", name, strings.Join(tags, ", +"))
+		site.Tags = append(site.Tags, tags...)
+		sites[name] = site
+		tagged++
+	}
+
+	switch {
+	case tagged == 0:
+		fmt.Println("No site needs new tags")
+		return nil
+	case *dryRun:
+		fmt.Printf("Would tag %d sites\n", tagged)
+		return nil
+	}
+
+	if err := saveSites(sites); err != nil {
+		return fmt.Errorf("saving sites: %w", err)
+	}
+	fmt.Printf("✓ Tagged %d sites\n", tagged)
+	return nil
+}
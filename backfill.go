@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	BACKFILL_NONE = "none"
+	BACKFILL_ALL  = "all"
+)
+
+// backfillCount parses a backfill policy: "none" (or empty) records existing
+// entries silently, "all" reports every entry, and a number N reports the
+// newest N. It returns -1 for "all".
+func backfillCount(policy string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(policy)) {
+	case "", BACKFILL_NONE:
+		return 0, nil
+	case BACKFILL_ALL:
+		return -1, nil
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(policy))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("backfill must be \"none\", \"all\" or a number of entries, got '%s'", policy)
+	}
+	return n, nil
+}
+
+// backfillPolicy returns the policy for a site's first check; the site's own
+// setting takes precedence over the config.
+func (c Config) backfillPolicy(site Site) string {
+	if site.Backfill != "" {
+		return site.Backfill
+	}
+	return c.Backfill
+}
+
+// backfillEntries picks which entries recorded on a site's first check are
+// reported as new, and marks them unread again.
+func backfillEntries(recorded []Entry, policy string, entries *EntryStore) ([]Entry, error) {
+	count, err := backfillCount(policy)
+	if err != nil || count == 0 || len(recorded) == 0 {
+		return nil, err
+	}
+
+	reported := recorded
+	if count > 0 && len(recorded) > count {
+		reported = recorded[len(recorded)-count:]
+	}
+
+	ids := make(map[int64]bool, len(reported))
+	for i := range reported {
+		reported[i].Read, reported[i].Backfill = false, false
+		ids[reported[i].ID] = true
+	}
+	entries.update(func(e Entry) bool { return ids[e.ID] }, func(e *Entry) { e.Read, e.Backfill = false, false })
+
+	return reported, nil
+}
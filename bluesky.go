@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	BLUESKY_PREFIX      = "bsky:"
+	BLUESKY_PROFILE_URL = "https://bsky.app/profile/"
+)
+
+var blueskyActorPattern = regexp.MustCompile(`^(did:plc:[a-z0-9]+|[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)+)$`)
+
+func blueskyFeedURL(input string) (string, bool) {
+	input = strings.TrimSpace(input)
+
+	var actor string
+	switch {
+	case strings.HasPrefix(input, BLUESKY_PREFIX):
+		actor = strings.TrimPrefix(strings.TrimPrefix(input, BLUESKY_PREFIX), "@")
+	case strings.HasPrefix(input, BLUESKY_PROFILE_URL):
+		actor = strings.TrimPrefix(input, BLUESKY_PROFILE_URL)
+		actor = strings.TrimSuffix(strings.TrimSuffix(actor, "/rss"), "/")
+		if strings.Contains(actor, "/") {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+
+	if !blueskyActorPattern.MatchString(actor) {
+		return "", false
+	}
+	return fmt.Sprintf("%s%s/rss", BLUESKY_PROFILE_URL, strings.ToLower(actor)), true
+}
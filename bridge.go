@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const BRIDGE_PREFIX = "bridge:"
+
+type BridgeSource struct {
+	Instance string            `json:"instance,omitempty"`
+	Name     string            `json:"bridge"`
+	Params   map[string]string `json:"params,omitempty"`
+}
+
+func (b *BridgeSource) feedURL(defaultInstance string) (string, error) {
+	instance := b.Instance
+	if instance == "" {
+		instance = defaultInstance
+	}
+	if instance == "" {
+		return "", fmt.Errorf("no RSS-Bridge instance configured for bridge '%s'", b.Name)
+	}
+
+	u, err := url.Parse(instance)
+	if err != nil {
+		return "", fmt.Errorf("invalid RSS-Bridge instance URL: %w", err)
+	}
+
+	query := u.Query()
+	for key, value := range b.Params {
+		query.Set(key, value)
+	}
+	query.Set("action", "display")
+	query.Set("bridge", b.Name)
+	query.Set("format", "Atom")
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+func parseBridgeSpec(spec string) (*BridgeSource, error) {
+	fields := strings.Fields(strings.TrimPrefix(spec, BRIDGE_PREFIX))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("missing bridge name (expected 'bridge:<Name> key=value ...')")
+	}
+
+	bridge := &BridgeSource{Name: fields[0], Params: make(map[string]string)}
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid bridge parameter '%s' (expected key=value)", field)
+		}
+		bridge.Params[key] = value
+	}
+
+	return bridge, nil
+}
+
+func resolveFeedURL(site Site, config Config) (string, error) {
+	if site.Bridge != nil {
+		return site.Bridge.feedURL(config.RSSBridgeURL)
+	}
+	if len(site.Command) > 0 {
+		return commandFeedURL(site.Command), nil
+	}
+	return site.RSSUrl, nil
+}
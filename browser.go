@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_BROWSER_WAIT    = 5 * time.Second
+	BROWSER_URL_PLACEHOLDER = "{url}"
+)
+
+// BrowserConfig sets up fetching through a headless browser, for sites with
+// "browser": true whose feeds sit behind JavaScript challenges. With
+// RenderURL, an external rendering service is asked for the page instead of
+// a local Chromium.
+type BrowserConfig struct {
+	Command   string   `json:"command,omitempty"`
+	Args      []string `json:"args,omitempty"`
+	Wait      string   `json:"wait,omitempty"`
+	RenderURL string   `json:"render_url,omitempty"`
+}
+
+var (
+	browserConfig   BrowserConfig
+	browserWait     = DEFAULT_BROWSER_WAIT
+	browserCommands = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable"}
+)
+
+func applyBrowserConfig(config *BrowserConfig) error {
+	if config == nil {
+		return nil
+	}
+	if config.Wait != "" {
+		wait, err := parseDuration(config.Wait)
+		if err != nil {
+			return fmt.Errorf("config browser.wait: %w", err)
+		}
+		browserWait = wait
+	}
+	if config.RenderURL != "" && !strings.Contains(config.RenderURL, BROWSER_URL_PLACEHOLDER) {
+		return fmt.Errorf("config browser.render_url must contain %s", BROWSER_URL_PLACEHOLDER)
+	}
+	browserConfig = *config
+	return nil
+}
+
+// browserCommand is the command line that loads a page in headless Chromium
+// and prints the resulting document.
+func browserCommand(pageURL string) ([]string, error) {
+	command := browserConfig.Command
+	if command == "" {
+		for _, candidate := range browserCommands {
+			if _, err := exec.LookPath(candidate); err == nil {
+				command = candidate
+				break
+			}
+		}
+		if command == "" {
+			return nil, fmt.Errorf("no Chromium found (set browser.command or browser.render_url)")
+		}
+	}
+
+	args := []string{
+		command,
+		"--headless",
+		"--disable-gpu",
+		fmt.Sprintf("--virtual-time-budget=%d", browserWait.Milliseconds()),
+	}
+	args = append(args, browserConfig.Args...)
+	return append(args, "--dump-dom", pageURL), nil
+}
+
+func renderPage(pageURL string, timeout time.Duration) ([]byte, error) {
+	if browserConfig.RenderURL == "" {
+		command, err := browserCommand(pageURL)
+		if err != nil {
+			return nil, err
+		}
+		return runFeedCommand(command, timeout)
+	}
+
+	endpoint := strings.ReplaceAll(browserConfig.RenderURL, BROWSER_URL_PLACEHOLDER, url.QueryEscape(pageURL))
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("rendering service: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("rendering service: HTTP status: %s", resp.Status)
+	}
+	return readFeedBody(resp.Body)
+}
+
+// extractRenderedFeed cuts the feed out of a rendered document, dropping
+// whatever markup the browser put around it.
+func extractRenderedFeed(body []byte) []byte {
+	content := string(body)
+	for _, root := range escapedFeedRoots {
+		start := -1
+		for offset := 0; offset < len(content); {
+			i := strings.Index(content[offset:], "<"+root)
+			if i < 0 {
+				break
+			}
+			i += offset
+			if next := i + len(root) + 1; next < len(content) && strings.ContainsRune(" \t\r\n>", rune(content[next])) {
+				start = i
+				break
+			}
+			offset = i + 1
+		}
+		if start < 0 {
+			continue
+		}
+
+		closing := "</" + root + ">"
+		end := strings.LastIndex(content, closing)
+		if end < start {
+			continue
+		}
+		return []byte(content[start : end+len(closing)])
+	}
+	return body
+}
+
+func fetchBrowserFeed(site Site, feedURL string, timeout time.Duration, span *Span) *FeedResult {
+	render := startSpan(span, "browser")
+	start := time.Now()
+	body, err := renderPage(feedURL, timeout)
+	render.fail(err)
+	render.finish()
+	if err != nil {
+		return &FeedResult{Error: fmt.Errorf("browser fetch: %w", err)}
+	}
+
+	return parseFetchedFeed(site, feedURL, extractRenderedFeed(body), time.Since(start), span)
+}
+
+func setBrowser(sites SiteData, args []string) error {
+	if len(args) != 2 || args[1] != "on" && args[1] != "off" {
+		return fmt.Errorf("usage: browser <site> <on|off>")
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	name, err := resolveSiteName(sites, args[0], reader)
+	if err != nil {
+		return err
+	}
+
+	site := sites[name]
+	site.Browser = args[1] == "on"
+	sites[name] = site
+	if err := saveSites(sites); err != nil {
+		return fmt.Errorf("saving sites: %w", err)
+	}
+
+	if site.Browser {
+		fmt.Printf("✓ '%s' is now fetched through the headless browser\n", name)
+		return nil
+	}
+	fmt.Printf("✓ '%s' is fetched directly again\n", name)
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// hasTag reports whether tags has tag, ignoring case, or a tag nested under
+// it: work matches work/security too, as it does for /feeds/work.xml.
+func hasTag(tags []string, tag string) bool {
+	tag = strings.TrimSpace(tag)
+	for _, siteTag := range tags {
+		siteTag = strings.TrimSpace(siteTag)
+		if strings.EqualFold(siteTag, tag) || (len(siteTag) > len(tag) && siteTag[len(tag)] == '/' && strings.EqualFold(siteTag[:len(tag)], tag)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sitesTagged returns the names of the sites with tag or one nested under
+// it, sorted.
+func sitesTagged(sites SiteData, tag string) ([]string, error) {
+	var names []string
+	for name, site := range sites {
+		if hasTag(site.Tags, tag) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no sites are tagged '%s'", tag)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// onHold reports whether checks skip the site unless it is named: it is
+// paused, snoozed until later than now, or backing off after its name
+// failed to resolve.
+func (s Site) onHold(now time.Time) bool {
+	return s.Paused || (s.SnoozedUntil != nil && s.SnoozedUntil.After(now)) || (s.RetryAfter != nil && s.RetryAfter.After(now))
+}
+
+// confirmGroup asks before acting on every site with a tag, listing them.
+func confirmGroup(reader *bufio.Reader, action, tag string, names []string) bool {
+	fmt.Printf("%s %d sites tagged '%s' (%s)? (y/n): ", action, len(names), tag, strings.Join(names, ", "))
+	confirm, _ := reader.ReadString('\n')
+	return strings.TrimSpace(strings.ToLower(confirm)) == "y"
+}
+
+// pauseSites pauses (or resumes) the named site or every site with -tag.
+// Paused sites are left out of checks until they are resumed.
+func pauseSites(sites SiteData, args []string, paused bool) error {
+	command, verb, unchanged := "pause", "Paused", "is already paused"
+	if !paused {
+		command, verb, unchanged = "resume", "Resumed", "isn't paused"
+	}
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	tag := fs.String("tag", "", "Apply to every site with this tag.")
+	positional := parseInterspersed(fs, args)
+
+	if err := refuseWhileServing(); err != nil {
+		return err
+	}
+
+	var names []string
+	switch {
+	case *tag != "" && len(positional) == 0:
+		var err error
+		if names, err = sitesTagged(sites, *tag); err != nil {
+			return err
+		}
+	case *tag == "" && len(positional) == 1:
+		name, err := resolveSiteName(sites, positional[0], bufio.NewReader(os.Stdin))
+		if err != nil {
+			return err
+		}
+		names = []string{name}
+	default:
+		return fmt.Errorf("usage: %s <site> | %s -tag <tag>", command, command)
+	}
+
+	changed := 0
+	for _, name := range names {
+		site := sites[name]
+		if site.Paused != paused {
+			site.Paused = paused
+			sites[name] = site
+			changed++
+		}
+	}
+	if changed > 0 {
+		if err := saveSites(sites); err != nil {
+			return fmt.Errorf("saving sites: %w", err)
+		}
+	}
+
+	if *tag == "" {
+		if changed == 0 {
+			fmt.Printf("'%s' %s\n", names[0], unchanged)
+			return nil
+		}
+		fmt.Printf("✓ %s '%s'\n", verb, names[0])
+		return nil
+	}
+	fmt.Printf("✓ %s %d of %d sites tagged '%s'\n", verb, changed, len(names), *tag)
+	return nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	RESOLVE_OFF       = "off"
+	RESOLVE_REDIRECTS = "redirects"
+	RESOLVE_CANONICAL = "canonical"
+	RESOLVE_WORKERS   = 4
+	RESOLVE_MAX_PAGE  = 1 << 20
+)
+
+func validateResolveMode(mode string) error {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", RESOLVE_OFF, RESOLVE_REDIRECTS, RESOLVE_CANONICAL:
+		return nil
+	}
+	return fmt.Errorf("resolve_links must be \"off\", \"redirects\" or \"canonical\", got '%s'", mode)
+}
+
+// resolveMode returns how a site's entry links are resolved, or "" when they
+// are stored as the feed gives them. The site's own setting takes precedence.
+func (c Config) resolveMode(site Site) string {
+	mode := site.ResolveLinks
+	if mode == "" {
+		mode = c.ResolveLinks
+	}
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode == RESOLVE_OFF {
+		return ""
+	}
+	return mode
+}
+
+// findCanonicalLink returns the <link rel="canonical"> of a page's <head>.
+func findCanonicalLink(body []byte, base *url.URL) string {
+	decoder := newHTMLDecoder(body)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		name := strings.ToLower(start.Name.Local)
+		if name == "body" {
+			return ""
+		}
+		if name != "link" {
+			continue
+		}
+
+		var rel, href string
+		for _, attr := range start.Attr {
+			switch strings.ToLower(attr.Name.Local) {
+			case "rel":
+				rel = " " + strings.ToLower(attr.Value) + " "
+			case "href":
+				href = strings.TrimSpace(attr.Value)
+			}
+		}
+		if !strings.Contains(rel, " canonical ") || href == "" {
+			continue
+		}
+		resolved, err := base.Parse(href)
+		if err != nil || (resolved.Scheme != "http" && resolved.Scheme != "https") {
+			continue
+		}
+		return resolved.String()
+	}
+}
+
+// stripTrackingParams drops the utm_* parameters feed proxies append.
+func stripTrackingParams(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.RawQuery == "" {
+		return link
+	}
+	query := u.Query()
+	changed := false
+	for key := range query {
+		if strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+			changed = true
+		}
+	}
+	if !changed {
+		return link
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// resolveEntryLink follows an entry link through its redirects and, in
+// canonical mode, to the rel="canonical" URL of the page it lands on.
+func resolveEntryLink(client *http.Client, link, mode string) (string, error) {
+	if mode == RESOLVE_REDIRECTS {
+		// HEAD is enough to follow redirects, but not every server allows it.
+		if resp, err := client.Head(link); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 400 {
+				return stripTrackingParams(resp.Request.URL.String()), nil
+			}
+		}
+	}
+
+	resp, err := client.Get(link)
+	if err != nil {
+		return "", fmt.Errorf("HTTP error: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("HTTP status: %s", resp.Status)
+	}
+
+	final := resp.Request.URL
+	if mode == RESOLVE_CANONICAL && looksLikeHTML(resp.Header.Get("Content-Type"), nil) {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, RESOLVE_MAX_PAGE))
+		if err == nil {
+			if canonical := findCanonicalLink(body, final); canonical != "" {
+				return stripTrackingParams(canonical), nil
+			}
+		}
+	}
+	return stripTrackingParams(final.String()), nil
+}
+
+// storedLinks maps the keys of a site's stored entries to their links.
+func (s *EntryStore) storedLinks(siteName string) map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	links := make(map[string]string)
+	for _, entry := range s.Entries {
+		if entry.Site == siteName {
+			links[entryKey(entry.Site, entry.GUID, entry.Link)] = entry.Link
+		}
+	}
+	return links
+}
+
+// resolveEntryLinks rewrites the links of a feed's entries the way they are
+// stored, so a proxy handing out a different link on every fetch doesn't
+// make the feed look changed. Entries stored before get their stored link;
+// new entries and the latest one otherwise are resolved, though on a site's
+// first check only the latest is. It returns the rewritten entries and the
+// new ones among them to record. An entry without a GUID keeps its original
+// link as GUID so it is still recognised on the next check. New entries that
+// resolve to a link the site already has are marked seen and left out.
+func resolveEntryLinks(siteName string, site Site, feedEntries []FeedEntry, mode string, first bool, entries *EntryStore) ([]FeedEntry, []FeedEntry) {
+	stored := entries.storedLinks(siteName)
+	known := make(map[string]bool, len(stored))
+	for _, link := range stored {
+		known[link] = true
+	}
+
+	feed := append([]FeedEntry(nil), feedEntries...)
+	isNew := make([]bool, len(feed))
+	var pending []int
+	for i, entry := range feed {
+		key := entryKey(siteName, entry.ID, entry.Link)
+		isNew[i] = !entries.has(key)
+		if entry.Link == "" {
+			continue
+		}
+		if link, ok := stored[key]; ok {
+			feed[i].Link = link
+			continue
+		}
+		// The latest entry decides whether the site changed, so it is
+		// resolved even when it was seen without being stored.
+		if i == 0 || (isNew[i] && !first) {
+			pending = append(pending, i)
+		}
+	}
+
+	resolved := make([]string, len(feed))
+	if len(pending) > 0 {
+		client := newFeedClient(httpTimeout, site.IPVersion)
+		slots := make(chan struct{}, RESOLVE_WORKERS)
+		var wg sync.WaitGroup
+		for _, i := range pending {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				slots <- struct{}{}
+				defer func() { <-slots }()
+				if link, err := resolveEntryLink(client, feed[i].Link, mode); err == nil {
+					resolved[i] = link
+				}
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	var recordable []FeedEntry
+	for i, entry := range feed {
+		link := resolved[i]
+		if link == "" || link == entry.Link {
+			if isNew[i] {
+				recordable = append(recordable, entry)
+			}
+			continue
+		}
+		feed[i].Link = link
+		if !isNew[i] {
+			continue
+		}
+		if known[link] {
+			entries.markSeen(entryKey(siteName, entry.ID, entry.Link))
+			continue
+		}
+		known[link] = true
+		if entry.ID == "" {
+			entry.ID = entry.Link
+		}
+		entry.Link = link
+		recordable = append(recordable, entry)
+	}
+	return feed, recordable
+}
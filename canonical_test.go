@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// A proxy link that changes on every fetch must read as the same entry, or
+// the site looks changed and notifies again each time.
+func TestResolveChangingProxyLink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/post" {
+			fmt.Fprint(w, "<html></html>")
+			return
+		}
+		http.Redirect(w, r, "/post?utm_source=feedburner", http.StatusFound)
+	}))
+	defer server.Close()
+	post := server.URL + "/post"
+
+	entries := newEntryStore(memory)
+	entries.index()
+	site := Site{LatestEntry: ""}
+	for fetch := 1; fetch <= 3; fetch++ {
+		proxied := fmt.Sprintf("%s/~r/%d", server.URL, fetch)
+		feed, recordable := resolveEntryLinks("blog", site, []FeedEntry{{Title: "Post", Link: proxied}}, RESOLVE_REDIRECTS, fetch == 1, entries)
+		if feed[0].Link != post {
+			t.Fatalf("fetch %d: feed link is %q, want %q", fetch, feed[0].Link, post)
+		}
+
+		added := entries.record("blog", recordable, fetch == 1)
+		if fetch > 1 && len(added) > 0 {
+			t.Fatalf("fetch %d: recorded %+v again", fetch, added)
+		}
+
+		result := &FeedResult{Entries: feed, LatestLink: feed[0].Link}
+		if _, changed := (LatestLinkDetector{}).Detect(ChangeCheck{Site: site, Result: result}); fetch > 1 && changed {
+			t.Fatalf("fetch %d: the site looks changed", fetch)
+		}
+		site.LatestEntry = result.LatestLink
+	}
+
+	if len(entries.Entries) != 1 || entries.Entries[0].Link != post {
+		t.Fatalf("stored %+v", entries.Entries)
+	}
+}
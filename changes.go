@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	CHANGE_LATEST_LINK  = "latest_link"
+	CHANGE_GUID         = "guid"
+	CHANGE_PUBLISHED    = "published"
+	CHANGE_CONTENT_HASH = "content_hash"
+)
+
+// ChangeCheck is what a ChangeDetector compares: the site as it was before
+// the check, what the feed returned, and the entries the store hadn't seen.
+type ChangeCheck struct {
+	Site       Site
+	Result     *FeedResult
+	NewEntries []Entry
+}
+
+// ChangeDetector decides whether a site has something new since its last
+// check, and which entry to announce for it.
+type ChangeDetector interface {
+	Detect(check ChangeCheck) (FeedEntry, bool)
+}
+
+// LatestLinkDetector compares the feed's first link with the one seen last.
+type LatestLinkDetector struct{}
+
+// GUIDDetector reports entries whose GUID or link was never seen, for feeds
+// that reorder their items.
+type GUIDDetector struct{}
+
+// PublishedDetector reports entries published after the newest date seen so
+// far, for feeds whose links change on every fetch.
+type PublishedDetector struct{}
+
+// ContentHashDetector reports any change to the feed's entries, for feeds
+// that edit entries in place.
+type ContentHashDetector struct{}
+
+func validateChangeDetection(name string) error {
+	_, err := newChangeDetector(name)
+	return err
+}
+
+func newChangeDetector(name string) (ChangeDetector, error) {
+	switch name {
+	case "", CHANGE_LATEST_LINK:
+		return LatestLinkDetector{}, nil
+	case CHANGE_GUID:
+		return GUIDDetector{}, nil
+	case CHANGE_PUBLISHED:
+		return PublishedDetector{}, nil
+	case CHANGE_CONTENT_HASH:
+		return ContentHashDetector{}, nil
+	default:
+		return nil, fmt.Errorf("change_detection must be \"%s\", \"%s\", \"%s\" or \"%s\", got '%s'",
+			CHANGE_LATEST_LINK, CHANGE_GUID, CHANGE_PUBLISHED, CHANGE_CONTENT_HASH, name)
+	}
+}
+
+// changeDetection returns the strategy a site is compared with. The site's
+// own setting takes precedence.
+func (c Config) changeDetection(site Site) string {
+	if site.ChangeDetection != "" {
+		return site.ChangeDetection
+	}
+	return c.ChangeDetection
+}
+
+func (LatestLinkDetector) Detect(check ChangeCheck) (FeedEntry, bool) {
+	if check.Result.LatestLink == strings.TrimSpace(check.Site.LatestEntry) {
+		return FeedEntry{}, false
+	}
+	latest := check.Result.Entries[0]
+	latest.Link = check.Result.LatestLink
+	return latest, true
+}
+
+func (GUIDDetector) Detect(check ChangeCheck) (FeedEntry, bool) {
+	if len(check.NewEntries) == 0 {
+		return FeedEntry{}, false
+	}
+	newest := check.NewEntries[0]
+	for _, entry := range check.NewEntries[1:] {
+		if entry.Published.After(newest.Published) {
+			newest = entry
+		}
+	}
+	return FeedEntry{ID: newest.GUID, Title: newest.Title, Link: newest.Link, Published: newest.Published, Author: newest.Author}, true
+}
+
+func (PublishedDetector) Detect(check ChangeCheck) (FeedEntry, bool) {
+	var newest FeedEntry
+	found := false
+	for _, entry := range check.Result.Entries {
+		if entry.Published.IsZero() || (check.Site.LastPublished != nil && !entry.Published.After(*check.Site.LastPublished)) {
+			continue
+		}
+		if !found || entry.Published.After(newest.Published) {
+			newest, found = entry, true
+		}
+	}
+	return newest, found
+}
+
+func (ContentHashDetector) Detect(check ChangeCheck) (FeedEntry, bool) {
+	if feedContentHash(check.Result) == check.Site.ContentHash {
+		return FeedEntry{}, false
+	}
+	return check.Result.Entries[0], true
+}
+
+// feedContentHash sums up the entries of a feed, ignoring everything about it
+// that is not an entry.
+func feedContentHash(result *FeedResult) string {
+	h := sha256.New()
+	for _, entry := range result.Entries {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00", entry.ID, entry.Title, entry.Link, entry.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// What checking a site came to.
+const (
+	CHECK_FAILED       = "failed"
+	CHECK_NOT_MODIFIED = "not_modified"
+	CHECK_FILTERED     = "filtered"
+	CHECK_FIRST        = "first"
+	CHECK_NEW_URLS     = "new_urls"
+	CHECK_NEW_ENTRY    = "new_entry"
+	CHECK_REPEAT       = "repeat"
+	CHECK_UNCHANGED    = "unchanged"
+	CHECK_SKIPPED      = "skipped"
+)
+
+// CheckOutcome is the result of checking one site once it has been recorded,
+// for the console or any other consumer to present.
+type CheckOutcome struct {
+	SiteName string
+	Status   string
+	Err      error
+	FeedType FeedType
+	// Title and Latest describe the newest entry of a CHECK_NEW_ENTRY or
+	// CHECK_REPEAT site.
+	Title  string
+	Latest FeedEntry
+	// NewEntries are the entries reported; Capped more were marked read.
+	NewEntries []Entry
+	Capped     int
+	// Warnings are problems that did not stop the site from being checked.
+	Warnings []error
+}
+
+// CheckRun streams the outcomes of a check as sites finish.
+type CheckRun struct {
+	Results <-chan CheckOutcome
+	finish  func() error
+}
+
+// Wait discards any outcomes not read yet, then saves the state and sends
+// notifications. Anything it prints comes after the outcomes.
+func (r *CheckRun) Wait() error {
+	for range r.Results {
+	}
+	return r.finish()
+}
+
+// startChecks checks the selected sites and records what they bring, sending
+// an outcome for each site as it is done.
+func startChecks(sites *SiteRepo, config Config, entries *EntryStore, opts CheckOptions) *CheckRun {
+	// Checks run on copies; their results are applied to the current sites.
+	snapshot := sites.Snapshot()
+	selected := opts.selectSites(snapshot)
+
+	outcomes := make(chan CheckOutcome, len(selected))
+	run := &CheckRun{Results: outcomes}
+
+	go func() {
+		cycle := startSpan(nil, "check_cycle")
+		cycle.set("sites", len(selected))
+
+		results := dispatchChecks(snapshot, selected, config, opts.Deadline, cycle)
+
+		hasUpdates := false
+		hasStats := false
+		hasNewEntries := false
+		var notifications []Notification
+		var checked []string
+		var links []string
+		var events []EntryEvent
+
+		// apply records a site's result, returning its outcome unless the site
+		// has been removed meanwhile.
+		apply := func(result CheckResult) (CheckOutcome, bool) {
+			siteName := result.SiteName
+			feedResult := result.Result
+
+			site, ok := sites.Get(siteName)
+			if !ok {
+				// Removed while it was being checked.
+				return CheckOutcome{}, false
+			}
+			update := func(change func(site *Site)) {
+				change(&site)
+				sites.Update(siteName, change)
+			}
+			outcome := CheckOutcome{SiteName: siteName, FeedType: feedResult.FeedType}
+
+			if result.Skipped {
+				outcome.Status = CHECK_SKIPPED
+				return outcome, true
+			}
+
+			if feedResult.Elapsed > 0 {
+				update(func(site *Site) { site.recordLatency(feedResult.Elapsed) })
+				hasStats = true
+			}
+
+			// Read messages are passed over even if they made no entries, so
+			// they aren't read again; it is saved along with the entries.
+			if feedResult.MailCursor != nil {
+				update(func(site *Site) { site.MailCursor = feedResult.MailCursor })
+				hasStats = true
+			}
+
+			if feedResult.Error != nil {
+				now := time.Now()
+				update(func(site *Site) {
+					site.LastError = feedResult.Error.Error()
+					site.LastErrorAt = &now
+					site.LastErrorKind = netErrorKind(feedResult.Error)
+					site.recordCheck(true)
+					site.backOff(site.LastErrorKind, now)
+				})
+				hasUpdates = true
+
+				outcome.Status, outcome.Err = CHECK_FAILED, feedResult.Error
+				return outcome, true
+			}
+
+			update(func(site *Site) { site.recordCheck(false) })
+			hasStats = true
+			if feedResult.MovedTo != site.MovedTo {
+				update(func(site *Site) { site.MovedTo = feedResult.MovedTo })
+			}
+
+			if site.LastError != "" {
+				update(func(site *Site) {
+					site.LastError = ""
+					site.LastErrorAt = nil
+					site.LastErrorKind = ""
+					site.RetryAfter = nil
+				})
+				hasUpdates = true
+			}
+
+			if detected := feedTypeName(feedResult.FeedType); site.DetectedType == "" && detected != "" {
+				update(func(site *Site) { site.DetectedType = detected })
+				hasStats = true
+			}
+
+			if feedResult.ETag != site.ETag || feedResult.LastModified != site.LastModified {
+				update(func(site *Site) { site.ETag, site.LastModified = feedResult.ETag, feedResult.LastModified })
+				hasStats = true
+			}
+
+			if feedResult.NotModified {
+				checked = append(checked, siteName)
+				outcome.Status = CHECK_NOT_MODIFIED
+				return outcome, true
+			}
+
+			savedLink := strings.TrimSpace(site.LatestEntry)
+
+			if feedResult.LatestLink == "" {
+				outcome.Status = CHECK_FILTERED
+				return outcome, true
+			}
+
+			recordable := feedResult.Entries
+			if err := validateResolveMode(site.ResolveLinks); err != nil {
+				outcome.Warnings = append(outcome.Warnings, err)
+			} else if mode := config.resolveMode(site); mode != "" && len(feedResult.Entries) > 0 {
+				// Detection and notifications see the links as they are
+				// stored, not the proxy's.
+				latest := feedResult.Entries[0].Link
+				feedResult.Entries, recordable = resolveEntryLinks(siteName, site, feedResult.Entries, mode, savedLink == "", entries)
+				if feedResult.LatestLink == latest {
+					feedResult.LatestLink = feedResult.Entries[0].Link
+				}
+			}
+			before := site
+			newEntries := entries.record(siteName, recordable, savedLink == "")
+			if len(newEntries) > 0 {
+				hasNewEntries = true
+			}
+			var repeatWindow time.Duration
+			if savedLink != "" {
+				repeatWindow = config.repeatWindow()
+			}
+			var repeats map[string]bool
+			if repeatWindow > 0 {
+				var updated bool
+				if newEntries, repeats, updated = entries.foldRepeats(siteName, recordable, newEntries, repeatWindow); updated {
+					hasNewEntries = true
+				}
+			}
+			recorded := newEntries
+
+			capped := 0
+			if savedLink == "" {
+				var err error
+				if newEntries, err = backfillEntries(newEntries, config.backfillPolicy(site), entries); err != nil {
+					outcome.Warnings = append(outcome.Warnings, err)
+				}
+			} else if !opts.AllNew {
+				newEntries, capped = capNewEntries(newEntries, config.newEntryCap(site), entries)
+			}
+			outcome.Capped = capped
+
+			var rules ruleOutcome
+			if len(newEntries) > 0 {
+				rules = config.applyRules(siteName, site, newEntries, feedResult.FeedType, entries)
+				notifications = append(notifications, rules.notifications...)
+				links = append(links, rules.open...)
+
+				for _, entry := range newEntries {
+					if !rules.ignored[entry.Link] {
+						events = append(events, newEntryEvent(entry, site))
+					}
+				}
+			}
+
+			if feedResult.SiteURL != "" && feedResult.SiteURL != site.SiteURL {
+				update(func(site *Site) { site.SiteURL = feedResult.SiteURL })
+				hasUpdates = true
+			}
+
+			sites.Update(siteName, func(current *Site) {
+				if current.updateLastPublished(feedResult.Entries) {
+					hasStats = true
+				}
+				site.LastPublished = current.LastPublished
+			})
+			checked = append(checked, siteName)
+
+			detection := config.changeDetection(site)
+			detector, err := newChangeDetector(detection)
+			if err != nil {
+				outcome.Warnings = append(outcome.Warnings, err)
+				detector = LatestLinkDetector{}
+			}
+			if detection == CHANGE_CONTENT_HASH {
+				if hash := feedContentHash(feedResult); hash != site.ContentHash {
+					update(func(site *Site) { site.ContentHash = hash })
+					hasStats = true
+				}
+			}
+
+			var announced FeedEntry
+			changed := false
+			if savedLink != "" && site.Type != SITE_TYPE_SITEMAP {
+				announced, changed = detector.Detect(ChangeCheck{Site: before, Result: feedResult, NewEntries: recorded})
+			}
+			repeat := changed && repeats[announced.Link]
+			if changed && !repeat && repeatWindow > 0 {
+				var updated bool
+				if repeat, updated = entries.refreshRepeat(siteName, announced, recorded, repeatWindow); updated {
+					hasNewEntries = true
+				}
+			}
+
+			switch {
+			case savedLink == "":
+				outcome.Status, outcome.NewEntries = CHECK_FIRST, newEntries
+				for i := len(newEntries) - 1; i >= 0; i-- {
+					entry := newEntries[i]
+					if notification, ok := rules.filter(newEntryNotification(config, siteName, site, entry, feedResult.FeedType)); ok && (notification.Priority != nil || !site.Muted) {
+						notifications = append(notifications, notification)
+					}
+				}
+				update(func(site *Site) { site.LatestEntry = feedResult.LatestLink })
+				hasUpdates = true
+
+			case site.Type == SITE_TYPE_SITEMAP:
+				if len(newEntries) == 0 {
+					outcome.Status = CHECK_UNCHANGED
+					break
+				}
+
+				outcome.Status, outcome.NewEntries = CHECK_NEW_URLS, newEntries
+				for _, entry := range newEntries {
+					if notification, ok := rules.filter(newEntryNotification(config, siteName, site, entry, feedResult.FeedType)); ok && (notification.Priority != nil || !site.Muted) {
+						notifications = append(notifications, notification)
+					}
+				}
+				update(func(site *Site) { site.LatestEntry = feedResult.LatestLink })
+				hasUpdates = true
+
+			case repeat:
+				outcome.Status, outcome.NewEntries = CHECK_REPEAT, newEntries
+				outcome.Title, outcome.Latest = announced.Title, announced
+				update(func(site *Site) { site.LatestEntry = feedResult.LatestLink })
+				hasUpdates = true
+
+			case changed:
+				title := announced.Title
+				if title == "" {
+					title = "Untitled"
+				}
+				outcome.Status, outcome.NewEntries = CHECK_NEW_ENTRY, newEntries
+				outcome.Title, outcome.Latest = title, announced
+				update(func(site *Site) { site.LatestEntry = feedResult.LatestLink })
+				hasUpdates = true
+
+				notification := Notification{
+					SiteName:  siteName,
+					Title:     title,
+					Link:      announced.Link,
+					FeedType:  feedResult.FeedType,
+					Priority:  config.matchPriorityRule(announced.Title),
+					Notifiers: config.notifiersFor(site),
+				}
+				if notification, ok := rules.filter(notification); ok && (notification.Priority != nil || !site.Muted) {
+					notifications = append(notifications, notification)
+				}
+
+			default:
+				outcome.Status = CHECK_UNCHANGED
+				// Other strategies still keep the latest link up to date.
+				if feedResult.LatestLink != savedLink {
+					update(func(site *Site) { site.LatestEntry = feedResult.LatestLink })
+					hasUpdates = true
+				}
+			}
+
+			return outcome, true
+		}
+		for result := range results {
+			if opts.StateLock != nil {
+				opts.StateLock.Lock()
+			}
+			outcome, ok := apply(result)
+			if opts.StateLock != nil {
+				opts.StateLock.Unlock()
+			}
+			if ok {
+				outcomes <- outcome
+			}
+		}
+		run.finish = func() error {
+			defer cycle.finish()
+
+			if reportStaleSites(opts.output(), sites, checked, config) {
+				hasStats = true
+			}
+
+			save := startSpan(cycle, "save")
+			var err error
+			if opts.Saver != nil {
+				opts.Saver.schedule(hasUpdates || hasStats, hasNewEntries)
+			} else {
+				store := opts.Store
+				if store == nil {
+					store = openStore(databaseFile, "")
+				}
+				err = saveCheckState(opts.output(), store, sites.Snapshot(), entries, hasUpdates, hasStats, hasNewEntries)
+			}
+			save.fail(err)
+			save.finish()
+			if err != nil {
+				return err
+			}
+
+			notify := startSpan(cycle, "notify")
+			notify.set("notifications", len(notifications))
+			notify.set("events", len(events))
+			dispatchNotifications(opts.output(), config, notifications)
+			publishEntries(opts.output(), config, events)
+			notify.finish()
+
+			for _, link := range links {
+				if err := openInBrowser(link); err != nil {
+					fmt.Fprintf(opts.output(), "Opening %s → ERROR: %v\n", link, err)
+				}
+			}
+			return nil
+		}
+		close(outcomes)
+	}()
+
+	return run
+}
+
+// checkPrinter writes check outcomes the way the check command shows them,
+// numbering the sites that were checked successfully.
+type checkPrinter struct {
+	out      io.Writer
+	index    int
+	relative bool
+	failed   []string
+	skipped  []string
+}
+
+func (p *checkPrinter) print(outcome CheckOutcome) {
+	siteName := outcome.SiteName
+	for _, err := range outcome.Warnings {
+		fmt.Fprintf(p.out, "%s → ERROR: %v\n", siteName, err)
+	}
+
+	if outcome.Status == CHECK_SKIPPED {
+		p.skipped = append(p.skipped, siteName)
+		return
+	}
+	if outcome.Status == CHECK_FAILED {
+		p.failed = append(p.failed, siteName)
+		if strings.Contains(outcome.Err.Error(), "timeout exceeded") {
+			fmt.Fprintf(p.out, "%s → TIMEOUT: %v\n", siteName, outcome.Err)
+		} else if strings.Contains(outcome.Err.Error(), "no entries found") {
+			fmt.Fprintf(p.out, "%s → %v\n", siteName, outcome.Err)
+		} else {
+			fmt.Fprintf(p.out, "%s → ERROR: %v\n", siteName, outcome.Err)
+		}
+		return
+	}
+
+	p.index++
+	switch outcome.Status {
+	case CHECK_FILTERED:
+		fmt.Fprintf(p.out, "%d. (-_-) %s (no entries match the filter)\n", p.index, siteName)
+		return
+
+	case CHECK_FIRST:
+		fmt.Fprintf(p.out, "%d. %s → First time checking (%s)\n", p.index, siteName, feedTypeString(outcome.FeedType))
+		for i := len(outcome.NewEntries) - 1; i >= 0; i-- {
+			entry := outcome.NewEntries[i]
+			fmt.Fprintf(p.out, "   + %s%s - %s%s\n", entryDisplayTitle(entry), formatAuthor(entry.Author), entry.Link, formatPublished(entry.Published, p.relative))
+		}
+
+	case CHECK_NEW_URLS:
+		fmt.Fprintf(p.out, "%d. %s → %d NEW URLS (%s)\n", p.index, siteName, len(outcome.NewEntries), feedTypeString(outcome.FeedType))
+		for _, entry := range outcome.NewEntries {
+			fmt.Fprintf(p.out, "   + %s%s\n", entry.Link, formatPublished(entry.Published, p.relative))
+		}
+
+	case CHECK_NEW_ENTRY:
+		fmt.Fprintf(p.out, "%d. %s → NEW ENTRY: %s%s - %s (%s)%s\n", p.index, siteName, outcome.Title, formatAuthor(outcome.Latest.Author),
+			outcome.Latest.Link, feedTypeString(outcome.FeedType), formatPublished(outcome.Latest.Published, p.relative))
+
+	case CHECK_REPEAT:
+		fmt.Fprintf(p.out, "%d. (-_-) %s (republished: %s - %s; not notified again)\n", p.index, siteName, outcome.Title, outcome.Latest.Link)
+
+	default:
+		fmt.Fprintf(p.out, "%d. (-_-) %s\n", p.index, siteName)
+	}
+
+	if outcome.Capped > 0 {
+		fmt.Fprintf(p.out, "   … and %d more new entries, marked read (use -all-new to report them all)\n", outcome.Capped)
+	}
+}
+
+// printSkipped lists the sites a time budget ran out for.
+func (p *checkPrinter) printSkipped(deadline time.Time) {
+	if len(p.skipped) == 0 {
+		return
+	}
+	sort.Strings(p.skipped)
+	fmt.Fprintf(p.out, "\nTime budget used up at %s; skipped %d sites:\n", deadline.Format("15:04:05"), len(p.skipped))
+	for _, name := range p.skipped {
+		fmt.Fprintf(p.out, "  %s\n", name)
+	}
+}
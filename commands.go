@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+func siteInitials(name string) string {
+	var initials strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		initials.WriteRune(unicode.ToLower([]rune(word)[0]))
+	}
+	return initials.String()
+}
+
+func isSubsequence(query, target string) bool {
+	queryRunes := []rune(query)
+	i := 0
+	for _, r := range target {
+		if i < len(queryRunes) && r == queryRunes[i] {
+			i++
+		}
+	}
+	return i == len(queryRunes)
+}
+
+func matchSiteNames(sites SiteData, query string) []string {
+	if _, exists := sites[query]; exists {
+		return []string{query}
+	}
+	if name, exists := siteForAlias(sites, strings.TrimSpace(query)); exists {
+		return []string{name}
+	}
+
+	lowerQuery := strings.ToLower(strings.TrimSpace(query))
+	if lowerQuery == "" {
+		return nil
+	}
+
+	var exact, prefix, contains, initials, fuzzy []string
+	for name := range sites {
+		lowerName := strings.ToLower(name)
+		switch {
+		case lowerName == lowerQuery:
+			exact = append(exact, name)
+		case strings.HasPrefix(lowerName, lowerQuery):
+			prefix = append(prefix, name)
+		case strings.Contains(lowerName, lowerQuery):
+			contains = append(contains, name)
+		case strings.HasPrefix(siteInitials(name), lowerQuery):
+			initials = append(initials, name)
+		case isSubsequence(lowerQuery, lowerName):
+			fuzzy = append(fuzzy, name)
+		}
+	}
+
+	for _, tier := range [][]string{exact, prefix, contains, initials, fuzzy} {
+		if len(tier) > 0 {
+			sort.Strings(tier)
+			return tier
+		}
+	}
+	return nil
+}
+
+func resolveSiteName(sites SiteData, query string, reader *bufio.Reader) (string, error) {
+	matches := matchSiteNames(sites, query)
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no site matches '%s'", query)
+	case 1:
+		return matches[0], nil
+	}
+
+	fmt.Printf("Multiple sites match '%s':\n", query)
+	for i, name := range matches {
+		fmt.Printf("  %d. %s\n", i+1, name)
+	}
+
+	for {
+		fmt.Print("Choose a site (number, empty to cancel): ")
+		choice, err := reader.ReadString('\n')
+		choice = strings.TrimSpace(choice)
+		if choice == "" {
+			return "", fmt.Errorf("no site selected for '%s'", query)
+		}
+
+		n, convErr := strconv.Atoi(choice)
+		if convErr == nil && n >= 1 && n <= len(matches) {
+			return matches[n-1], nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("error reading choice: %w", err)
+		}
+		fmt.Println("Invalid choice")
+	}
+}
+
+func confirmMatch(reader *bufio.Reader, query, name, action string) bool {
+	if query == name {
+		return true
+	}
+
+	fmt.Printf("%s '%s'? (y/n): ", action, name)
+	confirm, _ := reader.ReadString('\n')
+	return strings.TrimSpace(strings.ToLower(confirm)) == "y"
+}
+
+func snoozeSite(sites SiteData, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: snooze <site> <duration|off>")
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	name, err := resolveSiteName(sites, args[0], reader)
+	if err != nil {
+		return err
+	}
+
+	site := sites[name]
+	if args[1] == "off" || args[1] == "0" {
+		site.SnoozedUntil = nil
+		sites[name] = site
+		if err := saveSites(sites); err != nil {
+			return fmt.Errorf("saving sites: %w", err)
+		}
+		fmt.Printf("✓ '%s' is no longer snoozed\n", name)
+		return nil
+	}
+
+	duration, err := parseDuration(args[1])
+	if err != nil {
+		return err
+	}
+
+	until := time.Now().Add(duration)
+	site.SnoozedUntil = &until
+	sites[name] = site
+	if err := saveSites(sites); err != nil {
+		return fmt.Errorf("saving sites: %w", err)
+	}
+
+	fmt.Printf("✓ Snoozed '%s' until %s\n", name, until.Format("2006-01-02 15:04"))
+	return nil
+}
+
+func sortedSiteNames(sites SiteData) []string {
+	names := make([]string, 0, len(sites))
+	for name := range sites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func formatAgo(t time.Time) string {
+	elapsed := time.Since(t)
+	switch {
+	case elapsed < 0:
+		return "in the future"
+	case elapsed < time.Minute:
+		return "just now"
+	case elapsed < time.Hour:
+		return fmt.Sprintf("%dm ago", int(elapsed.Minutes()))
+	case elapsed < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(elapsed.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(elapsed.Hours()/24))
+	}
+}
+
+func formatPublished(published time.Time, relative bool) string {
+	if published.IsZero() {
+		return ""
+	}
+	if relative {
+		return " · " + formatAgo(published)
+	}
+	return " · " + published.Local().Format("2006-01-02 15:04 MST")
+}
+
+func listErrors(sites SiteData) {
+	failing := 0
+	kinds := make(map[string]int)
+	for _, name := range sortedSiteNames(sites) {
+		site := sites[name]
+		if site.LastError == "" {
+			continue
+		}
+		failing++
+		kinds[site.LastErrorKind]++
+
+		when := "unknown time"
+		if site.LastErrorAt != nil {
+			when = fmt.Sprintf("%s, %s", site.LastErrorAt.Format("2006-01-02 15:04"), formatAgo(*site.LastErrorAt))
+		}
+		fmt.Printf("%s → %s (%s)\n", name, site.LastError, when)
+	}
+
+	if failing == 0 {
+		fmt.Println("No failing sites")
+		return
+	}
+	fmt.Printf("\n%d of %d sites failing\n", failing, len(sites))
+	if kinds[NET_ERROR_DNS] > 0 {
+		fmt.Printf("  %d with DNS names not found, probably gone for good\n", kinds[NET_ERROR_DNS])
+	}
+	if kinds[NET_ERROR_REFUSED] > 0 {
+		fmt.Printf("  %d refusing connections, probably down for a moment\n", kinds[NET_ERROR_REFUSED])
+	}
+}
+
+func printStatus(sites SiteData, config Config) {
+	if len(sites) == 0 {
+		fmt.Println("No sites configured. Use -a to add sites.")
+		return
+	}
+
+	now := time.Now()
+	for i, name := range sortedSiteNames(sites) {
+		site := sites[name]
+
+		switch {
+		case site.Paused:
+			fmt.Printf("%d. %s → PAUSED\n", i+1, name)
+		case site.SnoozedUntil != nil && site.SnoozedUntil.After(now):
+			fmt.Printf("%d. %s → SNOOZED until %s\n", i+1, name, site.SnoozedUntil.Format("2006-01-02 15:04"))
+		case site.RetryAfter != nil && site.RetryAfter.After(now):
+			fmt.Printf("%d. %s → FAILING: %s (next try %s)\n", i+1, name, site.LastError, site.RetryAfter.Format("2006-01-02 15:04"))
+		case site.LastError != "":
+			fmt.Printf("%d. %s → FAILING: %s\n", i+1, name, site.LastError)
+		case site.LatestEntry == "":
+			fmt.Printf("%d. %s → NOT CHECKED YET\n", i+1, name)
+		default:
+			fmt.Printf("%d. %s → OK: %s\n", i+1, name, site.LatestEntry)
+		}
+		if site.LatestEntry != "" || site.LastError != "" {
+			fmt.Printf("   %s\n", formatHealth(computeHealth(name, site, config)))
+		}
+		if site.Note != "" {
+			fmt.Printf("   ✎ %s\n", site.Note)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	CONFIG_FILE              = "config.json"
+	DEFAULT_DIGEST_THRESHOLD = 5
+)
+
+type Config struct {
+	DigestThreshold  int                       `json:"digest_threshold,omitempty"`
+	Notifiers        map[string]NotifierConfig `json:"notifiers,omitempty"`
+	PriorityRules    []PriorityRule            `json:"priority_rules,omitempty"`
+	TagRoutes        map[string][]string       `json:"tag_routes,omitempty"`
+	DefaultNotify    []string                  `json:"default_notifiers"`
+	Fever            *FeverConfig              `json:"fever,omitempty"`
+	GReader          *GReaderConfig            `json:"greader,omitempty"`
+	FreshRSS         *FreshRSSConfig           `json:"freshrss,omitempty"`
+	RSSBridgeURL     string                    `json:"rss_bridge_url,omitempty"`
+	StaleAfter       string                    `json:"stale_after,omitempty"`
+	SMTP             *SMTPConfig               `json:"smtp,omitempty"`
+	Database         string                    `json:"database,omitempty"`
+	Entries          string                    `json:"entries,omitempty"`
+	Timeout          string                    `json:"timeout,omitempty"`
+	Workers          int                       `json:"workers,omitempty"`
+	HostWorkers      int                       `json:"host_workers,omitempty"`
+	Redirects        *RedirectConfig           `json:"redirects,omitempty"`
+	IPVersion        string                    `json:"ip_version,omitempty"`
+	FallbackDelay    string                    `json:"fallback_delay,omitempty"`
+	Secrets          *SecretsConfig            `json:"secrets,omitempty"`
+	Downloads        *DownloadConfig           `json:"downloads,omitempty"`
+	Rules            []Rule                    `json:"rules,omitempty"`
+	NotesExport      *NotesExportConfig        `json:"notes_export,omitempty"`
+	APIToken         string                    `json:"api_token,omitempty"`
+	ServeAuth        *ServeAuthConfig          `json:"serve_auth,omitempty"`
+	Users            map[string]UserConfig     `json:"users,omitempty"`
+	MQTT             *MQTTConfig               `json:"mqtt,omitempty"`
+	NATS             *NATSConfig               `json:"nats,omitempty"`
+	TrashDays        int                       `json:"trash_days,omitempty"`
+	Tracing          *TracingConfig            `json:"tracing,omitempty"`
+	MaxNewEntries    int                       `json:"max_new_entries,omitempty"`
+	Backfill         string                    `json:"backfill,omitempty"`
+	ServeLimits      *ServeLimits              `json:"serve_limits,omitempty"`
+	CORSOrigins      []string                  `json:"cors_origins,omitempty"`
+	ResolveLinks     string                    `json:"resolve_links,omitempty"`
+	PriorityInterval string                    `json:"priority_interval,omitempty"`
+	SaveDelay        string                    `json:"save_delay,omitempty"`
+	CheckExit        string                    `json:"check_exit,omitempty"`
+	ChangeDetection  string                    `json:"change_detection,omitempty"`
+	SavedSearches    map[string]SavedSearch    `json:"saved_searches,omitempty"`
+	TemplatesDir     string                    `json:"templates_dir,omitempty"`
+	TagRules         []TagRule                 `json:"tag_rules,omitempty"`
+	Browser          *BrowserConfig            `json:"browser,omitempty"`
+	Crawl            *CrawlConfig              `json:"crawl,omitempty"`
+	DNS              *DNSConfig                `json:"dns,omitempty"`
+	SaveFallback     string                    `json:"save_fallback,omitempty"`
+	RunSummary       *RunSummaryConfig         `json:"run_summary,omitempty"`
+	RepeatWindow     string                    `json:"repeat_window,omitempty"`
+}
+
+type PriorityRule struct {
+	Name      string   `json:"name"`
+	Keywords  []string `json:"keywords"`
+	Notifiers []string `json:"notifiers,omitempty"`
+}
+
+func readConfig() (Config, error) {
+	var config Config
+
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return config, fmt.Errorf("error reading config: %w", err)
+	}
+
+	if len(data) == 0 {
+		return config, nil
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("error parsing config: %w", err)
+	}
+
+	for name, notifierConfig := range config.Notifiers {
+		if _, err := newNotifier(notifierConfig); err != nil {
+			return config, fmt.Errorf("notifier '%s': %w", name, err)
+		}
+	}
+
+	if config.StaleAfter != "" {
+		if _, err := parseDuration(config.StaleAfter); err != nil {
+			return config, fmt.Errorf("stale_after: %w", err)
+		}
+	}
+
+	if config.PriorityInterval != "" {
+		if _, err := parseDuration(config.PriorityInterval); err != nil {
+			return config, fmt.Errorf("priority_interval: %w", err)
+		}
+	}
+
+	if config.SaveDelay != "" && config.SaveDelay != "0" {
+		if _, err := parseDuration(config.SaveDelay); err != nil {
+			return config, fmt.Errorf("save_delay: %w", err)
+		}
+	}
+
+	if config.RepeatWindow != "" {
+		if _, err := parseDuration(config.RepeatWindow); err != nil {
+			return config, fmt.Errorf("repeat_window: %w", err)
+		}
+	}
+
+	if err := validateChangeDetection(config.ChangeDetection); err != nil {
+		return config, err
+	}
+
+	if err := validateExitPolicy(config.CheckExit); err != nil {
+		return config, err
+	}
+
+	for _, rule := range config.TagRules {
+		if err := rule.validate(); err != nil {
+			return config, err
+		}
+	}
+
+	for name, search := range config.SavedSearches {
+		if err := validateSavedSearch(search); err != nil {
+			return config, fmt.Errorf("saved search '%s': %w", name, err)
+		}
+	}
+
+	if err := validateRunSummary(config.RunSummary); err != nil {
+		return config, err
+	}
+
+	if err := validateResolveMode(config.ResolveLinks); err != nil {
+		return config, err
+	}
+	if _, err := backfillCount(config.Backfill); err != nil {
+		return config, err
+	}
+
+	if config.TrashDays < 0 {
+		return config, fmt.Errorf("trash_days must not be negative, got %d", config.TrashDays)
+	}
+
+	if config.Downloads != nil && config.Downloads.RateLimit != "" {
+		if _, err := parseByteRate(config.Downloads.RateLimit); err != nil {
+			return config, fmt.Errorf("downloads rate_limit: %w", err)
+		}
+	}
+
+	for tag, names := range config.TagRoutes {
+		for _, name := range names {
+			if _, exists := config.Notifiers[name]; !exists {
+				return config, fmt.Errorf("tag route '%s': unknown notifier '%s'", tag, name)
+			}
+		}
+	}
+
+	for _, name := range config.DefaultNotify {
+		if _, exists := config.Notifiers[name]; !exists {
+			return config, fmt.Errorf("default_notifiers: unknown notifier '%s'", name)
+		}
+	}
+
+	for _, rule := range config.PriorityRules {
+		if len(rule.Keywords) == 0 {
+			return config, fmt.Errorf("priority rule '%s' has no keywords", rule.Name)
+		}
+		for _, name := range rule.Notifiers {
+			if _, exists := config.Notifiers[name]; !exists {
+				return config, fmt.Errorf("priority rule '%s': unknown notifier '%s'", rule.Name, name)
+			}
+		}
+	}
+
+	if config.MQTT != nil {
+		if err := config.MQTT.validate(); err != nil {
+			return config, err
+		}
+	}
+
+	if config.NATS != nil {
+		if err := config.NATS.validate(); err != nil {
+			return config, err
+		}
+	}
+
+	for _, rule := range config.Rules {
+		if err := rule.validate(config); err != nil {
+			return config, err
+		}
+	}
+
+	return config, nil
+}
+
+func (c Config) matchPriorityRule(title string) *PriorityRule {
+	title = strings.ToLower(title)
+
+	for i, rule := range c.PriorityRules {
+		for _, keyword := range rule.Keywords {
+			if keyword != "" && strings.Contains(title, strings.ToLower(keyword)) {
+				return &c.PriorityRules[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *PriorityRule) routesTo(notifierName string) bool {
+	if len(r.Notifiers) == 0 {
+		return true
+	}
+
+	for _, name := range r.Notifiers {
+		if name == notifierName {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c Config) notifiersFor(site Site) []string {
+	if site.Notifiers != nil {
+		return site.Notifiers
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, tag := range site.Tags {
+		for _, name := range c.TagRoutes[tag] {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) > 0 {
+		return names
+	}
+
+	if c.DefaultNotify != nil {
+		return c.DefaultNotify
+	}
+
+	for name, notifierConfig := range c.Notifiers {
+		if !notifierConfig.isTask() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (c Config) digestThreshold(notifierConfig NotifierConfig) int {
+	if notifierConfig.DigestThreshold > 0 {
+		return notifierConfig.DigestThreshold
+	}
+	if c.DigestThreshold > 0 {
+		return c.DigestThreshold
+	}
+	return DEFAULT_DIGEST_THRESHOLD
+}
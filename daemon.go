@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	DAEMON_SOCKET_SUFFIX = ".sock"
+	DAEMON_DIAL_TIMEOUT  = 2 * time.Second
+)
+
+// daemonRequest is a check handed over from the check command to serve.
+type daemonRequest struct {
+	Sites       []string      `json:"sites,omitempty"`
+	FailedOnly  bool          `json:"failed_only,omitempty"`
+	Tag         string        `json:"tag,omitempty"`
+	Relative    bool          `json:"relative,omitempty"`
+	AllNew      bool          `json:"all_new,omitempty"`
+	MaxDuration time.Duration `json:"max_duration,omitempty"`
+	NoConsole   bool          `json:"no_console,omitempty"`
+}
+
+// daemonMessage streams a handed-over check back: its report as it is
+// written, then the outcome.
+type daemonMessage struct {
+	Output string   `json:"output,omitempty"`
+	Done   bool     `json:"done,omitempty"`
+	Failed []string `json:"failed,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// daemonSocketPath is where serve listens for check commands: next to the
+// database, so only commands using the same database find it. Databases
+// that aren't files get no socket.
+func daemonSocketPath(database string) string {
+	if database == MEMORY_DATABASE || isPostgresDSN(database) {
+		return ""
+	}
+	return database + DAEMON_SOCKET_SUFFIX
+}
+
+// dialDaemon connects to a serve running on database, or returns nil if
+// there is none. A socket left behind by a serve that was killed just
+// refuses.
+func dialDaemon(database string) net.Conn {
+	path := daemonSocketPath(database)
+	if path == "" {
+		return nil
+	}
+	conn, err := net.DialTimeout("unix", path, DAEMON_DIAL_TIMEOUT)
+	if err != nil {
+		return nil
+	}
+	return conn
+}
+
+// refuseWhileServing fails when serve is running on the database: it keeps
+// the sites in memory and its next save would undo a change written behind
+// its back.
+func refuseWhileServing() error {
+	conn := dialDaemon(databaseFile)
+	if conn == nil {
+		return nil
+	}
+	conn.Close()
+	return fmt.Errorf("serve is running for %s and would overwrite the change; stop it first", databaseFile)
+}
+
+// listenDaemon takes the socket of the database this serve keeps, refusing
+// to start a second one on the same database.
+func (s *Server) listenDaemon(database string) error {
+	path := daemonSocketPath(database)
+	if path == "" {
+		return nil
+	}
+	if conn := dialDaemon(database); conn != nil {
+		conn.Close()
+		return fmt.Errorf("serve is already running for %s", database)
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		fmt.Printf("(-_-) Can't listen on %s, so check commands won't hand off to serve: %v\n", path, err)
+		return nil
+	}
+	os.Chmod(path, 0600)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handleDaemonConn(conn)
+		}
+	}()
+	return nil
+}
+
+// daemonWriter sends what a check writes to the command that handed it
+// over. Once the command is gone, writes are dropped and the check goes on.
+type daemonWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (w *daemonWriter) send(msg daemonMessage) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.enc.Encode(msg)
+}
+
+func (w *daemonWriter) Write(p []byte) (int, error) {
+	w.send(daemonMessage{Output: string(p)})
+	return len(p), nil
+}
+
+func (w *daemonWriter) done(failed []string, err error) {
+	msg := daemonMessage{Done: true, Failed: failed}
+	if err != nil {
+		msg.Error = err.Error()
+	}
+	w.send(msg)
+}
+
+func (s *Server) handleDaemonConn(conn net.Conn) {
+	defer conn.Close()
+
+	var req daemonRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+	out := &daemonWriter{enc: json.NewEncoder(conn)}
+
+	if !s.checking.TryLock() {
+		fmt.Fprintln(out, "→ Waiting for the check cycle in progress to finish...")
+		s.checking.Lock()
+	}
+	defer s.checking.Unlock()
+
+	for _, name := range req.Sites {
+		if _, ok := s.sites.Get(name); !ok {
+			out.done(nil, fmt.Errorf("serve doesn't know site '%s' yet", name))
+			return
+		}
+	}
+
+	opts := CheckOptions{Sites: req.Sites, FailedOnly: req.FailedOnly, Tag: req.Tag, Relative: req.Relative, AllNew: req.AllNew, Output: out}
+	if req.MaxDuration > 0 {
+		opts.Deadline = time.Now().Add(req.MaxDuration)
+	}
+
+	selected, ok := startCheckReport(out, s.sites.Snapshot(), opts)
+	if !ok {
+		out.done(nil, nil)
+		return
+	}
+	// A check run with -no-console is kept off serve's console too.
+	if !req.NoConsole {
+		fmt.Printf("→ Checking %d sites for a check command\n", len(selected))
+	}
+	failed, err := s.checkSites(opts, len(selected))
+	out.done(failed, err)
+}
+
+// handOffCheck has the running serve do a check, writing its report to out
+// as it comes. It returns the sites that failed.
+func handOffCheck(conn net.Conn, out io.Writer, req daemonRequest) ([]string, error) {
+	defer conn.Close()
+
+	fmt.Fprintln(out, "→ serve is running on this database; it will do the check")
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("handing the check to serve: %w", err)
+	}
+
+	decoder := json.NewDecoder(conn)
+	for {
+		var msg daemonMessage
+		if err := decoder.Decode(&msg); err != nil {
+			return nil, fmt.Errorf("serve stopped before the check finished: %w", err)
+		}
+		if msg.Output != "" {
+			io.WriteString(out, msg.Output)
+		}
+		if msg.Done {
+			if msg.Error != "" {
+				return msg.Failed, errors.New(msg.Error)
+			}
+			return msg.Failed, nil
+		}
+	}
+}
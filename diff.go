@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+type SiteChange struct {
+	Site        string   `json:"site"`
+	Change      string   `json:"change"`
+	From        string   `json:"from,omitempty"`
+	To          string   `json:"to,omitempty"`
+	NewEntries  []string `json:"new_entries,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	PrevError   string   `json:"previous_error,omitempty"`
+	SiteURLFrom string   `json:"site_url_from,omitempty"`
+	SiteURLTo   string   `json:"site_url_to,omitempty"`
+}
+
+type CheckDiff struct {
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at"`
+	Checked    int          `json:"checked"`
+	Changes    []SiteChange `json:"changes"`
+}
+
+// diffSites compares site state before and after a check. Sites whose
+// recorded state didn't change are left out.
+func diffSites(before, after SiteData, names []string, entries *EntryStore, firstNewID int64) []SiteChange {
+	newLinks := make(map[string][]string)
+	for _, entry := range entries.list(func(e Entry) bool { return e.ID >= firstNewID }) {
+		newLinks[entry.Site] = append(newLinks[entry.Site], entry.Link)
+	}
+
+	changes := []SiteChange{}
+	for _, name := range names {
+		old, current := before[name], after[name]
+
+		change := SiteChange{Site: name}
+		switch {
+		case current.LastError != "" && old.LastError == "":
+			change.Change = "failed"
+			change.Error = current.LastError
+		case current.LastError == "" && old.LastError != "":
+			change.Change = "recovered"
+			change.PrevError = old.LastError
+		case current.LastError != old.LastError:
+			change.Change = "failed"
+			change.Error, change.PrevError = current.LastError, old.LastError
+		}
+
+		if current.LatestEntry != old.LatestEntry {
+			change.From, change.To = old.LatestEntry, current.LatestEntry
+			if change.Change == "" || change.Change == "recovered" {
+				if old.LatestEntry == "" {
+					change.Change = "initialized"
+				} else {
+					change.Change = "advanced"
+				}
+			}
+		}
+		change.NewEntries = newLinks[name]
+		if change.Change == "" && len(change.NewEntries) > 0 {
+			change.Change = "new_entries"
+		}
+
+		if current.SiteURL != old.SiteURL {
+			change.SiteURLFrom, change.SiteURLTo = old.SiteURL, current.SiteURL
+			if change.Change == "" {
+				change.Change = "site_url"
+			}
+		}
+
+		if change.Change != "" {
+			changes = append(changes, change)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Site < changes[j].Site })
+	return changes
+}
+
+func writeCheckDiff(path string, diff CheckDiff) error {
+	data, err := json.MarshalIndent(diff, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling diff: %w", err)
+	}
+	data = append(data, '\n')
+
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing diff: %w", err)
+	}
+	return nil
+}
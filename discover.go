@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var feedLinkTypes = map[string]bool{
+	"application/rss+xml":  true,
+	"application/atom+xml": true,
+	"application/rdf+xml":  true,
+	"text/xml":             true,
+}
+
+var commonFeedPaths = []string{"/feed", "/rss.xml", "/atom.xml", "/feed.xml", "/index.xml", "/rss"}
+
+type DiscoveredFeed struct {
+	URL      string
+	Title    string
+	FeedType FeedType
+}
+
+func normalizePageURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid URL '%s'", raw)
+	}
+	return u, nil
+}
+
+func fetchForDiscovery(client *http.Client, target string) (*url.URL, []byte, error) {
+	resp, err := client.Get(target)
+	if err != nil {
+		return nil, nil, fmt.Errorf("HTTP error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("HTTP status: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read error: %w", err)
+	}
+	return resp.Request.URL, body, nil
+}
+
+func findFeedLinks(body []byte, base *url.URL) (links []string, pageTitle string) {
+	decoder := newHTMLDecoder(body)
+	inTitle := false
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if name == "title" && pageTitle == "" {
+				inTitle = true
+			}
+			if name == "body" {
+				return links, strings.TrimSpace(pageTitle)
+			}
+			if name != "link" {
+				continue
+			}
+
+			var rel, linkType, href string
+			for _, attr := range t.Attr {
+				switch strings.ToLower(attr.Name.Local) {
+				case "rel":
+					rel = strings.ToLower(attr.Value)
+				case "type":
+					linkType = strings.ToLower(strings.TrimSpace(attr.Value))
+				case "href":
+					href = strings.TrimSpace(attr.Value)
+				}
+			}
+			if href == "" || !feedLinkTypes[linkType] || !strings.Contains(" "+rel+" ", " alternate ") {
+				continue
+			}
+			if resolved, err := base.Parse(href); err == nil {
+				links = append(links, resolved.String())
+			}
+		case xml.EndElement:
+			if strings.ToLower(t.Name.Local) == "title" {
+				inTitle = false
+			}
+		case xml.CharData:
+			if inTitle {
+				pageTitle += string(t)
+			}
+		}
+	}
+
+	return links, strings.TrimSpace(pageTitle)
+}
+
+func probeFeed(client *http.Client, feedURL string) (DiscoveredFeed, bool) {
+	finalURL, body, err := fetchForDiscovery(client, feedURL)
+	if err != nil || detectFeedType(body) == FeedTypeUnknown {
+		return DiscoveredFeed{}, false
+	}
+
+	feed := DiscoveredFeed{URL: finalURL.String(), FeedType: detectFeedType(body)}
+	if result, err := parseFeed(body); err == nil {
+		feed.Title = result.FeedTitle
+	}
+	return feed, true
+}
+
+// discoverFeeds returns the feeds a page offers: the page itself when it is a
+// feed, else the feeds it links to, else the first feed at a common path.
+func discoverFeeds(pageURL string) ([]DiscoveredFeed, error) {
+	u, err := normalizePageURL(pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	client := newFeedClient(httpTimeout, "")
+	finalURL, body, err := fetchForDiscovery(client, u.String())
+	if err != nil {
+		return nil, err
+	}
+
+	if feedType := detectFeedType(body); feedType != FeedTypeUnknown {
+		feed := DiscoveredFeed{URL: finalURL.String(), FeedType: feedType}
+		if result, err := parseFeed(body); err == nil {
+			feed.Title = result.FeedTitle
+		}
+		return []DiscoveredFeed{feed}, nil
+	}
+
+	links, pageTitle := findFeedLinks(body, finalURL)
+	var feeds []DiscoveredFeed
+	seen := make(map[string]bool)
+	probe := func(candidate string) {
+		feed, ok := probeFeed(client, candidate)
+		if !ok || seen[feed.URL] {
+			return
+		}
+		seen[feed.URL] = true
+		if feed.Title == "" {
+			feed.Title = pageTitle
+		}
+		feeds = append(feeds, feed)
+	}
+
+	for _, link := range links {
+		probe(link)
+	}
+	for _, path := range commonFeedPaths {
+		if len(feeds) > 0 {
+			break
+		}
+		probe((&url.URL{Scheme: finalURL.Scheme, Host: finalURL.Host, Path: path}).String())
+	}
+
+	if len(feeds) == 0 {
+		return nil, fmt.Errorf("no feed found at %s", finalURL)
+	}
+	return feeds, nil
+}
+
+func discoverFeed(pageURL string) (DiscoveredFeed, error) {
+	feeds, err := discoverFeeds(pageURL)
+	if err != nil {
+		return DiscoveredFeed{}, err
+	}
+	return feeds[0], nil
+}
+
+// looksLikeHTML reports whether a response is a web page rather than a feed,
+// from its Content-Type or, when that is missing or generic, its markup.
+func looksLikeHTML(contentType string, body []byte) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	if mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+		return true
+	}
+	start := bytes.ToLower(bytes.TrimLeft(body[:min(len(body), 512)], " \t\r\n\xef\xbb\xbf"))
+	return bytes.HasPrefix(start, []byte("<!doctype html")) || bytes.HasPrefix(start, []byte("<html"))
+}
+
+// suggestFeed returns the first working feed a web page links to, if any.
+func suggestFeed(client *http.Client, pageURL *url.URL, body []byte) string {
+	links, _ := findFeedLinks(body, pageURL)
+	for _, link := range links {
+		if feed, ok := probeFeed(client, link); ok {
+			return feed.URL
+		}
+	}
+	return ""
+}
+
+func webPageError(suggestion string) error {
+	if suggestion != "" {
+		return fmt.Errorf("this is a web page, not a feed; did you mean %s?", suggestion)
+	}
+	return fmt.Errorf("this is a web page, not a feed, and it does not link to one")
+}
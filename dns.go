@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const DNS_DEFAULT_PORT = "53"
+
+// DNSConfig changes how feed hosts are looked up. Hosts pins names to
+// addresses the way /etc/hosts does; Server ("10.0.0.1" or "10.0.0.1:53")
+// answers for every other name instead of the system resolver.
+type DNSConfig struct {
+	Server string            `json:"server,omitempty"`
+	Hosts  map[string]string `json:"hosts,omitempty"`
+}
+
+var (
+	dnsHosts    map[string]string
+	dnsResolver *net.Resolver
+)
+
+func normalizeDNSName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+func applyDNSConfig(config *DNSConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	dnsHosts = make(map[string]string, len(config.Hosts))
+	for name, address := range config.Hosts {
+		ip := net.ParseIP(strings.TrimSpace(address))
+		if ip == nil {
+			return fmt.Errorf("config dns.hosts: '%s' for %s is not an IP address", address, name)
+		}
+		dnsHosts[normalizeDNSName(name)] = ip.String()
+	}
+
+	if config.Server != "" {
+		server := config.Server
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(strings.Trim(server, "[]"), DNS_DEFAULT_PORT)
+		}
+		host, _, _ := net.SplitHostPort(server)
+		if net.ParseIP(host) == nil {
+			return fmt.Errorf("config dns.server: '%s' is not an IP address", config.Server)
+		}
+
+		dnsResolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				dialer := net.Dialer{Timeout: DIAL_TIMEOUT}
+				return dialer.DialContext(ctx, network, server)
+			},
+		}
+	}
+	return nil
+}
+
+// dnsAddress replaces the host of a "host:port" address with its pinned
+// address, if it has one.
+func dnsAddress(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if ip, ok := dnsHosts[normalizeDNSName(host)]; ok {
+		return net.JoinHostPort(ip, port)
+	}
+	return addr
+}
+
+// newFeedDialer returns a dialer that looks names up through the configured
+// DNS server. Callers still pass their addresses through dnsAddress.
+func newFeedDialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout:       timeout,
+		KeepAlive:     DIAL_KEEP_ALIVE,
+		FallbackDelay: fallbackDelay,
+		Resolver:      dnsResolver,
+	}
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_DOWNLOAD_DIR         = "downloads"
+	DEFAULT_DOWNLOAD_CONCURRENCY = 2
+	PARTIAL_SUFFIX               = ".part"
+)
+
+type Enclosure struct {
+	URL        string `json:"url"`
+	Type       string `json:"type,omitempty"`
+	Length     int64  `json:"length,omitempty"`
+	Checksum   string `json:"checksum,omitempty"`
+	Downloaded string `json:"downloaded,omitempty"`
+}
+
+type DownloadConfig struct {
+	Dir         string `json:"dir,omitempty"`
+	Concurrency int    `json:"concurrency,omitempty"`
+	RateLimit   string `json:"rate_limit,omitempty"`
+	Verify      bool   `json:"verify,omitempty"`
+}
+
+type downloadJob struct {
+	entryID   int64
+	index     int
+	site      string
+	title     string
+	enclosure Enclosure
+	path      string
+}
+
+type rateLimiter struct {
+	mu   sync.Mutex
+	rate int64
+	next time.Time
+}
+
+type throttledReader struct {
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func newEnclosure(rawURL, mimeType, length string, hashes []RSSHash) Enclosure {
+	enclosure := Enclosure{
+		URL:  strings.TrimSpace(rawURL),
+		Type: strings.TrimSpace(mimeType),
+	}
+
+	if n, err := strconv.ParseInt(strings.TrimSpace(length), 10, 64); err == nil && n > 0 {
+		enclosure.Length = n
+	}
+
+	for _, h := range hashes {
+		algo := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(h.Algo), "-", ""))
+		if algo == "" {
+			algo = "md5"
+		}
+		if newChecksumHash(algo) != nil {
+			enclosure.Checksum = algo + ":" + strings.ToLower(strings.TrimSpace(h.Value))
+			break
+		}
+	}
+
+	return enclosure
+}
+
+func newChecksumHash(algo string) hash.Hash {
+	switch algo {
+	case "md5":
+		return md5.New()
+	case "sha1":
+		return sha1.New()
+	case "sha256":
+		return sha256.New()
+	}
+	return nil
+}
+
+func parseByteRate(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	value = strings.TrimSuffix(value, "/S")
+	value = strings.TrimSuffix(value, "B")
+
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(value, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(value, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(value, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		value = value[:len(value)-1]
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid rate '%s' (use e.g. 500K or 2M)", value)
+	}
+
+	return int64(n * float64(multiplier)), nil
+}
+
+func (l *rateLimiter) wait(n int) {
+	if l == nil || l.rate <= 0 || n <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	delay := time.Until(l.next)
+	l.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.limiter.wait(n)
+	return n, err
+}
+
+func enclosureFileName(entryID int64, rawURL string) string {
+	name := ""
+	if u, err := url.Parse(rawURL); err == nil {
+		name = path.Base(u.Path)
+	}
+	name = strings.Trim(unsafeFileChars.ReplaceAllString(name, "_"), "._")
+	if name == "" {
+		name = "enclosure"
+	}
+	return fmt.Sprintf("%d-%s", entryID, name)
+}
+
+func buildDownloadQueue(entries *EntryStore, dir string, names map[string]bool, limit int) []downloadJob {
+	matching := entries.list(func(entry Entry) bool {
+		return len(entry.Enclosures) > 0 && (len(names) == 0 || names[entry.Site])
+	})
+	sort.Slice(matching, func(i, j int) bool {
+		return matching[i].Published.After(matching[j].Published)
+	})
+
+	var jobs []downloadJob
+	for _, entry := range matching {
+		siteDir := strings.Trim(unsafeFileChars.ReplaceAllString(entry.Site, "_"), "._")
+		for i, enclosure := range entry.Enclosures {
+			if enclosure.Downloaded != "" || enclosure.URL == "" {
+				continue
+			}
+			if limit > 0 && len(jobs) >= limit {
+				return jobs
+			}
+			jobs = append(jobs, downloadJob{
+				entryID:   entry.ID,
+				index:     i,
+				site:      entry.Site,
+				title:     entryDisplayTitle(entry),
+				enclosure: enclosure,
+				path:      filepath.Join(dir, siteDir, enclosureFileName(entry.ID, enclosure.URL)),
+			})
+		}
+	}
+
+	return jobs
+}
+
+func downloadEnclosure(client *http.Client, job downloadJob, limiter *rateLimiter, verify bool) error {
+	if err := os.MkdirAll(filepath.Dir(job.path), 0755); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+
+	partPath := job.path + PARTIAL_SUFFIX
+	var offset int64
+	if info, err := os.Stat(partPath); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, job.enclosure.URL, nil)
+	if err != nil {
+		return fmt.Errorf("URL fetch error: %w", err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		if start, _, ok := parseContentRange(resp.Header.Get("Content-Range")); !ok || start != offset {
+			return restartDownload(client, job, limiter, verify, resp, partPath)
+		}
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The partial file already holds the whole enclosure, if the server
+		// says it is that size; otherwise it is left from something else.
+		if _, total, ok := parseContentRange(resp.Header.Get("Content-Range")); !ok || total != offset {
+			return restartDownload(client, job, limiter, verify, resp, partPath)
+		}
+		return finishDownload(job, partPath, verify)
+	case resp.StatusCode == http.StatusOK:
+		flags |= os.O_TRUNC
+	default:
+		return fmt.Errorf("HTTP status: %s", resp.Status)
+	}
+
+	file, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening file: %w", err)
+	}
+
+	_, err = io.Copy(file, &throttledReader{r: resp.Body, limiter: limiter})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("download interrupted (will resume): %w", err)
+	}
+
+	return finishDownload(job, partPath, verify)
+}
+
+// restartDownload throws away a partial file the server's answer doesn't
+// fit, and downloads the enclosure again from the start.
+func restartDownload(client *http.Client, job downloadJob, limiter *rateLimiter, verify bool, resp *http.Response, partPath string) error {
+	resp.Body.Close()
+	if err := os.Remove(partPath); err != nil {
+		return fmt.Errorf("error removing partial download: %w", err)
+	}
+	return downloadEnclosure(client, job, limiter, verify)
+}
+
+// parseContentRange reads a Content-Range header, "bytes 100-199/1000" or
+// "bytes */1000", returning where the range starts (-1 for "*") and the
+// full length (-1 when "*").
+func parseContentRange(header string) (start, total int64, ok bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	byteRange, length, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+
+	total = -1
+	if length != "*" {
+		n, err := strconv.ParseInt(length, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		total = n
+	}
+
+	if byteRange == "*" {
+		return -1, total, true
+	}
+	first, _, found := strings.Cut(byteRange, "-")
+	n, err := strconv.ParseInt(first, 10, 64)
+	if !found || err != nil || n < 0 {
+		return 0, 0, false
+	}
+	return n, total, true
+}
+
+func finishDownload(job downloadJob, partPath string, verify bool) error {
+	if verify && job.enclosure.Checksum != "" {
+		if err := verifyChecksum(partPath, job.enclosure.Checksum); err != nil {
+			os.Remove(partPath)
+			return err
+		}
+	}
+
+	if err := os.Rename(partPath, job.path); err != nil {
+		return fmt.Errorf("error saving file: %w", err)
+	}
+	return nil
+}
+
+func verifyChecksum(filePath, checksum string) error {
+	algo, expected, _ := strings.Cut(checksum, ":")
+	h := newChecksumHash(algo)
+	if h == nil {
+		return fmt.Errorf("unsupported checksum algorithm '%s'", algo)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(h, file); err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != expected {
+		return fmt.Errorf("%s checksum mismatch: expected %s, got %s", algo, expected, actual)
+	}
+	return nil
+}
+
+func runDownload(sites SiteData, config Config, args []string) error {
+	downloads := DownloadConfig{}
+	if config.Downloads != nil {
+		downloads = *config.Downloads
+	}
+	if downloads.Dir == "" {
+		downloads.Dir = DEFAULT_DOWNLOAD_DIR
+	}
+	if downloads.Concurrency <= 0 {
+		downloads.Concurrency = DEFAULT_DOWNLOAD_CONCURRENCY
+	}
+
+	fs := flag.NewFlagSet("download", flag.ExitOnError)
+	dir := fs.String("dir", downloads.Dir, "Directory to save enclosures in.")
+	concurrency := fs.Int("concurrency", downloads.Concurrency, "Maximum number of simultaneous downloads.")
+	rate := fs.String("rate", downloads.RateLimit, "Total bandwidth cap, e.g. 500K or 2M (bytes per second).")
+	verify := fs.Bool("verify", downloads.Verify, "Verify checksums published in the feed (media:hash).")
+	limit := fs.Int("limit", 0, "Only download the N most recent pending enclosures.")
+	queries := parseInterspersed(fs, args)
+
+	if *concurrency <= 0 {
+		return fmt.Errorf("-concurrency must be positive")
+	}
+
+	limiter := &rateLimiter{}
+	if *rate != "" {
+		bytesPerSecond, err := parseByteRate(*rate)
+		if err != nil {
+			return err
+		}
+		limiter.rate = bytesPerSecond
+	}
+
+	names := make(map[string]bool)
+	reader := bufio.NewReader(os.Stdin)
+	for _, query := range queries {
+		name, err := resolveSiteName(sites, query, reader)
+		if err != nil {
+			return err
+		}
+		names[name] = true
+	}
+
+	entries, err := readEntries()
+	if err != nil {
+		return err
+	}
+
+	jobs := buildDownloadQueue(entries, *dir, names, *limit)
+	if len(jobs) == 0 {
+		fmt.Println("No pending enclosures to download")
+		return nil
+	}
+
+	fmt.Printf("Downloading %d enclosures to %s (concurrency: %d)...\n\n", len(jobs), *dir, *concurrency)
+
+	transport := feedTransport("").Clone()
+	transport.ResponseHeaderTimeout = httpTimeout
+	client := newFeedClient(0, "")
+	client.Transport = transport
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan struct{}, *concurrency)
+	downloaded, failed := 0, 0
+
+	for _, job := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(job downloadJob) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := downloadEnclosure(client, job, limiter, *verify)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed++
+				fmt.Printf("%s → ERROR: %s: %v\n", job.site, job.title, err)
+				return
+			}
+
+			downloaded++
+			entries.update(func(entry Entry) bool {
+				return entry.ID == job.entryID
+			}, func(entry *Entry) {
+				entry.Enclosures[job.index].Downloaded = job.path
+			})
+			fmt.Printf("%s → %s\n   ✓ %s\n", job.site, job.title, job.path)
+		}(job)
+	}
+	wg.Wait()
+
+	if downloaded > 0 {
+		if err := entries.save(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\nDownloaded %d enclosures", downloaded)
+	if failed > 0 {
+		fmt.Printf(", %d failed (re-run to resume)", failed)
+	}
+	fmt.Println()
+
+	return nil
+}
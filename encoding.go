@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	ACCEPT_ENCODING = "gzip, deflate, br"
+	// Servers behind misconfigured CDNs sometimes compress twice.
+	MAX_DECODE_PASSES = 3
+)
+
+// decodeFeedBody reads a feed response and undoes its content encoding. The
+// Content-Encoding header is only a hint: what the body actually starts with
+// decides, since some servers label plain bodies as compressed and the other
+// way round. The decompressed size is capped like any other feed body.
+func decodeFeedBody(r io.Reader, contentEncoding string) ([]byte, error) {
+	body, err := readFeedBody(r)
+	if err != nil {
+		return nil, err
+	}
+
+	declared := strings.ToLower(strings.TrimSpace(contentEncoding))
+	for pass := 0; pass < MAX_DECODE_PASSES; pass++ {
+		var decoder io.Reader
+		var name string
+
+		switch {
+		case isGzip(body):
+			reader, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return nil, fmt.Errorf("decompressing gzip body: %w", err)
+			}
+			decoder, name = reader, "gzip"
+		case looksLikeText(body):
+			return body, nil
+		case isZlib(body):
+			reader, err := zlib.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return nil, fmt.Errorf("decompressing deflate body: %w", err)
+			}
+			decoder, name = reader, "deflate"
+		case pass == 0 && declared == "deflate":
+			// Raw DEFLATE without the zlib wrapper, which some servers send.
+			decoder, name = flate.NewReader(bytes.NewReader(body)), "deflate"
+		case pass == 0 && declared == "br":
+			decoder, name = brotli.NewReader(bytes.NewReader(body)), "brotli"
+		default:
+			return body, nil
+		}
+
+		decoded, err := readFeedBody(decoder)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing %s body: %w", name, err)
+		}
+		body = decoded
+	}
+	return body, nil
+}
+
+func isGzip(body []byte) bool {
+	return len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b
+}
+
+// isZlib checks for a zlib header: deflate method, and a check value that
+// makes the first two bytes a multiple of 31.
+func isZlib(body []byte) bool {
+	return len(body) > 2 && body[0]&0x0f == 8 && body[0]>>4 <= 7 && (uint16(body[0])<<8|uint16(body[1]))%31 == 0
+}
+
+// looksLikeText reports whether a body already reads as a feed or page,
+// whatever its Content-Encoding claims.
+func looksLikeText(body []byte) bool {
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")), " \t\r\n")
+	return len(trimmed) > 0 && (trimmed[0] == '<' || trimmed[0] == '{')
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const ENTRIES_FILE = "entries.json"
+
+// MAX_PRUNED_KEYS is how many keys of pruned, deleted or skipped entries are
+// kept. Past it the oldest are forgotten; by then they have long left their
+// feeds, so they won't be seen again.
+const MAX_PRUNED_KEYS = 10000
+
+type Entry struct {
+	ID         int64       `json:"id"`
+	Ref        string      `json:"ref,omitempty"`
+	Site       string      `json:"site"`
+	GUID       string      `json:"guid,omitempty"`
+	Title      string      `json:"title"`
+	Link       string      `json:"link"`
+	Content    string      `json:"content,omitempty"`
+	Published  time.Time   `json:"published"`
+	Discovered time.Time   `json:"discovered"`
+	Read       bool        `json:"read,omitempty"`
+	Saved      bool        `json:"saved,omitempty"`
+	Backfill   bool        `json:"backfill,omitempty"`
+	Enclosures []Enclosure `json:"enclosures,omitempty"`
+	Categories []string    `json:"categories,omitempty"`
+	Tags       []string    `json:"tags,omitempty"`
+	Note       string      `json:"note,omitempty"`
+	ExportedAt *time.Time  `json:"exported_at,omitempty"`
+	Author     string      `json:"author,omitempty"`
+	Language   string      `json:"language,omitempty"`
+	// Score is the sum of the score actions of the rules it matched.
+	Score int `json:"score,omitempty"`
+}
+
+type EntryStore struct {
+	mu      sync.RWMutex
+	NextID  int64    `json:"next_id"`
+	Entries []Entry  `json:"entries"`
+	Pruned  []string `json:"pruned,omitempty"`
+	seen    map[string]bool
+	refs    map[string]bool
+	store   Store
+}
+
+func entryKey(siteName, guid, link string) string {
+	if guid != "" {
+		return siteName + "\x00" + guid
+	}
+	return siteName + "\x00" + link
+}
+
+func readEntries() (*EntryStore, error) {
+	return openStore(databaseFile, entriesFile).LoadEntries()
+}
+
+func newEntryStore(store Store) *EntryStore {
+	return &EntryStore{NextID: 1, store: store}
+}
+
+func (s *EntryStore) index() {
+	s.seen = make(map[string]bool, len(s.Entries))
+	s.refs = make(map[string]bool, len(s.Entries))
+	for _, entry := range s.Entries {
+		s.seen[entryKey(entry.Site, entry.GUID, entry.Link)] = true
+		if entry.Ref != "" {
+			s.refs[entry.Ref] = true
+		}
+	}
+	// Entries stored before references existed get theirs now.
+	for i := range s.Entries {
+		s.assignRef(&s.Entries[i])
+	}
+	for _, key := range s.Pruned {
+		s.seen[key] = true
+	}
+}
+
+func (s *EntryStore) has(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.seen[key]
+}
+
+// markSeen keeps an entry that was never recorded from being reported later.
+func (s *EntryStore) markSeen(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.seen[key] {
+		s.seen[key] = true
+		s.addPruned(key)
+	}
+}
+
+// addPruned remembers the key of an entry that isn't stored, so it isn't
+// recorded again, forgetting the oldest past MAX_PRUNED_KEYS. The caller holds
+// the lock.
+func (s *EntryStore) addPruned(key string) {
+	s.Pruned = append(s.Pruned, key)
+	if over := len(s.Pruned) - MAX_PRUNED_KEYS; over > 0 {
+		for _, old := range s.Pruned[:over] {
+			delete(s.seen, old)
+		}
+		s.Pruned = append([]string(nil), s.Pruned[over:]...)
+	}
+}
+
+func (s *EntryStore) save() error {
+	return saveOrRecover("entries", s.marshalIndent, func() error { return s.store.SaveEntries(s) })
+}
+
+func (s *EntryStore) record(siteName string, feedEntries []FeedEntry, markRead bool) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var added []Entry
+
+	for i := len(feedEntries) - 1; i >= 0; i-- {
+		feedEntry := feedEntries[i]
+		if feedEntry.Link == "" && feedEntry.ID == "" {
+			continue
+		}
+
+		key := entryKey(siteName, feedEntry.ID, feedEntry.Link)
+		if s.seen[key] {
+			continue
+		}
+		s.seen[key] = true
+
+		entry := Entry{
+			ID:         s.NextID,
+			Site:       siteName,
+			GUID:       feedEntry.ID,
+			Title:      feedEntry.Title,
+			Link:       feedEntry.Link,
+			Content:    feedEntry.Content,
+			Published:  feedEntry.Published,
+			Enclosures: feedEntry.Enclosures,
+			Categories: feedEntry.Categories,
+			Author:     feedEntry.Author,
+			Language:   feedEntry.Language,
+			Discovered: now,
+			Read:       markRead,
+			Backfill:   markRead,
+		}
+		s.assignRef(&entry)
+		s.Entries = append(s.Entries, entry)
+		added = append(added, entry)
+		s.NextID++
+	}
+
+	return added
+}
+
+func (s *EntryStore) list(filter func(Entry) bool) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []Entry
+	for _, entry := range s.Entries {
+		if filter == nil || filter(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func (s *EntryStore) update(filter func(Entry) bool, change func(*Entry)) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := 0
+	for i := range s.Entries {
+		if filter(s.Entries[i]) {
+			change(&s.Entries[i])
+			changed++
+		}
+	}
+	return changed
+}
@@ -0,0 +1,38 @@
+package main
+
+const DEFAULT_MAX_NEW_ENTRIES = 20
+
+// newEntryCap returns how many new entries of a site are reported per run,
+// or 0 for no limit. A site's max_new_entries overrides the config, and a
+// negative value disables the cap.
+func (c Config) newEntryCap(site Site) int {
+	limit := site.MaxNewEntries
+	if limit == 0 {
+		limit = c.MaxNewEntries
+	}
+	if limit == 0 {
+		limit = DEFAULT_MAX_NEW_ENTRIES
+	}
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// capNewEntries keeps the newest limit entries for reporting. The rest stay
+// recorded but are marked read, so a feed that resets its GUIDs does not
+// flood notifications or the unread list.
+func capNewEntries(newEntries []Entry, limit int, entries *EntryStore) ([]Entry, int) {
+	if limit <= 0 || len(newEntries) <= limit {
+		return newEntries, 0
+	}
+
+	excess := newEntries[:len(newEntries)-limit]
+	ids := make(map[int64]bool, len(excess))
+	for _, entry := range excess {
+		ids[entry.ID] = true
+	}
+	entries.update(func(e Entry) bool { return ids[e.ID] }, func(e *Entry) { e.Read = true })
+
+	return newEntries[len(newEntries)-limit:], len(excess)
+}
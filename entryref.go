@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	ENTRY_REF_LENGTH     = 7
+	ENTRY_REF_MAX_LENGTH = 12
+)
+
+// entryRef derives a short base36 reference from what identifies an entry,
+// so the same entry gets the same reference in every store. A longer one is
+// used if the short one is taken.
+func entryRef(key string, taken map[string]bool) string {
+	sum := sha256.Sum256([]byte(key))
+	value := binary.BigEndian.Uint64(sum[:8])
+
+	for length := ENTRY_REF_LENGTH; length <= ENTRY_REF_MAX_LENGTH; length++ {
+		ref := strconv.FormatUint(value, 36)
+		if len(ref) < length {
+			ref = strings.Repeat("0", length-len(ref)) + ref
+		}
+		ref = ref[len(ref)-length:]
+		if !taken[ref] {
+			return ref
+		}
+	}
+	return strconv.FormatUint(value, 36) + strconv.Itoa(len(taken))
+}
+
+// assignRef gives an entry its reference if it has none yet. The caller
+// holds s.mu.
+func (s *EntryStore) assignRef(entry *Entry) {
+	if entry.Ref == "" {
+		entry.Ref = entryRef(entryKey(entry.Site, entry.GUID, entry.Link), s.refs)
+	}
+	s.refs[entry.Ref] = true
+}
+
+// entryLabel is how an entry is referred to in command output.
+func entryLabel(entry Entry) string {
+	if entry.Ref == "" {
+		return fmt.Sprintf("#%d", entry.ID)
+	}
+	return "#" + entry.Ref
+}
+
+func markEntries(args []string, read bool) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: read <ref|id|link>... | unread <ref|id|link>...")
+	}
+
+	entries, err := readEntries()
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range args {
+		entry, err := findEntry(entries, ref)
+		if err != nil {
+			return err
+		}
+		entries.update(func(e Entry) bool { return e.ID == entry.ID }, func(e *Entry) { e.Read = read })
+
+		marker := "○"
+		if read {
+			marker = "✓"
+		}
+		fmt.Printf("%s %s %s → %s\n", marker, entryLabel(entry), entry.Site, entryDisplayTitle(entry))
+	}
+
+	return entries.save()
+}
+
+// openEntry opens an entry in the browser and marks it read.
+func openEntry(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: open <ref|id|link>")
+	}
+
+	entries, err := readEntries()
+	if err != nil {
+		return err
+	}
+	entry, err := findEntry(entries, args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := openInBrowser(entry.Link); err != nil {
+		return fmt.Errorf("opening %s: %w", entry.Link, err)
+	}
+	entries.update(func(e Entry) bool { return e.ID == entry.ID }, func(e *Entry) { e.Read = true })
+	if err := entries.save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("→ %s %s → %s\n   %s\n", entryLabel(entry), entry.Site, entryDisplayTitle(entry), entry.Link)
+	return nil
+}
+
+// handleEntry serves /api/entries/<ref>: GET returns the entry, POST with
+// {"read": true} or {"starred": true} changes it.
+func (s *Server) handleEntry(w http.ResponseWriter, r *http.Request) {
+	ref := strings.TrimPrefix(r.URL.Path, "/api/entries/")
+	matches := s.entries.list(func(e Entry) bool { return e.Ref == ref })
+	if ref == "" || len(matches) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no such entry"})
+		return
+	}
+	entry := matches[0]
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, entry)
+
+	case http.MethodPost:
+		var change struct {
+			Read    *bool `json:"read"`
+			Starred *bool `json:"starred"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+
+		s.entries.update(func(e Entry) bool { return e.ID == entry.ID }, func(e *Entry) {
+			if change.Read != nil {
+				e.Read = *change.Read
+			}
+			if change.Starred != nil {
+				e.Saved = *change.Starred
+			}
+			entry = *e
+		})
+		if err := s.entries.save(); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, entry)
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const EXEC_PREFIX = "exec:"
+
+func commandFeedURL(command []string) string {
+	return EXEC_PREFIX + strings.Join(command, " ")
+}
+
+// runFeedCommand runs a site's command and returns its stdout as the feed
+// body. Anything written to stderr is included in the error on failure.
+func runFeedCommand(command []string, timeout time.Duration) ([]byte, error) {
+	if len(command) == 0 || command[0] == "" {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("timeout exceeded after %v", timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("command failed: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("command failed: %w", err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func fetchCommandFeed(site Site, feedURL string, timeout time.Duration, span *Span) *FeedResult {
+	run := startSpan(span, "exec")
+	run.set("exec.command", site.Command[0])
+	start := time.Now()
+	body, err := runFeedCommand(site.Command, timeout)
+	run.fail(err)
+	run.finish()
+	if err != nil {
+		return &FeedResult{Error: err}
+	}
+
+	return parseFetchedFeed(site, feedURL, body, time.Since(start), span)
+}
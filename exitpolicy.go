@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	EXIT_BEST_EFFORT = "best-effort"
+	EXIT_STRICT      = "strict"
+	// Exit code of a strict check in which some sites failed, so CI can tell
+	// it apart from the tracker itself failing (1).
+	EXIT_SITES_FAILED = 2
+)
+
+func validateExitPolicy(policy string) error {
+	switch policy {
+	case "", EXIT_BEST_EFFORT, EXIT_STRICT:
+		return nil
+	}
+	return fmt.Errorf("check_exit must be \"%s\" or \"%s\", got '%s'", EXIT_BEST_EFFORT, EXIT_STRICT, policy)
+}
+
+// sitesFailedError fails a strict check once every site has been checked and
+// the state saved.
+type sitesFailedError struct {
+	sites []string
+	// reported is set when the output already says which sites failed and
+	// mustn't have anything added to it, like the JSON of check -stdin.
+	reported bool
+}
+
+func (e *sitesFailedError) Error() string {
+	if len(e.sites) == 1 {
+		return fmt.Sprintf("1 site failed: %s", e.sites[0])
+	}
+	return fmt.Sprintf("%d sites failed: %s", len(e.sites), strings.Join(e.sites, ", "))
+}
+
+// exitPolicy picks between the -strict and -best-effort flags and the
+// check_exit setting.
+func exitPolicy(config Config, strict, bestEffort bool) (string, error) {
+	switch {
+	case strict && bestEffort:
+		return "", fmt.Errorf("-strict and -best-effort cannot be used together")
+	case strict:
+		return EXIT_STRICT, nil
+	case bestEffort:
+		return EXIT_BEST_EFFORT, nil
+	case config.CheckExit != "":
+		return config.CheckExit, nil
+	}
+	return EXIT_BEST_EFFORT, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// originAllowed rejects cross-site pages, which would otherwise ride on
+// credentials the browser attaches by itself (basic auth). Origins listed in
+// cors_origins, such as a browser extension, are let through.
+func originAllowed(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || corsOriginAllowed(origin, allowed) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func corsOriginAllowed(origin string, allowed []string) bool {
+	for _, candidate := range allowed {
+		if strings.EqualFold(strings.TrimSuffix(candidate, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowCORS answers preflight requests and adds CORS headers to /api/
+// responses for the configured origins. Credentials are sent as headers, so
+// cookies are never allowed.
+func allowCORS(next http.Handler, origins []string) http.Handler {
+	if len(origins) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if !strings.HasPrefix(r.URL.Path, "/api/") || origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if !corsOriginAllowed(origin, origins) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// siteForPage finds the subscribed site a page belongs to: one whose feed is
+// the page itself, or whose homepage contains it. The most specific homepage
+// wins.
+func siteForPage(sites SiteData, pageURL string) (string, bool) {
+	page, err := url.Parse(pageURL)
+	if err != nil || page.Host == "" {
+		return "", false
+	}
+	pageHost := strings.TrimPrefix(strings.ToLower(page.Hostname()), "www.")
+
+	best, bestLength := "", -1
+	for name, site := range sites {
+		if site.RSSUrl == pageURL {
+			return name, true
+		}
+		home, err := url.Parse(site.SiteURL)
+		if err != nil || home.Host == "" {
+			continue
+		}
+		if strings.TrimPrefix(strings.ToLower(home.Hostname()), "www.") != pageHost {
+			continue
+		}
+		prefix := strings.TrimSuffix(home.Path, "/")
+		if prefix != "" && page.Path != prefix && !strings.HasPrefix(page.Path, prefix+"/") {
+			continue
+		}
+		if len(prefix) > bestLength || (len(prefix) == bestLength && name < best) {
+			best, bestLength = name, len(prefix)
+		}
+	}
+	return best, best != ""
+}
+
+// handlePageLookup serves GET /api/page?url=, telling an extension whether
+// the page it shows is subscribed. With discover=true, the feeds of a page
+// that is not are looked up as well.
+func (s *Server) handlePageLookup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	pageURL := r.URL.Query().Get("url")
+	if pageURL == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing url"})
+		return
+	}
+
+	s.mu.RLock()
+	name, subscribed := siteForPage(s.sites.Snapshot(), pageURL)
+	feedURL := ""
+	if site, ok := s.sites.Get(name); subscribed && ok {
+		feedURL = s.feedURL(site)
+	}
+	s.mu.RUnlock()
+
+	if subscribed {
+		unread := len(s.entries.list(func(e Entry) bool { return !e.Read && e.Site == name }))
+		writeJSON(w, http.StatusOK, map[string]any{"subscribed": true, "site": name, "url": feedURL, "unread": unread})
+		return
+	}
+
+	response := map[string]any{"subscribed": false}
+	if discover, _, _ := parseAPIBool(r.URL.Query(), "discover"); discover {
+		feeds := []map[string]string{}
+		if found, err := discoverFeeds(pageURL); err == nil {
+			for _, feed := range found {
+				// The page may be a feed, or link to one, followed under another homepage.
+				if existing := s.subscribedAs(feed.URL); existing != "" {
+					writeJSON(w, http.StatusOK, map[string]any{"subscribed": true, "site": existing, "url": feed.URL})
+					return
+				}
+				feeds = append(feeds, map[string]string{"url": feed.URL, "title": feed.Title, "type": feedTypeString(feed.FeedType)})
+			}
+		}
+		response["feeds"] = feeds
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleUnreadCount serves GET /api/unread, small enough to poll for a badge.
+func (s *Server) handleUnreadCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	unread := len(s.entries.list(func(e Entry) bool { return !e.Read }))
+	writeJSON(w, http.StatusOK, map[string]int{"unread": unread})
+}
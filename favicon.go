@@ -0,0 +1,302 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	FAVICON_REFRESH  = 7 * 24 * time.Hour
+	FAVICON_RETRY    = 24 * time.Hour
+	FAVICON_MAX_SIZE = 512 << 10
+)
+
+// Favicon is a cached site icon. A failed lookup is cached without data so
+// the site is not retried until FAVICON_RETRY has passed.
+type Favicon struct {
+	URL         string    `json:"url,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	FetchedAt   time.Time `json:"fetched_at"`
+	Data        []byte    `json:"data,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+func (f *Favicon) stale() bool {
+	maxAge := FAVICON_REFRESH
+	if len(f.Data) == 0 {
+		maxAge = FAVICON_RETRY
+	}
+	return time.Since(f.FetchedAt) > maxAge
+}
+
+func (f *Favicon) dataURI() string {
+	if f == nil || len(f.Data) == 0 {
+		return ""
+	}
+	return "data:" + f.ContentType + ";base64," + base64.StdEncoding.EncodeToString(f.Data)
+}
+
+// faviconHost returns the host a site's icon is cached under, taken from the
+// homepage link of its feed.
+func faviconHost(site Site) string {
+	u, err := url.Parse(site.SiteURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func faviconCachePath(host string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(host))
+	return filepath.Join(dir, "rss-tracker", "favicons", hex.EncodeToString(sum[:])+".json"), nil
+}
+
+// cachedFavicon returns the cached icon for a site without any network access.
+func cachedFavicon(site Site) *Favicon {
+	host := faviconHost(site)
+	if host == "" {
+		return nil
+	}
+	path, err := faviconCachePath(host)
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var favicon Favicon
+	if json.Unmarshal(data, &favicon) != nil {
+		return nil
+	}
+	return &favicon
+}
+
+func writeFaviconCache(host string, favicon Favicon) error {
+	path, err := faviconCachePath(host)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(favicon)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// loadFavicon returns a site's icon, fetching it when it is missing from the
+// cache or stale (or always, with force).
+func loadFavicon(site Site, force bool) (*Favicon, error) {
+	host := faviconHost(site)
+	if host == "" {
+		return nil, fmt.Errorf("no homepage link known")
+	}
+
+	cached := cachedFavicon(site)
+	if cached != nil && !force && !cached.stale() {
+		return cached, nil
+	}
+
+	favicon := Favicon{FetchedAt: time.Now()}
+	iconURL, contentType, data, err := fetchFavicon(site.SiteURL)
+	if err != nil {
+		// Keep serving a previously fetched icon when a refresh fails.
+		if cached != nil && len(cached.Data) > 0 {
+			return cached, err
+		}
+		favicon.Error = err.Error()
+	} else {
+		favicon.URL, favicon.ContentType, favicon.Data = iconURL, contentType, data
+	}
+
+	if werr := writeFaviconCache(host, favicon); werr != nil && err == nil {
+		err = fmt.Errorf("error writing favicon cache: %w", werr)
+	}
+	return &favicon, err
+}
+
+// findIconLink returns the icon declared in a page's <head>, preferring
+// rel="icon" over apple-touch-icon.
+func findIconLink(body []byte, base *url.URL) string {
+	decoder := newHTMLDecoder(body)
+	var touchIcon string
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		name := strings.ToLower(start.Name.Local)
+		if name == "body" {
+			break
+		}
+		if name != "link" {
+			continue
+		}
+
+		var rel, href string
+		for _, attr := range start.Attr {
+			switch strings.ToLower(attr.Name.Local) {
+			case "rel":
+				rel = " " + strings.ToLower(attr.Value) + " "
+			case "href":
+				href = strings.TrimSpace(attr.Value)
+			}
+		}
+		if href == "" || strings.HasPrefix(href, "data:") {
+			continue
+		}
+		resolved, err := base.Parse(href)
+		if err != nil {
+			continue
+		}
+
+		if strings.Contains(rel, " icon ") {
+			return resolved.String()
+		}
+		if strings.Contains(rel, " apple-touch-icon ") && touchIcon == "" {
+			touchIcon = resolved.String()
+		}
+	}
+
+	return touchIcon
+}
+
+func fetchFavicon(siteURL string) (string, string, []byte, error) {
+	client := newFeedClient(httpTimeout, "")
+
+	var candidates []string
+	if finalURL, body, err := fetchForDiscovery(client, siteURL); err == nil {
+		if icon := findIconLink(body, finalURL); icon != "" {
+			candidates = append(candidates, icon)
+		}
+	}
+	base, err := url.Parse(siteURL)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("invalid site URL: %w", err)
+	}
+	fallback := base.ResolveReference(&url.URL{Path: "/favicon.ico"}).String()
+	if len(candidates) == 0 || candidates[0] != fallback {
+		candidates = append(candidates, fallback)
+	}
+
+	var lastErr error
+	for _, iconURL := range candidates {
+		contentType, data, err := fetchIcon(client, iconURL)
+		if err == nil {
+			return iconURL, contentType, data, nil
+		}
+		lastErr = err
+	}
+	return "", "", nil, lastErr
+}
+
+func fetchIcon(client *http.Client, iconURL string) (string, []byte, error) {
+	resp, err := client.Get(iconURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("HTTP error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("%s: HTTP status: %s", iconURL, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, FAVICON_MAX_SIZE+1))
+	if err != nil {
+		return "", nil, fmt.Errorf("read error: %w", err)
+	}
+	if len(data) == 0 || len(data) > FAVICON_MAX_SIZE {
+		return "", nil, fmt.Errorf("%s: icon is empty or larger than %s", iconURL, formatBytes(FAVICON_MAX_SIZE))
+	}
+
+	contentType := strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return "", nil, fmt.Errorf("%s: not an image (%s)", iconURL, contentType)
+	}
+	return contentType, data, nil
+}
+
+// refreshFavicons fetches missing and stale icons, one per homepage host.
+func refreshFavicons(sites SiteData, force bool) map[string]error {
+	results := make(map[string]error)
+	done := make(map[string]bool)
+
+	names := make([]string, 0, len(sites))
+	for name := range sites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		site := sites[name]
+		host := faviconHost(site)
+		if host == "" || done[host] {
+			continue
+		}
+		done[host] = true
+
+		_, err := loadFavicon(site, force)
+		results[name] = err
+	}
+	return results
+}
+
+func runFavicons(sites SiteData, args []string) error {
+	fs := flag.NewFlagSet("favicons", flag.ExitOnError)
+	force := fs.Bool("refresh", false, "Fetch every icon again, even when the cached one is fresh.")
+	parseInterspersed(fs, args)
+
+	results := refreshFavicons(sites, *force)
+	if len(results) == 0 {
+		fmt.Println("No sites with a known homepage yet (run check first)")
+		return nil
+	}
+
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := results[name]; err != nil {
+			fmt.Printf("%s → ERROR: %v\n", name, err)
+			continue
+		}
+		favicon := cachedFavicon(sites[name])
+		if favicon == nil || len(favicon.Data) == 0 {
+			fmt.Printf("(-_-) %s (no icon found)\n", name)
+			continue
+		}
+		fmt.Printf("✓ %s → %s (%s)\n", name, favicon.URL, formatBytes(int64(len(favicon.Data))))
+	}
+	return nil
+}
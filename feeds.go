@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Entries in an output feed, newest first.
+const OUTPUT_FEED_ENTRIES = 50
+
+type outputFeed struct {
+	XMLName xml.Name          `xml:"rss"`
+	Version string            `xml:"version,attr"`
+	Channel outputFeedChannel `xml:"channel"`
+}
+
+type outputFeedChannel struct {
+	Title         string           `xml:"title"`
+	Link          string           `xml:"link"`
+	Description   string           `xml:"description"`
+	LastBuildDate string           `xml:"lastBuildDate"`
+	Items         []outputFeedItem `xml:"item"`
+}
+
+type outputFeedItem struct {
+	Title       string         `xml:"title"`
+	Link        string         `xml:"link"`
+	GUID        outputFeedGUID `xml:"guid"`
+	PubDate     string         `xml:"pubDate,omitempty"`
+	Author      string         `xml:"author,omitempty"`
+	Category    string         `xml:"category"`
+	Description string         `xml:"description,omitempty"`
+}
+
+type outputFeedGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// writeEntryFeed writes entries as an RSS feed, keeping the newest
+// OUTPUT_FEED_ENTRIES. The entries are expected newest first.
+func writeEntryFeed(w http.ResponseWriter, r *http.Request, title, description string, entries []Entry) {
+	if len(entries) > OUTPUT_FEED_ENTRIES {
+		entries = entries[:OUTPUT_FEED_ENTRIES]
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	feed := outputFeed{
+		Version: "2.0",
+		Channel: outputFeedChannel{
+			Title:         title,
+			Link:          scheme + "://" + r.Host + r.URL.Path,
+			Description:   description,
+			LastBuildDate: time.Now().Format(time.RFC1123Z),
+		},
+	}
+	for _, entry := range entries {
+		item := outputFeedItem{
+			Title:       entryDisplayTitle(entry),
+			Link:        entry.Link,
+			GUID:        outputFeedGUID{Value: entry.Site + ":" + entry.Ref},
+			Author:      entry.Author,
+			Category:    entry.Site,
+			Description: entry.Content,
+		}
+		if !entry.Published.IsZero() {
+			item.PubDate = entry.Published.Format(time.RFC1123Z)
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	fmt.Fprintf(w, "%s%s\n", xml.Header, data)
+}
+
+// newestEntries lists the stored entries of some sites, newest first.
+func (s *Server) newestEntries(include func(siteName string) bool) []Entry {
+	entries := s.entries.list(func(e Entry) bool { return include(e.Site) })
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].ID > entries[j].ID })
+	return entries
+}
+
+// handleAggregateFeed serves /feeds.xml, the entries of every site.
+func (s *Server) handleAggregateFeed(w http.ResponseWriter, r *http.Request) {
+	writeEntryFeed(w, r, "RSS Tracker", "New entries from every tracked site", s.newestEntries(func(string) bool { return true }))
+}
+
+// handleTagFeed serves /feeds/<tag>.xml, the entries of the sites with a
+// tag. Nested tags are included, so /feeds/work.xml has work/security too.
+func (s *Server) handleTagFeed(w http.ResponseWriter, r *http.Request) {
+	tag := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/feeds/"), ".xml")
+
+	members := make(map[string]bool)
+	s.mu.RLock()
+	for name, site := range s.sites.Snapshot() {
+		for _, siteTag := range site.Tags {
+			if siteTag == tag || strings.HasPrefix(siteTag, tag+"/") {
+				members[name] = true
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	if tag == "" || len(members) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("no site is tagged '%s'", tag)})
+		return
+	}
+
+	writeEntryFeed(w, r, "RSS Tracker: "+tag, fmt.Sprintf("New entries from sites tagged %s", tag), s.newestEntries(func(name string) bool { return members[name] }))
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	FEED_TYPE_AUTO = "auto"
+	FEED_TYPE_RSS  = "rss"
+	FEED_TYPE_ATOM = "atom"
+)
+
+// parseFeedTypeName reads the feed_type and detected_type of a site.
+func parseFeedTypeName(name string) (FeedType, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", FEED_TYPE_AUTO:
+		return FeedTypeUnknown, nil
+	case FEED_TYPE_RSS:
+		return FeedTypeRSS, nil
+	case FEED_TYPE_ATOM:
+		return FeedTypeAtom, nil
+	}
+	return FeedTypeUnknown, fmt.Errorf("feed_type must be \"%s\", \"%s\" or \"%s\", got '%s'", FEED_TYPE_AUTO, FEED_TYPE_RSS, FEED_TYPE_ATOM, name)
+}
+
+// feedTypeName is the name a detected feed type is recorded under, if it is
+// one that can be forced.
+func feedTypeName(feedType FeedType) string {
+	switch feedType {
+	case FeedTypeRSS:
+		return FEED_TYPE_RSS
+	case FeedTypeAtom:
+		return FEED_TYPE_ATOM
+	}
+	return ""
+}
+
+// parseSiteFeed parses a feed as the site's feed_type when it has one.
+// Otherwise the type is detected, and if that fails or finds no entries, the
+// type detected on an earlier check is tried.
+func parseSiteFeed(site Site, body []byte) (*FeedResult, error) {
+	forced, err := parseFeedTypeName(site.FeedType)
+	if err != nil {
+		return nil, err
+	}
+	if forced != FeedTypeUnknown {
+		return parseFeedAs(body, forced)
+	}
+
+	result, err := parseFeed(body)
+	if err == nil && len(result.Entries) > 0 {
+		return result, nil
+	}
+	if detected, _ := parseFeedTypeName(site.DetectedType); detected != FeedTypeUnknown && detected != detectFeedType(body) {
+		if retried, retryErr := parseFeedAs(body, detected); retryErr == nil && len(retried.Entries) > 0 {
+			return retried, nil
+		}
+	}
+	return result, err
+}
+
+func setFeedType(sites SiteData, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: feed-type <site> <auto|rss|atom>")
+	}
+	forced, err := parseFeedTypeName(args[1])
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	name, err := resolveSiteName(sites, args[0], reader)
+	if err != nil {
+		return err
+	}
+
+	site := sites[name]
+	site.FeedType = feedTypeName(forced)
+	sites[name] = site
+	if err := saveSites(sites); err != nil {
+		return fmt.Errorf("saving sites: %w", err)
+	}
+
+	if forced == FeedTypeUnknown {
+		fmt.Printf("✓ The feed type of '%s' is detected again\n", name)
+		return nil
+	}
+	fmt.Printf("✓ '%s' is now always parsed as %s\n", name, feedTypeString(forced))
+	return nil
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FEVER_API_VERSION = 3
+	FEVER_PAGE_SIZE   = 50
+)
+
+type FeverConfig struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type feverFeed struct {
+	ID                int64  `json:"id"`
+	FaviconID         int64  `json:"favicon_id"`
+	Title             string `json:"title"`
+	URL               string `json:"url"`
+	SiteURL           string `json:"site_url"`
+	IsSpark           int    `json:"is_spark"`
+	LastUpdatedOnTime int64  `json:"last_updated_on_time"`
+}
+
+type feverFavicon struct {
+	ID   int64  `json:"id"`
+	Data string `json:"data"`
+}
+
+type feverGroup struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+type feverFeedsGroup struct {
+	GroupID int64  `json:"group_id"`
+	FeedIDs string `json:"feed_ids"`
+}
+
+type feverItem struct {
+	ID            int64  `json:"id"`
+	FeedID        int64  `json:"feed_id"`
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	HTML          string `json:"html"`
+	URL           string `json:"url"`
+	IsSaved       int    `json:"is_saved"`
+	IsRead        int    `json:"is_read"`
+	CreatedOnTime int64  `json:"created_on_time"`
+}
+
+func (c *FeverConfig) apiKey() string {
+	sum := md5.Sum([]byte(c.Email + ":" + c.Password))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Server) feverAuthorized(apiKey string) bool {
+	if s.config.Fever == nil || apiKey == "" {
+		return false
+	}
+	expected := s.config.Fever.apiKey()
+	return subtle.ConstantTimeCompare([]byte(strings.ToLower(apiKey)), []byte(expected)) == 1
+}
+
+func (s *Server) handleFever(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if !r.Form.Has("api") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing api parameter"})
+		return
+	}
+
+	response := map[string]any{
+		"api_version": FEVER_API_VERSION,
+		"auth":        0,
+	}
+
+	if !s.feverAuthorized(r.PostFormValue("api_key")) {
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+	response["auth"] = 1
+
+	if r.PostForm.Has("mark") {
+		if err := s.feverMark(r.PostForm); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		switch r.PostFormValue("as") {
+		case "read", "unread":
+			response["unread_item_ids"] = s.feverItemIDs(func(e Entry) bool { return !e.Read })
+		case "saved", "unsaved":
+			response["saved_item_ids"] = s.feverItemIDs(func(e Entry) bool { return e.Saved })
+		}
+	}
+
+	s.mu.RLock()
+	response["last_refreshed_on_time"] = s.lastRefreshed.Unix()
+
+	if r.Form.Has("groups") {
+		response["groups"] = s.feverGroups()
+		response["feeds_groups"] = s.feverFeedsGroups()
+	}
+	if r.Form.Has("feeds") {
+		response["feeds"] = s.feverFeeds()
+		response["feeds_groups"] = s.feverFeedsGroups()
+	}
+	if r.Form.Has("favicons") {
+		response["favicons"] = s.feverFavicons()
+	}
+	s.mu.RUnlock()
+
+	if r.Form.Has("links") {
+		response["links"] = []any{}
+	}
+	if r.Form.Has("items") {
+		items, total := s.feverItems(r.Form)
+		response["items"] = items
+		response["total_items"] = total
+	}
+	if r.Form.Has("unread_item_ids") {
+		response["unread_item_ids"] = s.feverItemIDs(func(e Entry) bool { return !e.Read })
+	}
+	if r.Form.Has("saved_item_ids") {
+		response["saved_item_ids"] = s.feverItemIDs(func(e Entry) bool { return e.Saved })
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) feverFavicons() []feverFavicon {
+	seen := make(map[int64]bool)
+	favicons := []feverFavicon{}
+
+	for _, site := range s.sites.Snapshot() {
+		favicon := cachedFavicon(site)
+		if favicon == nil || len(favicon.Data) == 0 {
+			continue
+		}
+		id := siteID(faviconHost(site))
+		if !seen[id] {
+			seen[id] = true
+			favicons = append(favicons, feverFavicon{ID: id, Data: strings.TrimPrefix(favicon.dataURI(), "data:")})
+		}
+	}
+
+	sort.Slice(favicons, func(i, j int) bool { return favicons[i].ID < favicons[j].ID })
+	return favicons
+}
+
+func (s *Server) feverGroups() []feverGroup {
+	seen := make(map[string]bool)
+	groups := []feverGroup{}
+
+	for _, site := range s.sites.Snapshot() {
+		for _, tag := range site.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				groups = append(groups, feverGroup{ID: siteID(tag), Title: tag})
+			}
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Title < groups[j].Title })
+	return groups
+}
+
+func (s *Server) feverFeedsGroups() []feverFeedsGroup {
+	members := make(map[string][]string)
+	for name, site := range s.sites.Snapshot() {
+		for _, tag := range site.Tags {
+			members[tag] = append(members[tag], strconv.FormatInt(siteID(name), 10))
+		}
+	}
+
+	feedsGroups := []feverFeedsGroup{}
+	for tag, ids := range members {
+		sort.Strings(ids)
+		feedsGroups = append(feedsGroups, feverFeedsGroup{
+			GroupID: siteID(tag),
+			FeedIDs: strings.Join(ids, ","),
+		})
+	}
+
+	sort.Slice(feedsGroups, func(i, j int) bool { return feedsGroups[i].GroupID < feedsGroups[j].GroupID })
+	return feedsGroups
+}
+
+func (s *Server) feverFeeds() []feverFeed {
+	lastUpdated := make(map[string]int64)
+	for _, entry := range s.entries.list(nil) {
+		if t := entry.Discovered.Unix(); t > lastUpdated[entry.Site] {
+			lastUpdated[entry.Site] = t
+		}
+	}
+
+	feeds := []feverFeed{}
+	for name, site := range s.sites.Snapshot() {
+		var faviconID int64
+		if favicon := cachedFavicon(site); favicon != nil && len(favicon.Data) > 0 {
+			faviconID = siteID(faviconHost(site))
+		}
+		feeds = append(feeds, feverFeed{
+			ID:                siteID(name),
+			FaviconID:         faviconID,
+			Title:             name,
+			URL:               s.feedURL(site),
+			SiteURL:           site.SiteURL,
+			LastUpdatedOnTime: lastUpdated[name],
+		})
+	}
+
+	sort.Slice(feeds, func(i, j int) bool { return feeds[i].Title < feeds[j].Title })
+	return feeds
+}
+
+func (s *Server) feverItems(form url.Values) ([]feverItem, int) {
+	all := s.entries.list(nil)
+	var selected []Entry
+
+	switch {
+	case form.Get("with_ids") != "":
+		wanted := make(map[int64]bool)
+		for _, id := range strings.Split(form.Get("with_ids"), ",") {
+			if n, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64); err == nil {
+				wanted[n] = true
+			}
+		}
+		for _, entry := range all {
+			if wanted[entry.ID] && len(selected) < FEVER_PAGE_SIZE {
+				selected = append(selected, entry)
+			}
+		}
+
+	case form.Get("max_id") != "":
+		maxID, _ := strconv.ParseInt(form.Get("max_id"), 10, 64)
+		for i := len(all) - 1; i >= 0 && len(selected) < FEVER_PAGE_SIZE; i-- {
+			if all[i].ID < maxID {
+				selected = append(selected, all[i])
+			}
+		}
+
+	default:
+		sinceID, _ := strconv.ParseInt(form.Get("since_id"), 10, 64)
+		for _, entry := range all {
+			if entry.ID > sinceID && len(selected) < FEVER_PAGE_SIZE {
+				selected = append(selected, entry)
+			}
+		}
+	}
+
+	items := []feverItem{}
+	for _, entry := range selected {
+		created := entry.Published
+		if created.IsZero() {
+			created = entry.Discovered
+		}
+		items = append(items, feverItem{
+			ID:            entry.ID,
+			FeedID:        siteID(entry.Site),
+			Title:         entry.Title,
+			Author:        entry.Author,
+			HTML:          entry.Content,
+			URL:           entry.Link,
+			IsSaved:       boolToInt(entry.Saved),
+			IsRead:        boolToInt(entry.Read),
+			CreatedOnTime: created.Unix(),
+		})
+	}
+
+	return items, len(all)
+}
+
+func (s *Server) feverItemIDs(filter func(Entry) bool) string {
+	var ids []string
+	for _, entry := range s.entries.list(filter) {
+		ids = append(ids, strconv.FormatInt(entry.ID, 10))
+	}
+	return strings.Join(ids, ",")
+}
+
+func (s *Server) feverMark(form url.Values) error {
+	id, err := strconv.ParseInt(form.Get("id"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+
+	var change func(*Entry)
+	switch form.Get("as") {
+	case "read":
+		change = func(e *Entry) { e.Read = true }
+	case "unread":
+		change = func(e *Entry) { e.Read = false }
+	case "saved":
+		change = func(e *Entry) { e.Saved = true }
+	case "unsaved":
+		change = func(e *Entry) { e.Saved = false }
+	default:
+		return fmt.Errorf("unsupported mark action '%s'", form.Get("as"))
+	}
+
+	before := time.Now()
+	if form.Get("before") != "" {
+		seconds, err := strconv.ParseInt(form.Get("before"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid before: %w", err)
+		}
+		before = time.Unix(seconds, 0)
+	}
+
+	var filter func(Entry) bool
+	switch form.Get("mark") {
+	case "item":
+		filter = func(e Entry) bool { return e.ID == id }
+	case "feed":
+		filter = func(e Entry) bool { return siteID(e.Site) == id && !e.Discovered.After(before) }
+	case "group":
+		s.mu.RLock()
+		members := make(map[string]bool)
+		for name, site := range s.sites.Snapshot() {
+			for _, tag := range site.Tags {
+				if id == 0 || siteID(tag) == id {
+					members[name] = true
+				}
+			}
+		}
+		s.mu.RUnlock()
+		filter = func(e Entry) bool {
+			return (id == 0 || members[e.Site]) && !e.Discovered.After(before)
+		}
+	default:
+		return fmt.Errorf("unsupported mark target '%s'", form.Get("mark"))
+	}
+
+	if s.entries.update(filter, change) > 0 {
+		return s.entries.save()
+	}
+	return nil
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
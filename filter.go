@@ -0,0 +1,73 @@
+package main
+
+import "strings"
+
+type EntryFilter struct {
+	Keywords       []string `json:"keywords,omitempty"`
+	Exclude        []string `json:"exclude,omitempty"`
+	Authors        []string `json:"authors,omitempty"`
+	ExcludeAuthors []string `json:"exclude_authors,omitempty"`
+	Languages      []string `json:"languages,omitempty"`
+	ExcludeLangs   []string `json:"exclude_languages,omitempty"`
+}
+
+func containsAnyFold(text string, keywords []string) bool {
+	text = strings.ToLower(text)
+	for _, keyword := range keywords {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword != "" && strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *EntryFilter) allows(entry FeedEntry) bool {
+	if f == nil {
+		return true
+	}
+
+	text := entry.Title + "\n" + htmlToText(entry.Content)
+	if len(f.Keywords) > 0 && !containsAnyFold(text, f.Keywords) {
+		return false
+	}
+	if len(f.Exclude) > 0 && containsAnyFold(text, f.Exclude) {
+		return false
+	}
+	if len(f.Authors) > 0 && !containsAnyFold(entry.Author, f.Authors) {
+		return false
+	}
+	if len(f.ExcludeAuthors) > 0 && containsAnyFold(entry.Author, f.ExcludeAuthors) {
+		return false
+	}
+	// Entries whose language is unknown are kept.
+	if entry.Language != "" {
+		if len(f.Languages) > 0 && !matchesLanguage(entry.Language, f.Languages) {
+			return false
+		}
+		if matchesLanguage(entry.Language, f.ExcludeLangs) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *FeedResult) applyFilter(filter *EntryFilter) {
+	if filter == nil {
+		return
+	}
+
+	kept := r.Entries[:0]
+	for _, entry := range r.Entries {
+		if filter.allows(entry) {
+			kept = append(kept, entry)
+		}
+	}
+	r.Entries = kept
+
+	r.Title, r.LatestLink = "", ""
+	if len(r.Entries) > 0 {
+		r.Title = r.Entries[0].Title
+		r.LatestLink = r.Entries[0].Link
+	}
+}
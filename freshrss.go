@@ -0,0 +1,344 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Items whose read or starred state is sent to FreshRSS per request.
+	FRESHRSS_EDIT_BATCH = 250
+)
+
+// FreshRSSConfig points at the Google Reader API of a FreshRSS instance,
+// e.g. https://rss.example.com/api/greader.php.
+type FreshRSSConfig struct {
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type freshRSSClient struct {
+	base   string
+	auth   string
+	token  string
+	client *http.Client
+}
+
+type freshRSSSubscription struct {
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+	HTMLURL    string `json:"htmlUrl"`
+	Categories []struct {
+		ID    string `json:"id"`
+		Label string `json:"label"`
+	} `json:"categories"`
+}
+
+func loginFreshRSS(cfg *FreshRSSConfig) (*freshRSSClient, error) {
+	c := &freshRSSClient{
+		base:   strings.TrimRight(cfg.URL, "/"),
+		client: &http.Client{Timeout: httpTimeout},
+	}
+
+	resp, err := c.client.PostForm(c.base+"/accounts/ClientLogin", url.Values{
+		"Email":  {cfg.Username},
+		"Passwd": {cfg.Password},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("logging in: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("logging in: unexpected status: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("logging in: %w", err)
+	}
+	for _, line := range strings.Split(string(body), "\n") {
+		if auth, ok := strings.CutPrefix(strings.TrimSpace(line), "Auth="); ok {
+			c.auth = auth
+		}
+	}
+	if c.auth == "" {
+		return nil, fmt.Errorf("logging in: no Auth token in the response")
+	}
+	return c, nil
+}
+
+func (c *freshRSSClient) do(req *http.Request) ([]byte, error) {
+	req.Header.Set("Authorization", "GoogleLogin auth="+c.auth)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status: %s", req.URL.Path, resp.Status)
+	}
+	return body, nil
+}
+
+func (c *freshRSSClient) get(path string, query url.Values, out any) error {
+	query.Set("output", "json")
+	req, err := http.NewRequest(http.MethodGet, c.base+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	body, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("%s: error parsing response: %w", path, err)
+	}
+	return nil
+}
+
+// post sends a change, with the write token the API asks for.
+func (c *freshRSSClient) post(path string, form url.Values) error {
+	if c.token == "" {
+		req, err := http.NewRequest(http.MethodGet, c.base+"/reader/api/0/token", nil)
+		if err != nil {
+			return err
+		}
+		token, err := c.do(req)
+		if err != nil {
+			return err
+		}
+		c.token = strings.TrimSpace(string(token))
+	}
+
+	form.Set("T", c.token)
+	req, err := http.NewRequest(http.MethodPost, c.base+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = c.do(req)
+	return err
+}
+
+func syncFreshRSS(sites SiteData, config Config) error {
+	if config.FreshRSS == nil || config.FreshRSS.URL == "" {
+		return fmt.Errorf("no FreshRSS instance is configured (set \"freshrss\" in config.json)")
+	}
+
+	client, err := loginFreshRSS(config.FreshRSS)
+	if err != nil {
+		return err
+	}
+
+	if err := syncFreshRSSSubscriptions(client, sites, config); err != nil {
+		return fmt.Errorf("syncing subscriptions: %w", err)
+	}
+
+	entries, err := readEntries()
+	if err != nil {
+		return err
+	}
+	if err := syncFreshRSSState(client, entries); err != nil {
+		return fmt.Errorf("syncing read state: %w", err)
+	}
+
+	fmt.Printf("✓ Synced with %s\n", client.base)
+	return nil
+}
+
+// syncFreshRSSSubscriptions tracks the feeds only FreshRSS has and subscribes
+// FreshRSS to the ones only the tracker has. Folders and tags are the same
+// thing on both sides.
+func syncFreshRSSSubscriptions(client *freshRSSClient, sites SiteData, config Config) error {
+	var list struct {
+		Subscriptions []freshRSSSubscription `json:"subscriptions"`
+	}
+	if err := client.get("/reader/api/0/subscription/list", url.Values{}, &list); err != nil {
+		return err
+	}
+
+	remote := make(map[string]bool, len(list.Subscriptions))
+	var feeds []importedFeed
+	for _, sub := range list.Subscriptions {
+		remote[sub.URL] = true
+		feed := importedFeed{Title: sub.Title, URL: sub.URL, SiteURL: sub.HTMLURL}
+		if len(sub.Categories) == 0 {
+			feeds = append(feeds, feed)
+		}
+		for _, category := range sub.Categories {
+			feed.Tag = category.Label
+			if feed.Tag == "" {
+				feed.Tag = strings.TrimPrefix(category.ID, GREADER_LABEL_PREFIX)
+			}
+			feeds = append(feeds, feed)
+		}
+	}
+
+	names := make([]string, 0, len(sites))
+	for name := range sites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pushed := 0
+	for _, name := range names {
+		site := sites[name]
+		feedURL, err := resolveFeedURL(site, config)
+		if err != nil || remote[feedURL] ||
+			!strings.HasPrefix(feedURL, "http://") && !strings.HasPrefix(feedURL, "https://") {
+			continue
+		}
+
+		form := url.Values{
+			"ac": {"subscribe"},
+			"s":  {GREADER_FEED_PREFIX + feedURL},
+			"t":  {name},
+		}
+		// A feed is in one folder on FreshRSS, so it gets the first tag.
+		if len(site.Tags) > 0 {
+			form.Set("a", GREADER_LABEL_PREFIX+site.Tags[0])
+		}
+		if err := client.post("/reader/api/0/subscription/edit", form); err != nil {
+			fmt.Printf("(-_-) Could not subscribe FreshRSS to '%s': %v\n", name, err)
+			continue
+		}
+		pushed++
+	}
+
+	added, updated := addImportedFeeds(sites, feeds)
+	if added > 0 || updated > 0 {
+		if err := saveSites(sites); err != nil {
+			return fmt.Errorf("saving sites: %w", err)
+		}
+	}
+
+	fmt.Printf("→ Subscriptions: %d added here, %d added to FreshRSS\n", added, pushed)
+	return nil
+}
+
+// syncFreshRSSState matches stored entries with FreshRSS items by link. An
+// entry read or starred on either side becomes read or starred on both;
+// marking unread and unstarring are not carried over.
+func syncFreshRSSState(client *freshRSSClient, entries *EntryStore) error {
+	stored := entries.list(func(Entry) bool { return true })
+	if len(stored) == 0 {
+		fmt.Println("→ No entries stored yet, skipping read state")
+		return nil
+	}
+
+	byLink := make(map[string][]Entry)
+	oldest := stored[0].Discovered
+	for _, entry := range stored {
+		byLink[entry.Link] = append(byLink[entry.Link], entry)
+		if entry.Discovered.Before(oldest) {
+			oldest = entry.Discovered
+		}
+	}
+
+	readHere := make(map[string]bool)
+	starHere := make(map[string]bool)
+	var readThere, starThere []string
+
+	query := url.Values{
+		"n":  {strconv.Itoa(GREADER_MAX_N)},
+		"ot": {strconv.FormatInt(oldest.Unix(), 10)},
+	}
+	for {
+		var page struct {
+			Items        []greaderItem `json:"items"`
+			Continuation string        `json:"continuation"`
+		}
+		if err := client.get("/reader/api/0/stream/contents/"+GREADER_READING_LIST, query, &page); err != nil {
+			return err
+		}
+
+		for _, item := range page.Items {
+			link := ""
+			if len(item.Canonical) > 0 {
+				link = item.Canonical[0].Href
+			} else if len(item.Alternate) > 0 {
+				link = item.Alternate[0].Href
+			}
+			local, ok := byLink[link]
+			if link == "" || !ok {
+				continue
+			}
+
+			remoteRead, remoteStarred := false, false
+			for _, category := range item.Categories {
+				switch {
+				case strings.HasSuffix(category, "/state/com.google/read"):
+					remoteRead = true
+				case strings.HasSuffix(category, "/state/com.google/starred"):
+					remoteStarred = true
+				}
+			}
+
+			localRead, localStarred := false, false
+			for _, entry := range local {
+				localRead = localRead || entry.Read
+				localStarred = localStarred || entry.Saved
+			}
+
+			switch {
+			case remoteRead && !localRead:
+				readHere[link] = true
+			case localRead && !remoteRead:
+				readThere = append(readThere, item.ID)
+			}
+			switch {
+			case remoteStarred && !localStarred:
+				starHere[link] = true
+			case localStarred && !remoteStarred:
+				starThere = append(starThere, item.ID)
+			}
+		}
+
+		if page.Continuation == "" || len(page.Items) == 0 {
+			break
+		}
+		query.Set("c", page.Continuation)
+	}
+
+	if err := editFreshRSSItems(client, readThere, GREADER_READ); err != nil {
+		return err
+	}
+	if err := editFreshRSSItems(client, starThere, GREADER_STARRED); err != nil {
+		return err
+	}
+
+	read := entries.update(func(e Entry) bool { return readHere[e.Link] && !e.Read }, func(e *Entry) { e.Read = true })
+	starred := entries.update(func(e Entry) bool { return starHere[e.Link] && !e.Saved }, func(e *Entry) { e.Saved = true })
+	if read > 0 || starred > 0 {
+		if err := entries.save(); err != nil {
+			return fmt.Errorf("saving entries: %w", err)
+		}
+	}
+
+	fmt.Printf("→ Read: %d marked here, %d on FreshRSS\n", read, len(readThere))
+	fmt.Printf("→ Starred: %d marked here, %d on FreshRSS\n", starred, len(starThere))
+	return nil
+}
+
+func editFreshRSSItems(client *freshRSSClient, ids []string, tag string) error {
+	for start := 0; start < len(ids); start += FRESHRSS_EDIT_BATCH {
+		end := min(start+FRESHRSS_EDIT_BATCH, len(ids))
+		form := url.Values{"i": ids[start:end], "a": {tag}}
+		if err := client.post("/reader/api/0/edit-tag", form); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	GEMINI_HOSTS_FILE    = "gemini_hosts.json"
+	GEMINI_DEFAULT_PORT  = "1965"
+	GEMINI_MAX_REDIRECTS = 5
+)
+
+// GeminiHost is a certificate pinned on first use (TOFU). A different
+// certificate is only accepted once the pinned one has expired.
+type GeminiHost struct {
+	Fingerprint string    `json:"fingerprint"`
+	Expires     time.Time `json:"expires"`
+}
+
+var geminiHostsMu sync.Mutex
+
+func isGeminiSource(feedURL string) bool {
+	return strings.HasPrefix(feedURL, "gemini://")
+}
+
+func geminiHostsPath() string {
+	return filepath.Join(filepath.Dir(configFile), GEMINI_HOSTS_FILE)
+}
+
+func readGeminiHosts() (map[string]GeminiHost, error) {
+	hosts := make(map[string]GeminiHost)
+	data, err := os.ReadFile(geminiHostsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return hosts, nil
+		}
+		return nil, fmt.Errorf("error reading known Gemini hosts: %w", err)
+	}
+	if err := json.Unmarshal(data, &hosts); err != nil {
+		return nil, fmt.Errorf("error parsing known Gemini hosts: %w", err)
+	}
+	return hosts, nil
+}
+
+func writeGeminiHosts(hosts map[string]GeminiHost) error {
+	data, err := json.MarshalIndent(hosts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling known Gemini hosts: %w", err)
+	}
+	return os.WriteFile(geminiHostsPath(), data, 0644)
+}
+
+func verifyGeminiCert(host string, cert *x509.Certificate) error {
+	sum := sha256.Sum256(cert.Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	geminiHostsMu.Lock()
+	defer geminiHostsMu.Unlock()
+
+	hosts, err := readGeminiHosts()
+	if err != nil {
+		return err
+	}
+
+	known, ok := hosts[host]
+	if ok && known.Fingerprint == fingerprint {
+		return nil
+	}
+	if ok && time.Now().Before(known.Expires) {
+		return fmt.Errorf("certificate for %s changed (expected %s, got %s); remove it from %s if the change is expected",
+			host, known.Fingerprint[:16], fingerprint[:16], geminiHostsPath())
+	}
+
+	hosts[host] = GeminiHost{Fingerprint: fingerprint, Expires: cert.NotAfter}
+	return writeGeminiHosts(hosts)
+}
+
+func geminiRequest(target *url.URL, timeout time.Duration) (status int, meta string, body []byte, err error) {
+	host := target.Hostname()
+	addr := target.Host
+	if target.Port() == "" {
+		addr = net.JoinHostPort(host, GEMINI_DEFAULT_PORT)
+	}
+
+	// Gemini servers mostly use self-signed certificates, so chain
+	// verification is replaced by pinning the certificate per host.
+	tlsConfig := &tls.Config{
+		ServerName:         host,
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return fmt.Errorf("server sent no certificate")
+			}
+			return verifyGeminiCert(strings.ToLower(host), state.PeerCertificates[0])
+		},
+	}
+
+	conn, err := tls.DialWithDialer(newFeedDialer(timeout), "tcp", dnsAddress(addr), tlsConfig)
+	if err != nil {
+		return 0, "", nil, fmt.Errorf("connecting to server: %w", explainNetError(err))
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := fmt.Fprintf(conn, "%s\r\n", target.String()); err != nil {
+		return 0, "", nil, fmt.Errorf("sending request: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	header, err := reader.ReadString('\n')
+	if err != nil {
+		return 0, "", nil, fmt.Errorf("reading response header: %w", err)
+	}
+	code, meta, _ := strings.Cut(strings.TrimRight(header, "\r\n"), " ")
+	status, err = strconv.Atoi(code)
+	if err != nil || len(code) != 2 {
+		return 0, "", nil, fmt.Errorf("invalid response header '%s'", strings.TrimSpace(header))
+	}
+
+	if status/10 == 2 {
+		body, err = readFeedBody(reader)
+		if err != nil {
+			return 0, "", nil, fmt.Errorf("error reading response: %w", err)
+		}
+	}
+	return status, strings.TrimSpace(meta), body, nil
+}
+
+// fetchGemini requests a gemini:// URL, following redirects, and returns the
+// final URL, the MIME type and the body.
+func fetchGemini(feedURL string, timeout time.Duration) (string, string, []byte, error) {
+	target, err := url.Parse(feedURL)
+	if err != nil || target.Host == "" {
+		return "", "", nil, fmt.Errorf("invalid Gemini URL '%s'", feedURL)
+	}
+
+	for redirects := 0; ; redirects++ {
+		status, meta, body, err := geminiRequest(target, timeout)
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		switch status / 10 {
+		case 2:
+			if meta == "" {
+				meta = "text/gemini"
+			}
+			return target.String(), meta, body, nil
+		case 3:
+			if redirects >= GEMINI_MAX_REDIRECTS {
+				return "", "", nil, fmt.Errorf("stopped after %d redirects", GEMINI_MAX_REDIRECTS)
+			}
+			next, err := target.Parse(meta)
+			if err != nil || next.Scheme != "gemini" {
+				return "", "", nil, fmt.Errorf("refusing redirect to '%s'", meta)
+			}
+			target = next
+		case 1:
+			return "", "", nil, fmt.Errorf("server asks for input (%d %s)", status, meta)
+		case 6:
+			return "", "", nil, fmt.Errorf("server requires a client certificate (%d %s)", status, meta)
+		default:
+			return "", "", nil, fmt.Errorf("unexpected status %d %s", status, meta)
+		}
+	}
+}
+
+func fetchGeminiFeed(site Site, feedURL string, timeout time.Duration, span *Span) *FeedResult {
+	fetch := startSpan(span, "fetch")
+	fetch.setClient()
+	start := time.Now()
+	finalURL, mimeType, body, err := fetchGemini(feedURL, timeout)
+	if err != nil {
+		if strings.Contains(err.Error(), "timeout") {
+			err = fmt.Errorf("timeout exceeded after %v", timeout)
+		} else {
+			err = fmt.Errorf("Gemini fetch error: %w", err)
+		}
+		fetch.fail(err)
+		fetch.finish()
+		return &FeedResult{Error: err}
+	}
+	fetch.set("gemini.mime_type", mimeType)
+	fetch.set("body_bytes", len(body))
+	fetch.finish()
+
+	return parseFetchedFeed(site, finalURL, body, time.Since(start), span)
+}
+
+// parseGemfeed reads a gemtext page following the gemfeed convention: the
+// first heading is the feed title and every link line starting with a
+// YYYY-MM-DD date is an entry.
+func parseGemfeed(pageURL string, body []byte) (*FeedResult, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page URL: %w", err)
+	}
+
+	result := &FeedResult{FeedType: FeedTypeGemfeed, SiteURL: pageURL}
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		if strings.HasPrefix(line, "# ") && result.FeedTitle == "" {
+			result.FeedTitle = strings.TrimSpace(line[2:])
+			continue
+		}
+		if !strings.HasPrefix(line, "=>") {
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(line, "=>"))
+		if len(fields) < 2 || len(fields[1]) < 10 {
+			continue
+		}
+		published, err := time.Parse("2006-01-02", fields[1][:10])
+		if err != nil {
+			continue
+		}
+		link, err := base.Parse(fields[0])
+		if err != nil {
+			continue
+		}
+
+		title := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.Join(fields[1:], " ")[10:]), "-"))
+		if title == "" {
+			title = link.String()
+		}
+		result.Entries = append(result.Entries, FeedEntry{
+			Title:     title,
+			Link:      link.String(),
+			Published: published,
+		})
+	}
+
+	if len(result.Entries) == 0 {
+		return nil, fmt.Errorf("no dated links found (not a gemfeed)")
+	}
+
+	// Gemfeeds are usually newest first, but only by convention.
+	sort.SliceStable(result.Entries, func(i, j int) bool {
+		return result.Entries[i].Published.After(result.Entries[j].Published)
+	})
+	result.Title = result.Entries[0].Title
+	result.LatestLink = result.Entries[0].Link
+	return result, nil
+}
@@ -1,3 +1,16 @@
 module github.com/ahmed-hany94/RSS-Tracker
 
 go 1.22.0
+
+require (
+	github.com/andybalholm/brotli v1.2.0
+	github.com/lib/pq v1.10.9
+	go.etcd.io/bbolt v1.3.11
+	golang.org/x/crypto v0.31.0
+)
+
+require (
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
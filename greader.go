@@ -0,0 +1,516 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	GREADER_ITEM_PREFIX  = "tag:google.com,2005:reader/item/"
+	GREADER_READING_LIST = "user/-/state/com.google/reading-list"
+	GREADER_READ         = "user/-/state/com.google/read"
+	GREADER_STARRED      = "user/-/state/com.google/starred"
+	GREADER_LABEL_PREFIX = "user/-/label/"
+	GREADER_FEED_PREFIX  = "feed/"
+	GREADER_DEFAULT_N    = 20
+	GREADER_MAX_N        = 1000
+)
+
+type GReaderConfig struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type greaderLink struct {
+	Href string `json:"href"`
+	Type string `json:"type,omitempty"`
+}
+
+type greaderOrigin struct {
+	StreamID string `json:"streamId"`
+	Title    string `json:"title"`
+	HTMLURL  string `json:"htmlUrl"`
+}
+
+type greaderItem struct {
+	ID            string            `json:"id"`
+	CrawlTimeMsec string            `json:"crawlTimeMsec"`
+	TimestampUsec string            `json:"timestampUsec"`
+	Published     int64             `json:"published"`
+	Updated       int64             `json:"updated"`
+	Title         string            `json:"title"`
+	Canonical     []greaderLink     `json:"canonical"`
+	Alternate     []greaderLink     `json:"alternate"`
+	Summary       map[string]string `json:"summary"`
+	Categories    []string          `json:"categories"`
+	Origin        greaderOrigin     `json:"origin"`
+	Author        string            `json:"author,omitempty"`
+}
+
+func (c *GReaderConfig) authToken() string {
+	sum := sha256.Sum256([]byte("greader:" + c.Username + ":" + c.Password))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Server) registerGReader(mux *http.ServeMux) {
+	mux.HandleFunc("/accounts/ClientLogin", s.handleGReaderLogin)
+	mux.HandleFunc("/reader/api/0/token", s.greaderAuth(s.handleGReaderToken))
+	mux.HandleFunc("/reader/api/0/user-info", s.greaderAuth(s.handleGReaderUserInfo))
+	mux.HandleFunc("/reader/api/0/subscription/list", s.greaderAuth(s.handleGReaderSubscriptions))
+	mux.HandleFunc("/reader/api/0/tag/list", s.greaderAuth(s.handleGReaderTags))
+	mux.HandleFunc("/reader/api/0/unread-count", s.greaderAuth(s.handleGReaderUnreadCount))
+	mux.HandleFunc("/reader/api/0/stream/contents/", s.greaderAuth(s.handleGReaderStreamContents))
+	mux.HandleFunc("/reader/api/0/stream/items/ids", s.greaderAuth(s.handleGReaderItemIDs))
+	mux.HandleFunc("/reader/api/0/stream/items/contents", s.greaderAuth(s.handleGReaderItemContents))
+	mux.HandleFunc("/reader/api/0/edit-tag", s.greaderAuth(s.handleGReaderEditTag))
+	mux.HandleFunc("/reader/api/0/mark-all-as-read", s.greaderAuth(s.handleGReaderMarkAllAsRead))
+}
+
+func (s *Server) handleGReaderLogin(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cfg := s.config.GReader
+	if cfg == nil ||
+		subtle.ConstantTimeCompare([]byte(r.Form.Get("Email")), []byte(cfg.Username)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(r.Form.Get("Passwd")), []byte(cfg.Password)) != 1 {
+		http.Error(w, "Error=BadAuthentication", http.StatusUnauthorized)
+		return
+	}
+
+	token := cfg.authToken()
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintf(w, "SID=%s\nLSID=%s\nAuth=%s\n", token, token, token)
+}
+
+func (s *Server) greaderAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.config.GReader
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "GoogleLogin auth=")
+		if cfg == nil || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.authToken())) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleGReaderToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(w, s.config.GReader.authToken()[:57])
+}
+
+func (s *Server) handleGReaderUserInfo(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"userId":   "1",
+		"userName": s.config.GReader.Username,
+	})
+}
+
+func (s *Server) handleGReaderSubscriptions(w http.ResponseWriter, r *http.Request) {
+	type category struct {
+		ID    string `json:"id"`
+		Label string `json:"label"`
+	}
+	type subscription struct {
+		ID         string     `json:"id"`
+		Title      string     `json:"title"`
+		URL        string     `json:"url"`
+		HTMLURL    string     `json:"htmlUrl"`
+		IconURL    string     `json:"iconUrl"`
+		Categories []category `json:"categories"`
+	}
+
+	s.mu.RLock()
+	subscriptions := []subscription{}
+	for name, site := range s.sites.Snapshot() {
+		categories := []category{}
+		for _, tag := range site.Tags {
+			categories = append(categories, category{ID: GREADER_LABEL_PREFIX + tag, Label: tag})
+		}
+		subscriptions = append(subscriptions, subscription{
+			ID:         GREADER_FEED_PREFIX + s.feedURL(site),
+			Title:      name,
+			URL:        s.feedURL(site),
+			HTMLURL:    site.SiteURL,
+			Categories: categories,
+		})
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].Title < subscriptions[j].Title })
+	writeJSON(w, http.StatusOK, map[string]any{"subscriptions": subscriptions})
+}
+
+func (s *Server) handleGReaderTags(w http.ResponseWriter, r *http.Request) {
+	type tag struct {
+		ID   string `json:"id"`
+		Type string `json:"type,omitempty"`
+	}
+
+	tags := []tag{{ID: GREADER_STARRED}}
+	s.mu.RLock()
+	for _, group := range s.feverGroups() {
+		tags = append(tags, tag{ID: GREADER_LABEL_PREFIX + group.Title, Type: "folder"})
+	}
+	s.mu.RUnlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{"tags": tags})
+}
+
+func (s *Server) handleGReaderUnreadCount(w http.ResponseWriter, r *http.Request) {
+	type unreadCount struct {
+		ID                      string `json:"id"`
+		Count                   int    `json:"count"`
+		NewestItemTimestampUsec string `json:"newestItemTimestampUsec"`
+	}
+
+	s.mu.RLock()
+	feedStream := make(map[string]string, s.sites.Len())
+	for name, site := range s.sites.Snapshot() {
+		feedStream[name] = GREADER_FEED_PREFIX + s.feedURL(site)
+	}
+	s.mu.RUnlock()
+
+	counts := make(map[string]*unreadCount)
+	total := &unreadCount{ID: GREADER_READING_LIST}
+	for _, entry := range s.entries.list(func(e Entry) bool { return !e.Read }) {
+		streamID, ok := feedStream[entry.Site]
+		if !ok {
+			continue
+		}
+		count, ok := counts[streamID]
+		if !ok {
+			count = &unreadCount{ID: streamID}
+			counts[streamID] = count
+		}
+		count.Count++
+		count.NewestItemTimestampUsec = greaderTimestampUsec(entry)
+		total.Count++
+		total.NewestItemTimestampUsec = count.NewestItemTimestampUsec
+	}
+
+	unreadCounts := []unreadCount{*total}
+	for _, count := range counts {
+		unreadCounts = append(unreadCounts, *count)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"max":          GREADER_MAX_N,
+		"unreadcounts": unreadCounts,
+	})
+}
+
+func (s *Server) handleGReaderStreamContents(w http.ResponseWriter, r *http.Request) {
+	streamID := strings.TrimPrefix(r.URL.Path, "/reader/api/0/stream/contents/")
+	if streamID == "" {
+		streamID = r.Form.Get("s")
+	}
+	if streamID == "" {
+		streamID = GREADER_READING_LIST
+	}
+
+	entries, continuation := s.greaderQuery(streamID, r)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":           streamID,
+		"updated":      time.Now().Unix(),
+		"items":        s.greaderItems(entries),
+		"continuation": continuation,
+	})
+}
+
+func (s *Server) handleGReaderItemIDs(w http.ResponseWriter, r *http.Request) {
+	type itemRef struct {
+		ID              string   `json:"id"`
+		DirectStreamIDs []string `json:"directStreamIds"`
+		TimestampUsec   string   `json:"timestampUsec"`
+	}
+
+	streamID := r.Form.Get("s")
+	if streamID == "" {
+		streamID = GREADER_READING_LIST
+	}
+
+	entries, continuation := s.greaderQuery(streamID, r)
+	refs := []itemRef{}
+	for _, entry := range entries {
+		refs = append(refs, itemRef{
+			ID:              strconv.FormatInt(entry.ID, 10),
+			DirectStreamIDs: []string{},
+			TimestampUsec:   greaderTimestampUsec(entry),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"itemRefs":     refs,
+		"continuation": continuation,
+	})
+}
+
+func (s *Server) handleGReaderItemContents(w http.ResponseWriter, r *http.Request) {
+	wanted := make(map[int64]bool)
+	for _, raw := range r.Form["i"] {
+		if id, ok := parseGReaderItemID(raw); ok {
+			wanted[id] = true
+		}
+	}
+
+	entries := s.entries.list(func(e Entry) bool { return wanted[e.ID] })
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":      GREADER_READING_LIST,
+		"updated": time.Now().Unix(),
+		"items":   s.greaderItems(entries),
+	})
+}
+
+func (s *Server) handleGReaderEditTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	wanted := make(map[int64]bool)
+	for _, raw := range r.Form["i"] {
+		if id, ok := parseGReaderItemID(raw); ok {
+			wanted[id] = true
+		}
+	}
+
+	var changes []func(*Entry)
+	for _, tag := range r.Form["a"] {
+		switch tag {
+		case GREADER_READ:
+			changes = append(changes, func(e *Entry) { e.Read = true })
+		case GREADER_STARRED:
+			changes = append(changes, func(e *Entry) { e.Saved = true })
+		}
+	}
+	for _, tag := range r.Form["r"] {
+		switch tag {
+		case GREADER_READ:
+			changes = append(changes, func(e *Entry) { e.Read = false })
+		case GREADER_STARRED:
+			changes = append(changes, func(e *Entry) { e.Saved = false })
+		}
+	}
+
+	changed := s.entries.update(func(e Entry) bool { return wanted[e.ID] }, func(e *Entry) {
+		for _, change := range changes {
+			change(e)
+		}
+	})
+	if changed > 0 && len(changes) > 0 {
+		if err := s.entries.save(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(w, "OK")
+}
+
+func (s *Server) handleGReaderMarkAllAsRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	inStream := s.greaderStreamFilter(r.Form.Get("s"))
+	before := time.Now()
+	if ts := r.Form.Get("ts"); ts != "" {
+		usec, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid ts", http.StatusBadRequest)
+			return
+		}
+		before = time.UnixMicro(usec)
+	}
+
+	changed := s.entries.update(func(e Entry) bool {
+		return !e.Read && inStream(e) && !e.Discovered.After(before)
+	}, func(e *Entry) { e.Read = true })
+	if changed > 0 {
+		if err := s.entries.save(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(w, "OK")
+}
+
+func (s *Server) greaderStreamFilter(streamID string) func(Entry) bool {
+	switch {
+	case streamID == "" || streamID == GREADER_READING_LIST:
+		return func(Entry) bool { return true }
+	case streamID == GREADER_READ:
+		return func(e Entry) bool { return e.Read }
+	case streamID == GREADER_STARRED:
+		return func(e Entry) bool { return e.Saved }
+	case strings.HasPrefix(streamID, GREADER_LABEL_PREFIX):
+		tag := strings.TrimPrefix(streamID, GREADER_LABEL_PREFIX)
+		members := make(map[string]bool)
+		s.mu.RLock()
+		for name, site := range s.sites.Snapshot() {
+			for _, siteTag := range site.Tags {
+				if siteTag == tag {
+					members[name] = true
+				}
+			}
+		}
+		s.mu.RUnlock()
+		return func(e Entry) bool { return members[e.Site] }
+	case strings.HasPrefix(streamID, GREADER_FEED_PREFIX):
+		feedURL := strings.TrimPrefix(streamID, GREADER_FEED_PREFIX)
+		members := make(map[string]bool)
+		s.mu.RLock()
+		for name, site := range s.sites.Snapshot() {
+			if s.feedURL(site) == feedURL {
+				members[name] = true
+			}
+		}
+		s.mu.RUnlock()
+		return func(e Entry) bool { return members[e.Site] }
+	default:
+		return func(Entry) bool { return false }
+	}
+}
+
+func (s *Server) greaderQuery(streamID string, r *http.Request) ([]Entry, string) {
+	inStream := s.greaderStreamFilter(streamID)
+	exclude := r.Form.Get("xt")
+	include := r.Form.Get("it")
+
+	var olderThan, newerThan time.Time
+	if ot, err := strconv.ParseInt(r.Form.Get("ot"), 10, 64); err == nil {
+		newerThan = time.Unix(ot, 0)
+	}
+	if nt, err := strconv.ParseInt(r.Form.Get("nt"), 10, 64); err == nil {
+		olderThan = time.Unix(nt, 0)
+	}
+
+	entries := s.entries.list(func(e Entry) bool {
+		if !inStream(e) {
+			return false
+		}
+		if exclude == GREADER_READ && e.Read || exclude == GREADER_STARRED && e.Saved {
+			return false
+		}
+		if include == GREADER_READ && !e.Read || include == GREADER_STARRED && !e.Saved {
+			return false
+		}
+		if !newerThan.IsZero() && e.Discovered.Before(newerThan) {
+			return false
+		}
+		if !olderThan.IsZero() && e.Discovered.After(olderThan) {
+			return false
+		}
+		return true
+	})
+
+	if r.Form.Get("r") != "o" {
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+	}
+
+	n, err := strconv.Atoi(r.Form.Get("n"))
+	if err != nil || n <= 0 {
+		n = GREADER_DEFAULT_N
+	}
+	if n > GREADER_MAX_N {
+		n = GREADER_MAX_N
+	}
+
+	offset, _ := strconv.Atoi(r.Form.Get("c"))
+	if offset < 0 || offset > len(entries) {
+		offset = len(entries)
+	}
+	end := offset + n
+	if end > len(entries) {
+		end = len(entries)
+	}
+
+	continuation := ""
+	if end < len(entries) {
+		continuation = strconv.Itoa(end)
+	}
+
+	return entries[offset:end], continuation
+}
+
+func (s *Server) greaderItems(entries []Entry) []greaderItem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := []greaderItem{}
+	for _, entry := range entries {
+		site, _ := s.sites.Get(entry.Site)
+
+		published := entry.Published
+		if published.IsZero() {
+			published = entry.Discovered
+		}
+
+		categories := []string{GREADER_READING_LIST}
+		if entry.Read {
+			categories = append(categories, GREADER_READ)
+		}
+		if entry.Saved {
+			categories = append(categories, GREADER_STARRED)
+		}
+		for _, tag := range site.Tags {
+			categories = append(categories, GREADER_LABEL_PREFIX+tag)
+		}
+
+		items = append(items, greaderItem{
+			ID:            fmt.Sprintf("%s%016x", GREADER_ITEM_PREFIX, entry.ID),
+			CrawlTimeMsec: strconv.FormatInt(entry.Discovered.UnixMilli(), 10),
+			TimestampUsec: greaderTimestampUsec(entry),
+			Published:     published.Unix(),
+			Updated:       published.Unix(),
+			Title:         entry.Title,
+			Author:        entry.Author,
+			Canonical:     []greaderLink{{Href: entry.Link}},
+			Alternate:     []greaderLink{{Href: entry.Link, Type: "text/html"}},
+			Summary:       map[string]string{"content": entry.Content},
+			Categories:    categories,
+			Origin: greaderOrigin{
+				StreamID: GREADER_FEED_PREFIX + s.feedURL(site),
+				Title:    entry.Site,
+				HTMLURL:  site.SiteURL,
+			},
+		})
+	}
+
+	return items
+}
+
+func greaderTimestampUsec(entry Entry) string {
+	return strconv.FormatInt(entry.Discovered.UnixMicro(), 10)
+}
+
+func parseGReaderItemID(raw string) (int64, bool) {
+	if strings.HasPrefix(raw, GREADER_ITEM_PREFIX) {
+		id, err := strconv.ParseUint(strings.TrimPrefix(raw, GREADER_ITEM_PREFIX), 16, 64)
+		return int64(id), err == nil
+	}
+
+	id, err := strconv.ParseInt(raw, 10, 64)
+	return id, err == nil
+}
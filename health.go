@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	MAX_HEALTH_SAMPLES = 20
+	// Sites without a stale_after are judged against this.
+	DEFAULT_HEALTH_STALE_AFTER = 30 * 24 * time.Hour
+	HEALTH_FAST_P95            = time.Second
+	HEALTH_SLOW_P95            = 10 * time.Second
+)
+
+// How much of the 100 points each part of a site's health is worth.
+const (
+	HEALTH_RELIABILITY_POINTS = 40
+	HEALTH_FRESHNESS_POINTS   = 30
+	HEALTH_LATENCY_POINTS     = 20
+	HEALTH_REDIRECT_POINTS    = 10
+)
+
+type siteHealth struct {
+	SiteName string
+	Score    int
+	// Issues say what the missing points are for.
+	Issues []string
+}
+
+func (s *Site) recordCheck(failed bool) {
+	s.RecentFailures = append(s.RecentFailures, failed)
+	if len(s.RecentFailures) > MAX_HEALTH_SAMPLES {
+		s.RecentFailures = s.RecentFailures[len(s.RecentFailures)-MAX_HEALTH_SAMPLES:]
+	}
+}
+
+// permanentRedirect returns where a response ended up if every redirect on
+// the way was permanent, meaning the feed has moved and the site should be
+// updated. Temporary redirects are left alone.
+func permanentRedirect(resp *http.Response) string {
+	req := resp.Request
+	if req == nil || req.Response == nil {
+		return ""
+	}
+	for r := req; r.Response != nil; r = r.Response.Request {
+		if code := r.Response.StatusCode; code != http.StatusMovedPermanently && code != http.StatusPermanentRedirect {
+			return ""
+		}
+	}
+	return req.URL.String()
+}
+
+// healthPoints gives full points up to good, none from bad on, and a share
+// of them in between.
+func healthPoints(points int, value, good, bad float64) int {
+	switch {
+	case value <= good:
+		return points
+	case value >= bad:
+		return 0
+	}
+	return int(float64(points)*(bad-value)/(bad-good) + 0.5)
+}
+
+// computeHealth scores a site from 0 to 100 by how often its checks fail,
+// how long it has gone without publishing, how slow it answers and whether
+// its feed has moved. What isn't known yet costs nothing.
+func computeHealth(name string, site Site, config Config) siteHealth {
+	health := siteHealth{SiteName: name}
+
+	failures := 0
+	for _, failed := range site.RecentFailures {
+		if failed {
+			failures++
+		}
+	}
+	reliability := HEALTH_RELIABILITY_POINTS
+	if len(site.RecentFailures) > 0 {
+		reliability = healthPoints(HEALTH_RELIABILITY_POINTS, float64(failures)/float64(len(site.RecentFailures)), 0, 1)
+	} else if site.LastError != "" {
+		reliability = 0
+	}
+	if failures > 0 {
+		health.Issues = append(health.Issues, fmt.Sprintf("%d of the last %d checks failed", failures, len(site.RecentFailures)))
+	} else if site.LastError != "" {
+		health.Issues = append(health.Issues, "failing")
+	}
+
+	freshness := HEALTH_FRESHNESS_POINTS
+	if site.LastPublished != nil {
+		threshold := config.staleThreshold(site)
+		if threshold <= 0 {
+			threshold = DEFAULT_HEALTH_STALE_AFTER
+		}
+		age := time.Since(*site.LastPublished)
+		freshness = healthPoints(HEALTH_FRESHNESS_POINTS, float64(age), float64(threshold/2), float64(threshold*2))
+		if freshness < HEALTH_FRESHNESS_POINTS {
+			health.Issues = append(health.Issues, "nothing published for "+formatDays(age))
+		}
+	}
+
+	latency := HEALTH_LATENCY_POINTS
+	if len(site.Latencies) > 0 {
+		sorted := append([]int64(nil), site.Latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		p95 := percentile(sorted, 0.95)
+		latency = healthPoints(HEALTH_LATENCY_POINTS, float64(p95), float64(HEALTH_FAST_P95), float64(HEALTH_SLOW_P95))
+		if latency < HEALTH_LATENCY_POINTS {
+			health.Issues = append(health.Issues, fmt.Sprintf("slow (p95 %v)", p95))
+		}
+	}
+
+	redirect := HEALTH_REDIRECT_POINTS
+	if site.MovedTo != "" {
+		redirect = 0
+		health.Issues = append(health.Issues, "moved to "+site.MovedTo)
+	}
+
+	health.Score = reliability + freshness + latency + redirect
+	return health
+}
+
+func formatHealth(health siteHealth) string {
+	if len(health.Issues) == 0 {
+		return fmt.Sprintf("health %d", health.Score)
+	}
+	return fmt.Sprintf("health %d: %s", health.Score, strings.Join(health.Issues, ", "))
+}
+
+// printHealth lists the sites least healthy first, those that need looking
+// at.
+func printHealth(sites SiteData, config Config, limit int) {
+	var all []siteHealth
+	for name, site := range sites {
+		all = append(all, computeHealth(name, site, config))
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Score != all[j].Score {
+			return all[i].Score < all[j].Score
+		}
+		return all[i].SiteName < all[j].SiteName
+	})
+	if limit > 0 && len(all) > limit {
+		all = all[:limit]
+	}
+
+	for i, health := range all {
+		fmt.Printf("%d. %s → %s\n", i+1, health.SiteName, formatHealth(health))
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+const DEFAULT_HISTORY_ENTRIES = 20
+
+func runHistory(sites SiteData, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	limit := fs.Int("n", DEFAULT_HISTORY_ENTRIES, "Number of entries to show (0 for all).")
+	relative := fs.Bool("relative", false, "Show publication times relative to now (e.g. \"4h ago\").")
+	rest := parseInterspersed(fs, args)
+
+	if len(rest) != 1 {
+		return fmt.Errorf("usage: history <site> [-n 20]")
+	}
+
+	name, err := resolveSiteName(sites, rest[0], bufio.NewReader(os.Stdin))
+	if err != nil {
+		return err
+	}
+
+	entries, err := readEntries()
+	if err != nil {
+		return err
+	}
+
+	history := entries.list(func(e Entry) bool { return e.Site == name })
+	if len(history) == 0 {
+		fmt.Printf("No recorded entries for '%s'\n", name)
+		return nil
+	}
+
+	sort.SliceStable(history, func(i, j int) bool { return history[i].ID > history[j].ID })
+	total := len(history)
+	if *limit > 0 && len(history) > *limit {
+		history = history[:*limit]
+	}
+
+	fmt.Printf("%s → %d recorded entries\n\n", name, total)
+	for _, entry := range history {
+		marker := " "
+		switch {
+		case entry.Saved:
+			marker = "★"
+		case !entry.Read:
+			marker = "•"
+		}
+
+		published := entry.Published
+		if published.IsZero() {
+			published = entry.Discovered
+		}
+		fmt.Printf("%s %s %s%s%s\n   %s\n", marker, entryLabel(entry), entryDisplayTitle(entry), formatAuthor(entry.Author), formatPublished(published, *relative), entry.Link)
+		if entry.Note != "" {
+			fmt.Printf("   ✎ %s\n", entry.Note)
+		}
+	}
+
+	if len(history) < total {
+		fmt.Printf("\n... %d older entries (use -n 0 to show all)\n", total-len(history))
+	}
+	return nil
+}
+
+// prune drops entries discovered before cutoff, keeping starred and annotated
+// ones. Pruned entries stay in the seen index so they aren't recorded again.
+func (s *EntryStore) prune(cutoff time.Time, dryRun bool) map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := make(map[string]int)
+	kept := make([]Entry, 0, len(s.Entries))
+	for _, entry := range s.Entries {
+		if entry.Saved || entry.Note != "" || !entry.Discovered.Before(cutoff) {
+			kept = append(kept, entry)
+			continue
+		}
+		removed[entry.Site]++
+		if !dryRun {
+			s.addPruned(entryKey(entry.Site, entry.GUID, entry.Link))
+		}
+	}
+
+	if !dryRun {
+		s.Entries = kept
+	}
+	return removed
+}
+
+func runPrune(args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	olderThan := fs.String("older-than", "", "Remove entries discovered longer ago than this (e.g. 180d).")
+	dryRun := fs.Bool("dry-run", false, "Show what would be removed without changing anything.")
+	fs.Parse(args)
+
+	if *olderThan == "" {
+		return fmt.Errorf("usage: prune -older-than <duration> [-dry-run]")
+	}
+	age, err := parseDuration(*olderThan)
+	if err != nil {
+		return err
+	}
+	if age <= 0 {
+		return fmt.Errorf("-older-than must be positive")
+	}
+
+	entries, err := readEntries()
+	if err != nil {
+		return err
+	}
+
+	removed := entries.prune(time.Now().Add(-age), *dryRun)
+	if len(removed) == 0 {
+		fmt.Println("No entries to prune")
+		return nil
+	}
+
+	names := make([]string, 0, len(removed))
+	total := 0
+	for name, count := range removed {
+		names = append(names, name)
+		total += count
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s → %d entries\n", name, removed[name])
+	}
+
+	if *dryRun {
+		fmt.Printf("Would prune %d entries (starred and annotated entries are kept)\n", total)
+		return nil
+	}
+
+	if err := entries.save(); err != nil {
+		return fmt.Errorf("saving entries: %w", err)
+	}
+	fmt.Printf("✓ Pruned %d entries older than %s\n", total, *olderThan)
+	return nil
+}
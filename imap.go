@@ -0,0 +1,445 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	IMAP_DEFAULT_MAILBOX = "INBOX"
+	IMAP_MAX_MESSAGES    = 50
+)
+
+var (
+	imapLiteralPattern  = regexp.MustCompile(`\{(\d+)\}\r\n$`)
+	imapUIDPattern      = regexp.MustCompile(`\bUID (\d+)`)
+	imapValidityPattern = regexp.MustCompile(`\[UIDVALIDITY (\d+)\]`)
+	mailURLPattern      = regexp.MustCompile(`https?://[^\s<>"')\]]+`)
+)
+
+// MailRules selects which messages of an IMAP mailbox become entries. All
+// rules are evaluated by the server; From matches any of the addresses.
+type MailRules struct {
+	From    []string `json:"from,omitempty"`
+	Subject string   `json:"subject,omitempty"`
+}
+
+// mailCursor is how far into a mailbox a site has read: the UID of the last
+// message processed, valid while the mailbox keeps its UIDVALIDITY.
+type mailCursor struct {
+	Validity uint32 `json:"uid_validity"`
+	LastUID  uint32 `json:"last_uid"`
+}
+
+type imapResponse struct {
+	text     string
+	literals [][]byte
+}
+
+type imapConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	tag    int
+}
+
+func isIMAPSource(feedURL string) bool {
+	return strings.HasPrefix(feedURL, "imaps://") || strings.HasPrefix(feedURL, "imap://")
+}
+
+func imapQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func dialIMAP(u *url.URL, timeout time.Duration) (*imapConn, error) {
+	port := u.Port()
+	if port == "" {
+		port = "993"
+		if u.Scheme == "imap" {
+			port = "143"
+		}
+	}
+	addr := dnsAddress(net.JoinHostPort(u.Hostname(), port))
+
+	dialer := newFeedDialer(timeout)
+	var conn net.Conn
+	var err error
+	if u.Scheme == "imaps" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: u.Hostname()})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connecting to server: %w", explainNetError(err))
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	c := &imapConn{conn: conn, reader: bufio.NewReader(conn)}
+	greeting, err := c.reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected greeting from server")
+	}
+	return c, nil
+}
+
+// command sends a tagged command and collects the untagged responses,
+// reading any literals ({n} followed by n bytes) they contain.
+func (c *imapConn) command(format string, args ...any) ([]imapResponse, error) {
+	c.tag++
+	tag := fmt.Sprintf("a%d", c.tag)
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		return nil, fmt.Errorf("writing command: %w", err)
+	}
+
+	var responses []imapResponse
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("reading response: %w", err)
+		}
+
+		if strings.HasPrefix(line, tag+" ") {
+			status := strings.TrimSpace(strings.TrimPrefix(line, tag+" "))
+			if !strings.HasPrefix(status, "OK") {
+				return nil, fmt.Errorf("server replied: %s", status)
+			}
+			return responses, nil
+		}
+
+		response := imapResponse{}
+		for {
+			match := imapLiteralPattern.FindStringSubmatch(line)
+			if match == nil {
+				response.text += strings.TrimRight(line, "\r\n")
+				break
+			}
+			size, _ := strconv.Atoi(match[1])
+			literal := make([]byte, size)
+			if _, err := io.ReadFull(c.reader, literal); err != nil {
+				return nil, fmt.Errorf("reading response: %w", err)
+			}
+			response.text += strings.TrimSuffix(line, match[0])
+			response.literals = append(response.literals, literal)
+
+			if line, err = c.reader.ReadString('\n'); err != nil {
+				return nil, fmt.Errorf("reading response: %w", err)
+			}
+		}
+		responses = append(responses, response)
+	}
+}
+
+func (r *MailRules) searchCriteria() string {
+	criteria := []string{"UNSEEN"}
+	if r == nil {
+		return criteria[0]
+	}
+
+	if r.Subject != "" {
+		criteria = append(criteria, "SUBJECT "+imapQuote(r.Subject))
+	}
+	if len(r.From) > 0 {
+		from := "FROM " + imapQuote(r.From[len(r.From)-1])
+		for i := len(r.From) - 2; i >= 0; i-- {
+			from = "OR FROM " + imapQuote(r.From[i]) + " " + from
+		}
+		criteria = append(criteria, from)
+	}
+	return strings.Join(criteria, " ")
+}
+
+// fetchIMAPFeed turns unseen messages matching the site's mail rules into
+// entries. The mailbox is left as it is: the result carries how far the site
+// has read, and only once that is saved with the site are the messages
+// passed over, so a check whose state isn't kept consumes nothing.
+func fetchIMAPFeed(site Site, feedURL string, timeout time.Duration, span *Span) *FeedResult {
+	u, err := url.Parse(feedURL)
+	if err != nil || u.Host == "" {
+		return &FeedResult{Error: fmt.Errorf("invalid IMAP URL '%s'", feedURL)}
+	}
+	mailbox := strings.TrimPrefix(u.Path, "/")
+	if mailbox == "" {
+		mailbox = IMAP_DEFAULT_MAILBOX
+	}
+
+	username := site.Username
+	if username == "" && u.User != nil {
+		username = u.User.Username()
+	}
+	password, err := secrets.resolve(site.Password)
+	if err != nil {
+		return &FeedResult{Error: err}
+	}
+
+	fetch := startSpan(span, "fetch")
+	fetch.setClient()
+	fetch.set("imap.mailbox", mailbox)
+	start := time.Now()
+	messages, cursor, err := readIMAPMessages(u, username, password, mailbox, site.Mail, site.MailCursor, timeout)
+	fetch.fail(err)
+	fetch.set("imap.messages", len(messages))
+	fetch.finish()
+	elapsed := time.Since(start)
+	if err != nil {
+		if strings.Contains(err.Error(), "timeout") {
+			err = fmt.Errorf("timeout exceeded after %v", timeout)
+		} else {
+			err = fmt.Errorf("IMAP error: %w", err)
+		}
+		return &FeedResult{Error: err, Elapsed: elapsed}
+	}
+
+	if len(messages) == 0 {
+		return &FeedResult{NotModified: true, Elapsed: elapsed, MailCursor: cursor}
+	}
+
+	result := &FeedResult{FeedType: FeedTypeMail, FeedTitle: mailbox, Elapsed: elapsed, MailCursor: cursor}
+	for _, raw := range messages {
+		entry, err := parseMailEntry(raw)
+		if err != nil {
+			continue
+		}
+		result.Entries = append(result.Entries, entry)
+	}
+	if len(result.Entries) == 0 {
+		result.Error = fmt.Errorf("no readable messages found (%d matched)", len(messages))
+		return result
+	}
+
+	sort.SliceStable(result.Entries, func(i, j int) bool {
+		return result.Entries[i].Published.After(result.Entries[j].Published)
+	})
+	result.Title = result.Entries[0].Title
+	result.LatestLink = result.Entries[0].Link
+	result.applyFilter(site.Filter)
+	return result
+}
+
+// readIMAPMessages fetches the unseen messages matching rules that come
+// after cursor, oldest first, without flagging them. It returns them with
+// the cursor past the last one.
+func readIMAPMessages(u *url.URL, username, password, mailbox string, rules *MailRules, cursor *mailCursor, timeout time.Duration) ([][]byte, *mailCursor, error) {
+	c, err := dialIMAP(u, timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer c.conn.Close()
+
+	if _, err := c.command("LOGIN %s %s", imapQuote(username), imapQuote(password)); err != nil {
+		return nil, nil, fmt.Errorf("login failed: %w", err)
+	}
+	responses, err := c.command("EXAMINE %s", imapQuote(mailbox))
+	if err != nil {
+		return nil, nil, fmt.Errorf("selecting mailbox '%s': %w", mailbox, err)
+	}
+
+	next := &mailCursor{}
+	for _, r := range responses {
+		if match := imapValidityPattern.FindStringSubmatch(r.text); match != nil {
+			validity, _ := strconv.ParseUint(match[1], 10, 32)
+			next.Validity = uint32(validity)
+		}
+	}
+	// UIDs only go on meaning the same messages while UIDVALIDITY holds.
+	if cursor != nil && cursor.Validity == next.Validity {
+		next.LastUID = cursor.LastUID
+	}
+
+	criteria := rules.searchCriteria()
+	if next.LastUID > 0 {
+		criteria += fmt.Sprintf(" UID %d:*", next.LastUID+1)
+	}
+	responses, err = c.command("UID SEARCH %s", criteria)
+	if err != nil {
+		return nil, nil, fmt.Errorf("searching mailbox: %w", err)
+	}
+	var uids []uint32
+	for _, r := range responses {
+		fields := strings.Fields(r.text)
+		if len(fields) < 2 || fields[1] != "SEARCH" {
+			continue
+		}
+		for _, field := range fields[2:] {
+			// "n:*" matches the last message even when it is below n.
+			if uid, err := strconv.ParseUint(field, 10, 32); err == nil && uint32(uid) > next.LastUID {
+				uids = append(uids, uint32(uid))
+			}
+		}
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	if len(uids) > IMAP_MAX_MESSAGES {
+		uids = uids[:IMAP_MAX_MESSAGES]
+	}
+	if len(uids) == 0 {
+		c.command("LOGOUT")
+		return nil, next, nil
+	}
+
+	set := make([]string, len(uids))
+	for i, uid := range uids {
+		set[i] = strconv.FormatUint(uint64(uid), 10)
+	}
+	responses, err = c.command("UID FETCH %s (UID BODY.PEEK[])", strings.Join(set, ","))
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetching messages: %w", err)
+	}
+	var messages [][]byte
+	for _, r := range responses {
+		if imapUIDPattern.MatchString(r.text) && len(r.literals) > 0 {
+			messages = append(messages, r.literals[0])
+		}
+	}
+
+	c.command("LOGOUT")
+	next.LastUID = uids[len(uids)-1]
+	return messages, next, nil
+}
+
+func parseMailEntry(raw []byte) (FeedEntry, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return FeedEntry{}, err
+	}
+
+	decoder := new(mime.WordDecoder)
+	subject, err := decoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+	published, _ := msg.Header.Date()
+
+	htmlBody, textBody := mailBodies(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+
+	id := strings.Trim(msg.Header.Get("Message-Id"), "<> ")
+	if id == "" {
+		sum := sha256.Sum256(raw)
+		id = hex.EncodeToString(sum[:16])
+	}
+
+	link := mailLink(htmlBody, textBody)
+	if link == "" {
+		link = "mid:" + url.PathEscape(id)
+	}
+
+	content := textBody
+	if content == "" {
+		content = htmlToText(htmlBody)
+	}
+
+	return FeedEntry{
+		ID:        id,
+		Title:     strings.TrimSpace(subject),
+		Link:      link,
+		Content:   content,
+		Published: published,
+	}, nil
+}
+
+// mailBodies returns the first text/html and text/plain parts of a message,
+// descending into multipart containers.
+func mailBodies(contentType, encoding string, body io.Reader) (htmlBody, textBody string) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err != nil {
+				break
+			}
+			h, t := mailBodies(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if htmlBody == "" {
+				htmlBody = h
+			}
+			if textBody == "" {
+				textBody = t
+			}
+		}
+		return htmlBody, textBody
+	}
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	data, err := io.ReadAll(io.LimitReader(body, 5<<20))
+	if err != nil && len(data) == 0 {
+		return "", ""
+	}
+
+	switch mediaType {
+	case "text/html":
+		return string(data), ""
+	case "text/plain":
+		return "", string(data)
+	}
+	return "", ""
+}
+
+func isWebLink(link string) bool {
+	return strings.HasPrefix(link, "https://") || strings.HasPrefix(link, "http://")
+}
+
+func isMailNoiseLink(link string) bool {
+	lower := strings.ToLower(link)
+	for _, noise := range []string{"unsubscribe", "list-manage", "preferences", "/optout"} {
+		if strings.Contains(lower, noise) {
+			return true
+		}
+	}
+	return false
+}
+
+// mailLink picks the link an entry points at: the first web link in the HTML
+// body (or the plain text body) that is not an unsubscribe or settings link.
+func mailLink(htmlBody, textBody string) string {
+	if htmlBody != "" {
+		decoder := newHTMLDecoder([]byte(htmlBody))
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				break
+			}
+			start, ok := token.(xml.StartElement)
+			if !ok || strings.ToLower(start.Name.Local) != "a" {
+				continue
+			}
+			for _, attr := range start.Attr {
+				href := strings.TrimSpace(attr.Value)
+				if strings.ToLower(attr.Name.Local) == "href" && isWebLink(href) && !isMailNoiseLink(href) {
+					return href
+				}
+			}
+		}
+	}
+
+	for _, link := range mailURLPattern.FindAllString(textBody, -1) {
+		if !isMailNoiseLink(link) {
+			return link
+		}
+	}
+	return ""
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Short function words that are distinctive enough to tell Latin-script
+// languages apart in a headline.
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "for", "with", "how", "why", "what", "your", "from", "this", "are", "you", "was"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "für", "von", "ein", "eine", "auf", "wie", "den", "im", "zum", "auch", "sich"},
+	"fr": {"le", "les", "et", "des", "est", "pour", "du", "dans", "avec", "sur", "au", "pas", "une", "qui", "aux", "ce", "plus"},
+	"es": {"el", "los", "las", "y", "del", "por", "con", "es", "como", "qué", "se", "al", "su", "más"},
+	"it": {"il", "di", "che", "gli", "della", "per", "sono", "non", "nel", "è", "lo", "alla", "delle", "anche", "più"},
+	"pt": {"os", "do", "da", "em", "não", "com", "são", "é", "dos", "das", "uma", "ao", "mais", "pelo"},
+	"nl": {"het", "een", "van", "en", "niet", "voor", "met", "op", "dat", "zijn", "ook", "naar", "bij"},
+}
+
+// Letters that only (or almost only) occur in one of the languages above.
+var languageLetters = map[rune]string{
+	'ß': "de", 'ä': "de", 'ö': "de", 'ü': "de",
+	'ñ': "es", '¿': "es", '¡': "es",
+	'ã': "pt", 'õ': "pt",
+	'œ': "fr", 'ê': "fr", 'è': "fr",
+}
+
+var stopwordLanguages = func() map[string][]string {
+	index := make(map[string][]string)
+	for lang, words := range languageStopwords {
+		for _, word := range words {
+			index[word] = append(index[word], lang)
+		}
+	}
+	return index
+}()
+
+// normalizeLanguage reduces a language tag to its primary subtag ("en-US" → "en").
+func normalizeLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if primary, _, ok := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-"); ok {
+		return primary
+	}
+	return tag
+}
+
+// detectLanguage guesses the language of a short text from its script, or
+// from function words for Latin script. It returns "" when unsure.
+func detectLanguage(text string) string {
+	scripts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			scripts["ja"]++
+		case unicode.Is(unicode.Hangul, r):
+			scripts["ko"]++
+		case unicode.Is(unicode.Han, r):
+			scripts["zh"]++
+		case strings.ContainsRune("іїєґІЇЄҐ", r):
+			scripts["uk"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			scripts["ru"]++
+		case strings.ContainsRune("پچژگ", r):
+			scripts["fa"]++
+		case unicode.Is(unicode.Arabic, r):
+			scripts["ar"]++
+		case unicode.Is(unicode.Hebrew, r):
+			scripts["he"]++
+		case unicode.Is(unicode.Greek, r):
+			scripts["el"]++
+		case unicode.Is(unicode.Thai, r):
+			scripts["th"]++
+		case unicode.Is(unicode.Devanagari, r):
+			scripts["hi"]++
+		case unicode.Is(unicode.Latin, r):
+			scripts["latin"]++
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+
+	// Kana mixed with kanji is Japanese; Ukrainian and Persian share most of
+	// their letters with Russian and Arabic.
+	if scripts["ja"] > 0 {
+		return "ja"
+	}
+	if scripts["uk"] > 0 {
+		return "uk"
+	}
+	if scripts["fa"] > 0 {
+		return "fa"
+	}
+
+	script, most := "", 0
+	for name, count := range scripts {
+		if count > most {
+			script, most = name, count
+		}
+	}
+	if most*2 < letters {
+		return ""
+	}
+	if script != "latin" {
+		return script
+	}
+
+	scores := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		for _, lang := range stopwordLanguages[word] {
+			scores[lang] += 2
+		}
+		for _, r := range word {
+			if lang, ok := languageLetters[r]; ok {
+				scores[lang]++
+			}
+		}
+	}
+
+	best, bestScore, runnerUp := "", 0, 0
+	for lang, score := range scores {
+		if score > bestScore {
+			best, bestScore, runnerUp = lang, score, bestScore
+		} else if score > runnerUp {
+			runnerUp = score
+		}
+	}
+	if bestScore < 2 || bestScore == runnerUp {
+		return ""
+	}
+	return best
+}
+
+// entryLanguage prefers a language declared on the entry itself, then what
+// the title looks like, and finally the language declared for the whole feed,
+// which aggregators often set regardless of what they carry.
+func entryLanguage(declared, feedDeclared, title string) string {
+	if declared != "" {
+		return normalizeLanguage(declared)
+	}
+	if detected := detectLanguage(title); detected != "" {
+		return detected
+	}
+	return normalizeLanguage(feedDeclared)
+}
+
+func matchesLanguage(language string, languages []string) bool {
+	for _, candidate := range languages {
+		if normalizeLanguage(candidate) == language {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	WEBSOCKET_GUID      = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	LIVE_PING_INTERVAL  = 30 * time.Second
+	LIVE_WRITE_TIMEOUT  = 10 * time.Second
+	LIVE_BUFFER         = 64
+	LIVE_MAX_CLIENT_MSG = 4096
+)
+
+const (
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+)
+
+// LiveEvent is pushed to clients connected to /api/live while serve runs its
+// check cycles.
+type LiveEvent struct {
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	Site       string    `json:"site,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Entry      *Entry    `json:"entry,omitempty"`
+	Sites      int       `json:"sites,omitempty"`
+	NewEntries int       `json:"new_entries,omitempty"`
+	Failed     int       `json:"failed,omitempty"`
+	Duration   string    `json:"duration,omitempty"`
+}
+
+// liveHub fans events out to connected clients. A client that falls
+// LIVE_BUFFER events behind is disconnected rather than slowing down checks.
+type liveHub struct {
+	mu      sync.Mutex
+	clients map[chan []byte]bool
+}
+
+func (h *liveHub) subscribe() chan []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.clients == nil {
+		h.clients = make(map[chan []byte]bool)
+	}
+	events := make(chan []byte, LIVE_BUFFER)
+	h.clients[events] = true
+	return events
+}
+
+func (h *liveHub) unsubscribe(events chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.clients[events] {
+		delete(h.clients, events)
+		close(events)
+	}
+}
+
+func (h *liveHub) active() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients) > 0
+}
+
+func (h *liveHub) publish(event LiveEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for events := range h.clients {
+		select {
+		case events <- data:
+		default:
+			delete(h.clients, events)
+			close(events)
+		}
+	}
+}
+
+// liveSnapshot is what a check cycle is compared against to find new
+// entries and sites whose status changed.
+type liveSnapshot struct {
+	nextID int64
+	errors map[string]string
+}
+
+func (s *Server) liveSnapshot() liveSnapshot {
+	s.entries.mu.RLock()
+	snapshot := liveSnapshot{nextID: s.entries.NextID, errors: make(map[string]string, s.sites.Len())}
+	s.entries.mu.RUnlock()
+
+	for name, site := range s.sites.Snapshot() {
+		snapshot.errors[name] = site.LastError
+	}
+	return snapshot
+}
+
+// publishCycle reports the entries and site status changes of a finished
+// check cycle. Entries are sent without their content to keep messages small.
+func (s *Server) publishCycle(before liveSnapshot, elapsed time.Duration) {
+	added := s.entries.list(func(entry Entry) bool {
+		return entry.ID >= before.nextID && !entry.Read
+	})
+	for i := range added {
+		entry := added[i]
+		entry.Content = ""
+		s.live.publish(LiveEvent{Type: "entry", Site: entry.Site, Entry: &entry})
+	}
+
+	failed := 0
+	for name, site := range s.sites.Snapshot() {
+		if site.LastError != "" {
+			failed++
+		}
+		previous, known := before.errors[name]
+		switch {
+		case site.LastError != "" && site.LastError != previous:
+			s.live.publish(LiveEvent{Type: "site_error", Site: name, Error: site.LastError})
+		case site.LastError == "" && known && previous != "":
+			s.live.publish(LiveEvent{Type: "site_recovered", Site: name})
+		}
+	}
+
+	s.live.publish(LiveEvent{
+		Type:       "cycle_finished",
+		Sites:      s.sites.Len(),
+		NewEntries: len(added),
+		Failed:     failed,
+		Duration:   elapsed.Round(time.Millisecond).String(),
+	})
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Server) handleLive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expected a WebSocket upgrade"})
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeJSON(w, http.StatusUpgradeRequired, map[string]string{"error": "unsupported WebSocket version"})
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing Sec-WebSocket-Key"})
+		return
+	}
+	if !originAllowed(r, s.config.CORSOrigins) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "cross-origin WebSocket requests are not allowed"})
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "connection does not support WebSocket"})
+		return
+	}
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	conn := &wsConn{conn: netConn, rw: rw}
+	defer conn.conn.Close()
+
+	sum := sha1.Sum([]byte(key + WEBSOCKET_GUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	events := s.live.subscribe()
+	defer s.live.unsubscribe(events)
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.readLoop()
+	}()
+
+	ping := time.NewTicker(LIVE_PING_INTERVAL)
+	defer ping.Stop()
+
+	for {
+		select {
+		case data, ok := <-events:
+			if !ok {
+				// Dropped for falling behind (1008: policy violation).
+				conn.writeFrame(wsOpClose, []byte{0x03, 0xF0})
+				return
+			}
+			if conn.writeFrame(wsOpText, data) != nil {
+				return
+			}
+		case <-ping.C:
+			if conn.writeFrame(wsOpPing, nil) != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mu   sync.Mutex
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(LIVE_WRITE_TIMEOUT))
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+func (c *wsConn) readFrame() (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.rw, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := head[0] & 0x0F
+	length := uint64(head[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if head[1]&0x80 == 0 {
+		return 0, nil, fmt.Errorf("client frame is not masked")
+	}
+	if length > LIVE_MAX_CLIENT_MSG {
+		return 0, nil, fmt.Errorf("client frame too large (%d bytes)", length)
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// readLoop answers pings and close frames; the stream is one-way, so
+// anything else a client sends is ignored. A client that stays silent for two
+// ping intervals is considered gone.
+func (c *wsConn) readLoop() {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(2 * LIVE_PING_INTERVAL))
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case wsOpClose:
+			if len(payload) > 2 {
+				payload = payload[:2]
+			}
+			c.writeFrame(wsOpClose, payload)
+			return
+		case wsOpPing:
+			c.writeFrame(wsOpPong, payload)
+		}
+	}
+}
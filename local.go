@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const STDIN_SOURCE = "-"
+
+var (
+	stdinFeedOnce sync.Once
+	stdinFeed     []byte
+	stdinFeedErr  error
+)
+
+// isLocalSource reports whether a feed is read from a file or stdin rather
+// than fetched over the network: "-", a file:// URL, or a path without a scheme.
+func isLocalSource(feedURL string) bool {
+	if feedURL == STDIN_SOURCE || strings.HasPrefix(feedURL, "file://") {
+		return true
+	}
+	return feedURL != "" && !strings.Contains(feedURL, "://")
+}
+
+func localPath(feedURL string) (string, error) {
+	if !strings.HasPrefix(feedURL, "file://") {
+		return feedURL, nil
+	}
+	parsed, err := url.Parse(feedURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid file URL: %w", err)
+	}
+	if parsed.Host != "" && parsed.Host != "localhost" {
+		return "", fmt.Errorf("file URL must be local, got host '%s'", parsed.Host)
+	}
+	return parsed.Path, nil
+}
+
+// Stdin can only be read once, so every site using "-" shares the same body.
+func readStdinFeed() ([]byte, error) {
+	stdinFeedOnce.Do(func() {
+		stdinFeed, stdinFeedErr = readFeedBody(os.Stdin)
+	})
+	return stdinFeed, stdinFeedErr
+}
+
+func readFeedFile(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readFeedBody(file)
+}
+
+// fetchLocalFeed reads a feed from disk or stdin. A file's modification time
+// stands in for Last-Modified, so unchanged files are skipped like a 304.
+func fetchLocalFeed(site Site, feedURL string, span *Span) *FeedResult {
+	start := time.Now()
+
+	if feedURL == STDIN_SOURCE {
+		body, err := readStdinFeed()
+		if err != nil {
+			return &FeedResult{Error: fmt.Errorf("error reading stdin: %w", err)}
+		}
+		return parseFetchedFeed(site, feedURL, body, time.Since(start), span)
+	}
+
+	path, err := localPath(feedURL)
+	if err != nil {
+		return &FeedResult{Error: err}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return &FeedResult{Error: fmt.Errorf("error reading file: %w", err)}
+	}
+	modified := info.ModTime().UTC().Format(time.RFC3339Nano)
+	if site.LastModified == modified {
+		return &FeedResult{NotModified: true, LastModified: modified, Elapsed: time.Since(start)}
+	}
+
+	body, err := readFeedFile(path)
+	if err != nil {
+		return &FeedResult{Error: fmt.Errorf("error reading file: %w", err)}
+	}
+
+	feedResult := parseFetchedFeed(site, feedURL, body, time.Since(start), span)
+	feedResult.LastModified = modified
+	return feedResult
+}
@@ -2,13 +2,17 @@ package main
 
 import (
 	"bufio"
-	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"flag"
 	"fmt"
+	"html"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +22,12 @@ const (
 	DATABASE_FILE = "sites.json"
 	HTTP_TIMEOUT  = 30 * time.Second
 	MAX_WORKERS   = 50
+
+	MAX_HOST_WORKERS = 3
+
+	MAX_LATENCY_SAMPLES = 50
+
+	MAX_FEED_SIZE = 20 << 20
 )
 
 type FeedType int
@@ -26,53 +36,208 @@ const (
 	FeedTypeUnknown FeedType = iota
 	FeedTypeAtom
 	FeedTypeRSS
+	FeedTypeWatch
+	FeedTypeSitemap
+	FeedTypeMail
+	FeedTypeGemfeed
 )
 
 type AtomFeed struct {
-	Entries []AtomEntry `xml:"entry"`
+	Title   string       `xml:"title"`
+	Links   []AtomLink   `xml:"link"`
+	Entries []AtomEntry  `xml:"entry"`
+	Authors []AtomPerson `xml:"author"`
+	Lang    string       `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
 }
 
 type AtomEntry struct {
-	Title string     `xml:"title"`
-	Links []AtomLink `xml:"link"`
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Links      []AtomLink     `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Summary    string         `xml:"summary"`
+	Content    string         `xml:"content"`
+	Categories []AtomCategory `xml:"category"`
+	Authors    []AtomPerson   `xml:"author"`
+	Lang       string         `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	DCDate     string         `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Issued     string         `xml:"issued"`
+	Modified   string         `xml:"modified"`
+}
+
+type AtomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email"`
+}
+
+type AtomCategory struct {
+	Term string `xml:"term,attr"`
 }
 
 type AtomLink struct {
-	Href string `xml:"href,attr"`
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
 }
 
 type RSSFeed struct {
 	Channel RSSChannel `xml:"channel"`
+	// RSS 1.0 (RDF) puts items next to the channel instead of inside it.
+	Items []RSSItem `xml:"item"`
 }
 
 type RSSChannel struct {
-	Items []RSSItem `xml:"item"`
+	Title    string    `xml:"title"`
+	Link     string    `xml:"link"`
+	Items    []RSSItem `xml:"item"`
+	Language string    `xml:"language"`
 }
 
 type RSSItem struct {
-	Title string `xml:"title"`
-	Link  string `xml:"link"`
-	Guid  string `xml:"guid"`
+	Title           string     `xml:"title"`
+	Link            string     `xml:"link"`
+	Guid            string     `xml:"guid"`
+	PubDate         string     `xml:"pubDate"`
+	Description     string     `xml:"description"`
+	ContentEncoded  string     `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Media           []RSSMedia `xml:"http://search.yahoo.com/mrss/ content"`
+	Enclosures      []RSSMedia `xml:"enclosure"`
+	Hashes          []RSSHash  `xml:"http://search.yahoo.com/mrss/ hash"`
+	Categories      []string   `xml:"category"`
+	Author          string     `xml:"author"`
+	Creators        []string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Language        string     `xml:"http://purl.org/dc/elements/1.1/ language"`
+	About           string     `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	DCDate          string     `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Issued          string     `xml:"http://purl.org/dc/terms/ issued"`
+	Created         string     `xml:"http://purl.org/dc/terms/ created"`
+	Modified        string     `xml:"http://purl.org/dc/terms/ modified"`
+	PublicationDate string     `xml:"publicationDate"`
+}
+
+type RSSMedia struct {
+	URL    string    `xml:"url,attr"`
+	Type   string    `xml:"type,attr"`
+	Length string    `xml:"length,attr"`
+	Hashes []RSSHash `xml:"http://search.yahoo.com/mrss/ hash"`
+}
+
+type RSSHash struct {
+	Algo  string `xml:"algo,attr"`
+	Value string `xml:",chardata"`
 }
 
 type Site struct {
-	RSSUrl      string `json:"rss_url"`
-	LatestEntry string `json:"latest_entry"`
+	RSSUrl          string            `json:"rss_url"`
+	LatestEntry     string            `json:"latest_entry"`
+	Muted           bool              `json:"muted,omitempty"`
+	Tags            []string          `json:"tags,omitempty"`
+	Notifiers       []string          `json:"notifiers"`
+	SiteURL         string            `json:"site_url,omitempty"`
+	Bridge          *BridgeSource     `json:"bridge,omitempty"`
+	SnoozedUntil    *time.Time        `json:"snoozed_until,omitempty"`
+	Paused          bool              `json:"paused,omitempty"`
+	LastError       string            `json:"last_error,omitempty"`
+	LastErrorAt     *time.Time        `json:"last_error_at,omitempty"`
+	LastErrorKind   string            `json:"last_error_kind,omitempty"`
+	Timeout         string            `json:"timeout,omitempty"`
+	Latencies       []int64           `json:"latencies_ms,omitempty"`
+	LastPublished   *time.Time        `json:"last_published,omitempty"`
+	StaleAfter      string            `json:"stale_after,omitempty"`
+	StaleAlertedAt  *time.Time        `json:"stale_alerted_at,omitempty"`
+	Type            string            `json:"type,omitempty"`
+	Selector        string            `json:"selector,omitempty"`
+	Filter          *EntryFilter      `json:"filter,omitempty"`
+	Username        string            `json:"username,omitempty"`
+	Password        string            `json:"password,omitempty"`
+	Note            string            `json:"note,omitempty"`
+	ETag            string            `json:"etag,omitempty"`
+	LastModified    string            `json:"last_modified,omitempty"`
+	Aliases         []string          `json:"aliases,omitempty"`
+	IPVersion       string            `json:"ip_version,omitempty"`
+	Command         []string          `json:"command,omitempty"`
+	Mail            *MailRules        `json:"mail,omitempty"`
+	MaxNewEntries   int               `json:"max_new_entries,omitempty"`
+	Backfill        string            `json:"backfill,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	ResolveLinks    string            `json:"resolve_links,omitempty"`
+	Priority        string            `json:"priority,omitempty"`
+	FeedType        string            `json:"feed_type,omitempty"`
+	DetectedType    string            `json:"detected_type,omitempty"`
+	ChangeDetection string            `json:"change_detection,omitempty"`
+	ContentHash     string            `json:"content_hash,omitempty"`
+	Browser         bool              `json:"browser,omitempty"`
+	RecentFailures  []bool            `json:"recent_failures,omitempty"`
+	MovedTo         string            `json:"moved_to,omitempty"`
+	MailCursor      *mailCursor       `json:"mail_cursor,omitempty"`
+	RetryAfter      *time.Time        `json:"retry_after,omitempty"`
 }
 
 type SiteData map[string]Site
 
 type FeedResult struct {
+	NotModified  bool
+	ETag         string
+	LastModified string
+	Title        string
+	LatestLink   string
+	FeedType     FeedType
+	FeedTitle    string
+	SiteURL      string
+	Entries      []FeedEntry
+	Error        error
+	Elapsed      time.Duration
+	// MovedTo is where the feed permanently redirected to, if it did.
+	MovedTo string
+	// MailCursor is how far into an IMAP mailbox the check read.
+	MailCursor *mailCursor
+}
+
+type FeedEntry struct {
+	ID         string
 	Title      string
-	LatestLink string
-	FeedType   FeedType
-	Error      error
+	Link       string
+	Content    string
+	Published  time.Time
+	Enclosures []Enclosure
+	Categories []string
+	Author     string
+	Language   string
+}
+
+type CheckOptions struct {
+	Sites      []string
+	FailedOnly bool
+	Tag        string
+	Relative   bool
+	Store      Store
+	AllNew     bool
+	// Saver, when set, defers writing the state to a later coalesced save.
+	Saver *stateSaver
+	// Output receives the check's report; nil means the console.
+	Output io.Writer
+	// StateLock, when set, is held while each site's result is applied, and
+	// only then, so those sharing it aren't blocked while sites are fetched.
+	StateLock sync.Locker
+	// Deadline, when set, is when checks that haven't finished are given up.
+	Deadline time.Time
+}
+
+func (o CheckOptions) output() io.Writer {
+	if o.Output == nil {
+		return os.Stdout
+	}
+	return o.Output
 }
 
 type CheckResult struct {
 	SiteName string
 	Site     Site
 	Result   *FeedResult
+	// Skipped is set for checks the time budget ran out for.
+	Skipped bool
 }
 
 func detectFeedType(body []byte) FeedType {
@@ -104,39 +269,119 @@ func detectFeedType(body []byte) FeedType {
 }
 
 func parseFeed(body []byte) (*FeedResult, error) {
-	feedType := detectFeedType(body)
+	return parseFeedAs(body, detectFeedType(body))
+}
 
+func parseFeedAs(body []byte, feedType FeedType) (*FeedResult, error) {
 	switch feedType {
 	case FeedTypeAtom:
 		return parseAtomFeed(body)
 	case FeedTypeRSS:
 		return parseRSSFeed(body)
 	default:
+		if inner, ok := unwrapEscapedFeed(body); ok {
+			return parseFeed(inner)
+		}
 		return nil, fmt.Errorf("unsupported feed format")
 	}
 }
 
+var escapedFeedRoots = []string{"rss", "feed", "rdf:RDF"}
+
+// unwrapEscapedFeed recovers a feed that a broken generator escaped into the
+// text of a wrapper document (&lt;rss ...&gt; inside <pre> or <string>).
+func unwrapEscapedFeed(body []byte) ([]byte, bool) {
+	content := string(body)
+	for _, root := range escapedFeedRoots {
+		start := strings.Index(content, "&lt;"+root)
+		if start < 0 {
+			continue
+		}
+		closing := "&lt;/" + root + "&gt;"
+		end := strings.LastIndex(content, closing)
+		if end < start {
+			continue
+		}
+		if decl := strings.LastIndex(content[:start], "&lt;?xml"); decl >= 0 {
+			start = decl
+		}
+		return []byte(html.UnescapeString(content[start : end+len(closing)])), true
+	}
+	return nil, false
+}
+
 func parseAtomFeed(body []byte) (*FeedResult, error) {
 	var atom AtomFeed
 	if err := xml.Unmarshal(body, &atom); err != nil {
 		return nil, fmt.Errorf("parsing Atom feed: %w", err)
 	}
 
-	if len(atom.Entries) == 0 {
-		return &FeedResult{FeedType: FeedTypeAtom}, nil
+	result := &FeedResult{
+		FeedType:  FeedTypeAtom,
+		FeedTitle: strings.TrimSpace(atom.Title),
+	}
+	for _, link := range atom.Links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			result.SiteURL = strings.TrimSpace(link.Href)
+			break
+		}
 	}
 
-	latestEntry := atom.Entries[0]
-	latestLink := ""
-	if len(latestEntry.Links) > 0 {
-		latestLink = strings.TrimSpace(latestEntry.Links[0].Href)
+	for _, entry := range atom.Entries {
+		link := ""
+		var enclosures []Enclosure
+		for _, entryLink := range entry.Links {
+			if entryLink.Rel == "enclosure" {
+				enclosures = append(enclosures, newEnclosure(entryLink.Href, entryLink.Type, entryLink.Length, nil))
+			} else if link == "" && (entryLink.Rel == "" || entryLink.Rel == "alternate") {
+				link = strings.TrimSpace(entryLink.Href)
+			}
+		}
+		if link == "" && len(entry.Links) > 0 {
+			link = strings.TrimSpace(entry.Links[0].Href)
+		}
+
+		content := entry.Content
+		if strings.TrimSpace(content) == "" {
+			content = entry.Summary
+		}
+
+		title := strings.TrimSpace(entry.Title)
+		if title == "" {
+			title = deriveEntryTitle(content, 0)
+		}
+
+		var categories []string
+		for _, category := range entry.Categories {
+			if term := strings.TrimSpace(category.Term); term != "" {
+				categories = append(categories, term)
+			}
+		}
+
+		authors := entry.Authors
+		if len(authors) == 0 {
+			authors = atom.Authors
+		}
+
+		result.Entries = append(result.Entries, FeedEntry{
+			ID:         strings.TrimSpace(entry.ID),
+			Title:      title,
+			Link:       link,
+			Content:    strings.TrimSpace(content),
+			Published:  firstFeedDate(entry.Published, entry.Issued, entry.DCDate, entry.Updated, entry.Modified),
+			Enclosures: enclosures,
+			Categories: categories,
+			Author:     atomAuthor(authors),
+			Language:   entryLanguage(entry.Lang, atom.Lang, title),
+		})
 	}
 
-	return &FeedResult{
-		Title:      strings.TrimSpace(latestEntry.Title),
-		LatestLink: latestLink,
-		FeedType:   FeedTypeAtom,
-	}, nil
+	if len(result.Entries) > 0 {
+		result.Title = result.Entries[0].Title
+		result.LatestLink = result.Entries[0].Link
+	}
+
+	return result, nil
 }
 
 func parseRSSFeed(body []byte) (*FeedResult, error) {
@@ -145,21 +390,150 @@ func parseRSSFeed(body []byte) (*FeedResult, error) {
 		return nil, fmt.Errorf("parsing RSS feed: %w", err)
 	}
 
-	if len(rss.Channel.Items) == 0 {
-		return &FeedResult{FeedType: FeedTypeRSS}, nil
+	result := &FeedResult{
+		FeedType:  FeedTypeRSS,
+		FeedTitle: strings.TrimSpace(rss.Channel.Title),
+		SiteURL:   strings.TrimSpace(rss.Channel.Link),
+	}
+
+	for _, item := range append(rss.Channel.Items, rss.Items...) {
+		link := strings.TrimSpace(item.Link)
+		if link == "" {
+			link = strings.TrimSpace(item.Guid)
+		}
+		if link == "" {
+			link = strings.TrimSpace(item.About)
+		}
+
+		content := item.ContentEncoded
+		if strings.TrimSpace(content) == "" {
+			content = item.Description
+		}
+
+		title := strings.TrimSpace(item.Title)
+		if title == "" {
+			title = deriveEntryTitle(content, len(item.Media)+len(item.Enclosures))
+		}
+
+		var enclosures []Enclosure
+		for _, enclosure := range item.Enclosures {
+			hashes := item.Hashes
+			for _, media := range item.Media {
+				if media.URL == enclosure.URL && len(media.Hashes) > 0 {
+					hashes = media.Hashes
+				}
+			}
+			enclosures = append(enclosures, newEnclosure(enclosure.URL, enclosure.Type, enclosure.Length, hashes))
+		}
+
+		var categories []string
+		for _, category := range item.Categories {
+			if category = strings.TrimSpace(category); category != "" {
+				categories = append(categories, category)
+			}
+		}
+
+		result.Entries = append(result.Entries, FeedEntry{
+			ID:         strings.TrimSpace(item.Guid),
+			Title:      title,
+			Link:       link,
+			Content:    strings.TrimSpace(content),
+			Published:  firstFeedDate(item.PubDate, item.DCDate, item.Issued, item.Created, item.PublicationDate, item.Modified),
+			Enclosures: enclosures,
+			Categories: categories,
+			Author:     rssAuthor(item),
+			Language:   entryLanguage(item.Language, rss.Channel.Language, title),
+		})
+	}
+
+	if len(result.Entries) > 0 {
+		result.Title = result.Entries[0].Title
+		result.LatestLink = result.Entries[0].Link
+	}
+
+	return result, nil
+}
+
+func atomAuthor(authors []AtomPerson) string {
+	var names []string
+	for _, author := range authors {
+		name := strings.TrimSpace(author.Name)
+		if name == "" {
+			name = strings.TrimSpace(author.Email)
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// rssAuthor prefers dc:creator, which holds a plain name. RSS <author> is an
+// email address, optionally followed by the name in parentheses.
+func rssAuthor(item RSSItem) string {
+	var names []string
+	for _, creator := range item.Creators {
+		if creator = strings.TrimSpace(creator); creator != "" {
+			names = append(names, creator)
+		}
+	}
+	if len(names) > 0 {
+		return strings.Join(names, ", ")
 	}
 
-	latestItem := rss.Channel.Items[0]
-	latestLink := strings.TrimSpace(latestItem.Link)
-	if latestLink == "" {
-		latestLink = strings.TrimSpace(latestItem.Guid)
+	author := strings.TrimSpace(item.Author)
+	if start := strings.Index(author, "("); start >= 0 && strings.HasSuffix(author, ")") {
+		if name := strings.TrimSpace(author[start+1 : len(author)-1]); name != "" {
+			return name
+		}
 	}
+	return author
+}
 
-	return &FeedResult{
-		Title:      strings.TrimSpace(latestItem.Title),
-		LatestLink: latestLink,
-		FeedType:   FeedTypeRSS,
-	}, nil
+func formatAuthor(author string) string {
+	if author == "" {
+		return ""
+	}
+	return " by " + author
+}
+
+func parseFeedDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+
+	layouts := []string{
+		time.RFC3339,
+		time.RFC1123Z,
+		time.RFC1123,
+		"Mon, 2 Jan 2006 15:04:05 -0700",
+		"Mon, 2 Jan 2006 15:04:05 MST",
+		"2 Jan 2006 15:04:05 -0700",
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02 15:04:05",
+		"2006-01-02",
+		"2006-01",
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// firstFeedDate returns the first of several date fields that parses, so
+// feeds that only carry dc:date or dcterms dates still get one.
+func firstFeedDate(values ...string) time.Time {
+	for _, value := range values {
+		if t := parseFeedDate(value); !t.IsZero() {
+			return t
+		}
+	}
+	return time.Time{}
 }
 
 func feedTypeString(feedType FeedType) string {
@@ -168,39 +542,33 @@ func feedTypeString(feedType FeedType) string {
 		return "Atom"
 	case FeedTypeRSS:
 		return "RSS"
+	case FeedTypeWatch:
+		return "Page"
+	case FeedTypeSitemap:
+		return "Sitemap"
+	case FeedTypeMail:
+		return "Mail"
+	case FeedTypeGemfeed:
+		return "Gemfeed"
 	default:
 		return "Unknown"
 	}
 }
 
 func readSites() (SiteData, error) {
-	data, err := os.ReadFile(DATABASE_FILE)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return make(SiteData), nil
-		}
-		return nil, fmt.Errorf("error reading file: %w", err)
-	}
-
-	if len(data) == 0 {
-		return make(SiteData), nil
-	}
-
-	var sites SiteData
-	if err := json.Unmarshal(data, &sites); err != nil {
-		return nil, fmt.Errorf("error parsing JSON: %w", err)
-	}
+	return readSitesFrom(databaseFile)
+}
 
-	return sites, nil
+func readSitesFrom(path string) (SiteData, error) {
+	return openStore(path, "").LoadSites()
 }
 
 func saveSites(sites SiteData) error {
-	data, err := json.MarshalIndent(sites, "", "  ")
-	if err != nil {
-		return fmt.Errorf("error marshaling JSON: %w", err)
-	}
+	return saveSitesTo(databaseFile, sites)
+}
 
-	return os.WriteFile(DATABASE_FILE, data, 0644)
+func saveSitesTo(path string, sites SiteData) error {
+	return saveStoreSites(openStore(path, ""), sites)
 }
 
 func getSiteInput(sites SiteData, reader *bufio.Reader) (string, string, error) {
@@ -222,6 +590,11 @@ func getSiteInput(sites SiteData, reader *bufio.Reader) (string, string, error)
 			continue
 		}
 
+		if owner, exists := siteForAlias(sites, siteName); exists {
+			fmt.Printf("'%s' is already an alias of '%s'!\n", siteName, owner)
+			continue
+		}
+
 		fmt.Print("Enter Site RSS URL: ")
 		siteRSSURL, err := reader.ReadString('\n')
 		if err != nil {
@@ -238,7 +611,50 @@ func getSiteInput(sites SiteData, reader *bufio.Reader) (string, string, error)
 	}
 }
 
-func addSiteMode(sites SiteData) error {
+func parseTags(input string) []string {
+	var tags []string
+	for _, tag := range strings.Split(input, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			n, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration '%s'", value)
+			}
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s'", value)
+	}
+	return d, nil
+}
+
+func addSiteMode(sites SiteData, config Config) error {
 	reader := bufio.NewReader(os.Stdin)
 
 	for {
@@ -247,9 +663,51 @@ func addSiteMode(sites SiteData) error {
 			return err
 		}
 
+		site := Site{RSSUrl: siteRSSURL}
+		if feedURL, ok := mastodonFeedURL(siteRSSURL); ok {
+			fmt.Printf("Using Mastodon feed %s\n", feedURL)
+			site.RSSUrl = feedURL
+		} else if feedURL, ok := blueskyFeedURL(siteRSSURL); ok {
+			fmt.Printf("Using Bluesky feed %s\n", feedURL)
+			site.RSSUrl = feedURL
+		} else if strings.HasPrefix(siteRSSURL, BRIDGE_PREFIX) {
+			bridge, err := parseBridgeSpec(siteRSSURL)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			site = Site{Bridge: bridge}
+		} else if strings.HasPrefix(siteRSSURL, SITEMAP_PREFIX) {
+			site = Site{Type: SITE_TYPE_SITEMAP, RSSUrl: strings.TrimSpace(strings.TrimPrefix(siteRSSURL, SITEMAP_PREFIX))}
+		} else if strings.HasPrefix(siteRSSURL, EXEC_PREFIX) {
+			command := strings.Fields(strings.TrimPrefix(siteRSSURL, EXEC_PREFIX))
+			if len(command) == 0 {
+				fmt.Println("missing command (expected 'exec:<command> [args...]')")
+				continue
+			}
+			site = Site{Command: command}
+		} else if strings.HasPrefix(siteRSSURL, WATCH_PREFIX) {
+			site = parseWatchSpec(siteRSSURL)
+			if site.RSSUrl == "" {
+				fmt.Println("missing page URL (expected 'watch:<url> [selector]')")
+				continue
+			}
+		}
+
+		feedURL, err := resolveFeedURL(site, config)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
 		fmt.Printf("Testing feed... ")
-		client := &http.Client{Timeout: HTTP_TIMEOUT}
-		resp, err := client.Get(siteRSSURL)
+		body, err := readFeedForTest(site, feedURL)
+		var sample []FeedEntry
+		if err == nil {
+			if result, err := parseSiteBody(site, feedURL, body); err == nil {
+				sample = result.Entries
+			}
+		}
 		if err != nil {
 			fmt.Printf("FAILED: %v\n", err)
 			fmt.Print("Do you want to save anyway? (y/n): ")
@@ -258,21 +716,35 @@ func addSiteMode(sites SiteData) error {
 				fmt.Println("Site not saved")
 				continue
 			}
-		} else {
-			defer resp.Body.Close()
-			body, err := io.ReadAll(resp.Body)
-			if err != nil {
+		} else if site.Type != SITE_TYPE_FEED {
+			if result, err := parseSiteBody(site, feedURL, body); err != nil {
 				fmt.Printf("FAILED: %v\n", err)
 			} else {
-				feedType := detectFeedType(body)
-				fmt.Printf("OK (%s feed detected)\n", feedTypeString(feedType))
+				fmt.Printf("OK (%s detected)\n", feedTypeString(result.FeedType))
+			}
+		} else if feedType := detectFeedType(body); feedType != FeedTypeUnknown || isGeminiSource(feedURL) || !looksLikeHTML("", body) {
+			fmt.Printf("OK (%s feed detected)\n", feedTypeString(feedType))
+		} else if page, err := url.Parse(feedURL); err == nil {
+			suggestion := suggestFeed(newFeedClient(httpTimeout, site.IPVersion), page, body)
+			fmt.Printf("FAILED: %v\n", webPageError(suggestion))
+			if suggestion != "" {
+				fmt.Print("Use it instead? (y/n): ")
+				confirm, _ := reader.ReadString('\n')
+				if strings.TrimSpace(strings.ToLower(confirm)) == "y" {
+					site.RSSUrl = suggestion
+				}
 			}
 		}
 
-		sites[siteName] = Site{
-			RSSUrl:      siteRSSURL,
-			LatestEntry: "",
+		fmt.Print("Enter Tags (comma separated, optional): ")
+		tagsInput, _ := reader.ReadString('\n')
+
+		site.Tags = parseTags(tagsInput)
+		if tags := config.autoTags(site, feedEntryTexts(sample)); len(tags) > 0 {
+			site.Tags = append(site.Tags, tags...)
+			fmt.Printf("→ Tagged automatically: %s\n", strings.Join(tags, ", "))
 		}
+		sites[siteName] = site
 
 		if err := saveSites(sites); err != nil {
 			return fmt.Errorf("saving site: %w", err)
@@ -291,154 +763,612 @@ func addSiteMode(sites SiteData) error {
 	return nil
 }
 
-func checkSingleFeed(siteName string, site Site, results chan<- CheckResult, wg *sync.WaitGroup) {
-	defer wg.Done()
+func readFeedForTest(site Site, feedURL string) ([]byte, error) {
+	if len(site.Command) > 0 {
+		return runFeedCommand(site.Command, httpTimeout)
+	}
+	if isIMAPSource(feedURL) {
+		return nil, fmt.Errorf("mailboxes are read during a check; set the site's username and password first")
+	}
+	if isGeminiSource(feedURL) {
+		_, _, body, err := fetchGemini(feedURL, httpTimeout)
+		return body, err
+	}
+	if isLocalSource(feedURL) {
+		if feedURL == STDIN_SOURCE {
+			return nil, fmt.Errorf("stdin sources can only be read during a check")
+		}
+		path, err := localPath(feedURL)
+		if err != nil {
+			return nil, err
+		}
+		return readFeedFile(path)
+	}
+	if err := crawlPolitely(site, feedURL, httpTimeout, time.Time{}, nil); err != nil {
+		return nil, err
+	}
+	if site.Browser {
+		body, err := renderPage(feedURL, httpTimeout)
+		return extractRenderedFeed(body), err
+	}
 
-	client := &http.Client{Timeout: HTTP_TIMEOUT}
+	req, err := newFeedRequest(site, feedURL)
+	if err != nil {
+		return nil, err
+	}
+	client := newFeedClient(httpTimeout, site.IPVersion)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return decodeFeedBody(resp.Body, resp.Header.Get("Content-Encoding"))
+}
 
-	start := time.Now()
-	resp, err := client.Get(site.RSSUrl)
+// readFeedBody reads a feed body, refusing anything larger than
+// MAX_FEED_SIZE instead of buffering an unbounded response.
+func readFeedBody(r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, MAX_FEED_SIZE+1))
 	if err != nil {
-		if strings.Contains(err.Error(), "timeout") || strings.Contains(err.Error(), "deadline") {
-			results <- CheckResult{
-				SiteName: siteName,
-				Site:     site,
-				Result: &FeedResult{
-					Error: fmt.Errorf("timeout exceeded after %v", HTTP_TIMEOUT),
-				},
-			}
+		return nil, err
+	}
+	if len(body) > MAX_FEED_SIZE {
+		return nil, fmt.Errorf("feed is larger than %s", formatBytes(MAX_FEED_SIZE))
+	}
+	return body, nil
+}
+
+func (s Site) timeout() (time.Duration, error) {
+	if s.Timeout == "" {
+		return httpTimeout, nil
+	}
+
+	timeout, err := parseDuration(s.Timeout)
+	if err != nil {
+		return 0, err
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("timeout must be positive, got '%s'", s.Timeout)
+	}
+	return timeout, nil
+}
+
+func (s *Site) recordLatency(elapsed time.Duration) {
+	s.Latencies = append(s.Latencies, elapsed.Milliseconds())
+	if len(s.Latencies) > MAX_LATENCY_SAMPLES {
+		s.Latencies = s.Latencies[len(s.Latencies)-MAX_LATENCY_SAMPLES:]
+	}
+}
+
+func newFeedRequest(site Site, feedURL string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("URL fetch error: %w", err)
+	}
+
+	// Set explicitly, so the transport leaves decoding to decodeFeedBody.
+	req.Header.Set("Accept-Encoding", ACCEPT_ENCODING)
+	req.Header.Set("User-Agent", USER_AGENT)
+	for name, value := range site.Headers {
+		value, err := secrets.resolve(value)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", name, err)
+		}
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+
+	if site.ETag != "" {
+		req.Header.Set("If-None-Match", site.ETag)
+	}
+	if site.LastModified != "" {
+		req.Header.Set("If-Modified-Since", site.LastModified)
+	}
+
+	if site.Username != "" || site.Password != "" {
+		password, err := secrets.resolve(site.Password)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(site.Username, password)
+	}
+
+	return req, nil
+}
+
+func checkSingleFeed(siteName string, site Site, feedURL string, timeout time.Duration, deadline time.Time, parent *Span, results chan<- CheckResult, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if !deadline.IsZero() {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			results <- CheckResult{SiteName: siteName, Site: site, Result: &FeedResult{}, Skipped: true}
 			return
 		}
+		timeout = min(timeout, remaining)
+	}
+
+	span := startSpan(parent, "check_feed")
+	span.set("site", siteName)
+	span.set("url", feedURL)
+
+	result := fetchFeed(site, feedURL, timeout, deadline, span)
+	span.set("entries", len(result.Entries))
+	span.set("not_modified", result.NotModified)
+	span.fail(result.Error)
+	span.finish()
+
+	results <- CheckResult{
+		SiteName: siteName,
+		Site:     site,
+		Result:   result,
+		// A check cut short by the budget says nothing about the site.
+		Skipped: result.Error != nil && !deadline.IsZero() && (!time.Now().Before(deadline) || errors.Is(result.Error, errCrawlPastDeadline)),
+	}
+}
 
-		results <- CheckResult{
-			SiteName: siteName,
-			Site:     site,
-			Result: &FeedResult{
-				Error: fmt.Errorf("URL fetch error: %w", err),
-			},
+func fetchFeed(site Site, feedURL string, timeout time.Duration, deadline time.Time, span *Span) *FeedResult {
+	if len(site.Command) > 0 {
+		return fetchCommandFeed(site, feedURL, timeout, span)
+	}
+	if isIMAPSource(feedURL) {
+		return fetchIMAPFeed(site, feedURL, timeout, span)
+	}
+	if isGeminiSource(feedURL) {
+		return fetchGeminiFeed(site, feedURL, timeout, span)
+	}
+	if isLocalSource(feedURL) {
+		return fetchLocalFeed(site, feedURL, span)
+	}
+	if err := crawlPolitely(site, feedURL, timeout, deadline, span); err != nil {
+		return &FeedResult{Error: err}
+	}
+	if site.Browser {
+		return fetchBrowserFeed(site, feedURL, timeout, span)
+	}
+
+	if err := validateIPVersion(site.IPVersion); err != nil {
+		return &FeedResult{Error: err}
+	}
+	client := newFeedClient(timeout, site.IPVersion)
+
+	req, err := newFeedRequest(site, feedURL)
+	if err != nil {
+		return &FeedResult{Error: err}
+	}
+
+	fetch := startSpan(span, "fetch")
+	fetch.setClient()
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		if netErr := classifyNetError(err); netErr != nil {
+			err = netErr
+		} else if strings.Contains(err.Error(), "timeout") || strings.Contains(err.Error(), "deadline") {
+			err = fmt.Errorf("timeout exceeded after %v", timeout)
+		} else {
+			err = fmt.Errorf("URL fetch error: %w", err)
 		}
-		return
+		fetch.fail(err)
+		fetch.finish()
+		return &FeedResult{Error: err}
 	}
 	defer resp.Body.Close()
+	fetch.set("http.status_code", resp.StatusCode)
+
+	if resp.StatusCode == http.StatusNotModified {
+		fetch.finish()
+		return &FeedResult{
+			NotModified:  true,
+			ETag:         site.ETag,
+			LastModified: site.LastModified,
+			Elapsed:      time.Since(start),
+			MovedTo:      permanentRedirect(resp),
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("HTTP status: %s", resp.Status)
+		fetch.fail(err)
+		fetch.finish()
+		return &FeedResult{Error: err}
+	}
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := decodeFeedBody(resp.Body, resp.Header.Get("Content-Encoding"))
+	fetch.set("bytes", len(body))
 	if err != nil {
-		results <- CheckResult{
-			SiteName: siteName,
-			Site:     site,
-			Result: &FeedResult{
-				Error: fmt.Errorf("error reading response: %w", err),
-			},
-		}
-		return
+		err = fmt.Errorf("error reading response: %w", err)
+		fetch.fail(err)
+		fetch.finish()
+		return &FeedResult{Error: err}
+	}
+	fetch.finish()
+
+	feedResult := parseFetchedFeed(site, feedURL, body, time.Since(start), span)
+	if feedResult.Error != nil && site.Type == SITE_TYPE_FEED && looksLikeHTML(resp.Header.Get("Content-Type"), body) {
+		feedResult.Error = webPageError(suggestFeed(client, resp.Request.URL, body))
 	}
+	feedResult.ETag = resp.Header.Get("ETag")
+	feedResult.LastModified = resp.Header.Get("Last-Modified")
+	feedResult.MovedTo = permanentRedirect(resp)
+	return feedResult
+}
 
-	feedResult, err := parseFeed(body)
+func parseFetchedFeed(site Site, feedURL string, body []byte, elapsed time.Duration, span *Span) *FeedResult {
+	parse := startSpan(span, "parse")
+	feedResult, err := parseSiteBody(site, feedURL, body)
 	if err != nil {
-		results <- CheckResult{
-			SiteName: siteName,
-			Site:     site,
-			Result: &FeedResult{
-				Error: fmt.Errorf("parse error: %w", err),
-			},
-		}
-		return
+		err = fmt.Errorf("parse error: %w", err)
+		parse.fail(err)
+		parse.finish()
+		return &FeedResult{Error: err, Elapsed: elapsed}
 	}
+	parse.set("feed_type", feedTypeString(feedResult.FeedType))
+	parse.finish()
 
-	elapsed := time.Since(start)
+	feedResult.Elapsed = elapsed
 	if feedResult.LatestLink == "" {
 		feedResult.Error = fmt.Errorf("no entries found (%s) - checked in %v", feedTypeString(feedResult.FeedType), elapsed)
 	} else {
 		feedResult.Error = nil
+		feedResult.applyFilter(site.Filter)
 	}
 
-	results <- CheckResult{
-		SiteName: siteName,
-		Site:     site,
-		Result:   feedResult,
-	}
+	return feedResult
 }
 
-func checkFeeds(sites SiteData) error {
-	var wg sync.WaitGroup
-	results := make(chan CheckResult, len(sites))
-
-	sem := make(chan struct{}, MAX_WORKERS)
-
-	hasUpdates := false
-	index := 1
+func (o CheckOptions) selectSites(sites SiteData) []string {
+	if len(o.Sites) > 0 {
+		return o.Sites
+	}
 
+	now := time.Now()
+	names := make([]string, 0, len(sites))
 	for name, site := range sites {
-		wg.Add(1)
-		sem <- struct{}{}
+		if site.onHold(now) {
+			continue
+		}
+		if o.FailedOnly && site.LastError == "" {
+			continue
+		}
+		if o.Tag != "" && !hasTag(site.Tags, o.Tag) {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func dispatchChecks(sites SiteData, names []string, config Config, deadline time.Time, parent *Span) <-chan CheckResult {
+	results := make(chan CheckResult, len(names))
 
-		go func(siteName string, site Site) {
-			defer func() { <-sem }()
-			checkSingleFeed(siteName, site, results, &wg)
-		}(name, site)
+	type job struct {
+		name string
+		site Site
+	}
+	jobs := make([]job, 0, len(names))
+	ordered := append([]string(nil), names...)
+	sortByPriority(sites, ordered)
+	for _, name := range ordered {
+		jobs = append(jobs, job{name: name, site: sites[name]})
 	}
 
 	go func() {
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, maxWorkers)
+		hosts := make(map[string]chan struct{})
+
+		// Checks of one priority are only started once every check of the
+		// priority above holds a worker slot, so they can't be overtaken.
+		var started sync.WaitGroup
+		rank := 0
+
+		for _, j := range jobs {
+			if r := priorityRank(j.site); r != rank {
+				started.Wait()
+				rank = r
+			}
+
+			feedURL, err := resolveFeedURL(j.site, config)
+			if err != nil {
+				results <- CheckResult{
+					SiteName: j.name,
+					Site:     j.site,
+					Result:   &FeedResult{Error: err},
+				}
+				continue
+			}
+
+			timeout, err := j.site.timeout()
+			if err != nil {
+				results <- CheckResult{
+					SiteName: j.name,
+					Site:     j.site,
+					Result:   &FeedResult{Error: fmt.Errorf("invalid timeout: %w", err)},
+				}
+				continue
+			}
+
+			// Each host gets its own smaller limit. A check waits for its host
+			// slot before taking a global one, so a busy host doesn't hold up
+			// the others.
+			host := feedHost(feedURL)
+			hostSem, ok := hosts[host]
+			if !ok {
+				hostSem = make(chan struct{}, maxHostWorkers)
+				hosts[host] = hostSem
+			}
+
+			wg.Add(1)
+			started.Add(1)
+			go func(siteName string, site Site, feedURL string, timeout time.Duration) {
+				hostSem <- struct{}{}
+				sem <- struct{}{}
+				started.Done()
+				defer func() {
+					<-sem
+					<-hostSem
+				}()
+				checkSingleFeed(siteName, site, feedURL, timeout, deadline, parent, results, &wg)
+			}(j.name, j.site, feedURL, timeout)
+		}
+
 		wg.Wait()
 		close(results)
 	}()
 
-	for result := range results {
-		siteName := result.SiteName
-		site := result.Site
-		feedResult := result.Result
+	return results
+}
 
-		if feedResult.Error != nil {
-			if strings.Contains(feedResult.Error.Error(), "timeout exceeded") {
-				fmt.Printf("%s → TIMEOUT: %v\n", siteName, feedResult.Error)
-			} else if strings.Contains(feedResult.Error.Error(), "no entries found") {
-				fmt.Printf("%s → %v\n", siteName, feedResult.Error)
-			} else {
-				fmt.Printf("%s → ERROR: %v\n", siteName, feedResult.Error)
-			}
-			continue
+func saveCheckState(out io.Writer, store Store, sites SiteData, entries *EntryStore, hasUpdates, hasStats, hasNewEntries bool) error {
+	if hasUpdates {
+		if err := saveStoreSites(store, sites); err != nil {
+			return fmt.Errorf("saving updates: %w", err)
+		}
+		fmt.Fprintln(out, "✓ Site database updated")
+	} else if hasStats {
+		if err := saveStoreSites(store, sites); err != nil {
+			return fmt.Errorf("saving stats: %w", err)
+		}
+	}
+
+	if hasNewEntries {
+		if err := entries.save(); err != nil {
+			return fmt.Errorf("saving entries: %w", err)
+		}
+	}
+	return nil
+}
+
+func feedHost(feedURL string) string {
+	parsed, err := url.Parse(feedURL)
+	if err != nil || parsed.Host == "" {
+		return feedURL
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// checkFeeds checks sites and prints the outcome, returning the sites that
+// failed.
+func checkFeeds(sites *SiteRepo, config Config, entries *EntryStore, opts CheckOptions) ([]string, error) {
+	summary := newRunSummary(config)
+	run := startChecks(sites, config, entries, opts)
+	printer := checkPrinter{out: opts.output(), relative: opts.Relative}
+	for outcome := range run.Results {
+		printer.print(outcome)
+		summary.add(outcome, sites)
+	}
+	sort.Strings(printer.failed)
+	printer.printSkipped(opts.Deadline)
+
+	err := run.Wait()
+	summary.post(config.RunSummary, err, opts.output())
+	return printer.failed, err
+}
+
+// startCheckReport prints what a check is about to do. It returns the sites
+// selected, or false if there are no failing sites to re-check.
+func startCheckReport(out io.Writer, sites SiteData, opts CheckOptions) ([]string, bool) {
+	selected := opts.selectSites(sites)
+	if opts.FailedOnly && len(selected) == 0 {
+		fmt.Fprintln(out, "No failing sites to re-check")
+		return nil, false
+	}
+	if opts.Tag != "" && len(selected) == 0 {
+		fmt.Fprintf(out, "No sites tagged '%s' to check (snoozed and paused sites are skipped)\n", opts.Tag)
+		return nil, false
+	}
+
+	fmt.Fprintf(out, "Checking %d sites concurrently (timeout: %v, max workers: %d, per host: %d)...\n", len(selected), httpTimeout, maxWorkers, maxHostWorkers)
+	if snoozed := len(sites) - len(selected); len(opts.Sites) == 0 && !opts.FailedOnly && opts.Tag == "" && snoozed > 0 {
+		fmt.Fprintf(out, "Skipping %d snoozed or paused sites\n", snoozed)
+	}
+	fmt.Fprintln(out)
+	return selected, true
+}
+
+func runCheck(sites SiteData, config Config, args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	failedOnly := fs.Bool("failed-only", false, "Only re-check sites whose last check failed.")
+	relative := fs.Bool("relative", false, "Show publication times relative to now (e.g. \"4h ago\").")
+	stdin := fs.Bool("stdin", false, "Check sites read from stdin and print JSON results without touching the database.")
+	diffPath := fs.String("diff", "", "Write a JSON diff of site state changes to this file (- for stdout, with -no-console).")
+	allNew := fs.Bool("all-new", false, "Report every new entry, ignoring the per-feed cap.")
+	outputFile := fs.String("output-file", "", "Also write the check's report to this file.")
+	outputMode := fs.String("output-mode", OUTPUT_APPEND, "How to open -output-file: append or truncate.")
+	noConsole := fs.Bool("no-console", false, "Write the report only to -output-file.")
+	maxDuration := fs.Duration("max-duration", 0, "Give up on checks still running after this long (e.g. 2m); the rest are skipped.")
+	strict := fs.Bool("strict", false, "Exit with status 2 if any site fails to be checked.")
+	bestEffort := fs.Bool("best-effort", false, "Exit with status 0 even if sites fail to be checked.")
+	noDaemon := fs.Bool("no-daemon", false, "Check here even if serve is running on the same database.")
+	tag := fs.String("tag", "", "Only check the sites with this tag.")
+	queries := parseInterspersed(fs, args)
+
+	if *tag != "" && len(queries) > 0 {
+		return fmt.Errorf("-tag can't be combined with site names")
+	}
+	// The report goes to stdout too unless -no-console moves it to the file.
+	if *diffPath == "-" && !*noConsole {
+		return fmt.Errorf("-diff - needs -no-console and -output-file, so the report doesn't mix with the diff on stdout")
+	}
+
+	policy, err := exitPolicy(config, *strict, *bestEffort)
+	if err != nil {
+		return err
+	}
+
+	if *stdin {
+		failed, err := runStdinCheck(config, os.Stdin, os.Stdout)
+		if err != nil {
+			return err
 		}
+		if policy == EXIT_STRICT && len(failed) > 0 {
+			return &sitesFailedError{sites: failed, reported: true}
+		}
+		return nil
+	}
 
-		savedLink := strings.TrimSpace(site.LatestEntry)
+	if databaseFile == MEMORY_DATABASE {
+		memorySites, err := readMemorySites(queries, os.Stdin)
+		if err != nil {
+			return err
+		}
+		for name, site := range memorySites {
+			sites[name] = site
+		}
+		queries = nil
+	}
 
-		switch {
-		case savedLink == "":
-			fmt.Printf("%d. %s → First time checking (%s)\n", index, siteName, feedTypeString(feedResult.FeedType))
-			site.LatestEntry = feedResult.LatestLink
-			sites[siteName] = site
-			hasUpdates = true
+	if len(sites) == 0 {
+		fmt.Println("No sites configured. Use -a to add sites.")
+		return nil
+	}
 
-		case feedResult.LatestLink != savedLink:
-			title := feedResult.Title
-			if title == "" {
-				title = "Untitled"
-			}
-			fmt.Printf("%d. %s → NEW ENTRY: %s - %s (%s)\n", index, siteName, title, feedResult.LatestLink, feedTypeString(feedResult.FeedType))
-			site.LatestEntry = feedResult.LatestLink
-			sites[siteName] = site
-			hasUpdates = true
+	out, err := openCheckOutput(*outputFile, *outputMode, !*noConsole)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
 
-		default:
-			fmt.Printf("%d. (-_-) %s\n", index, siteName)
+	opts := CheckOptions{FailedOnly: *failedOnly, Tag: *tag, Relative: *relative, AllNew: *allNew, Output: out}
+	if *maxDuration > 0 {
+		opts.Deadline = time.Now().Add(*maxDuration)
+	}
+	reader := bufio.NewReader(os.Stdin)
+	for _, query := range queries {
+		name, err := resolveSiteName(sites, query, reader)
+		if err != nil {
+			return err
 		}
+		opts.Sites = append(opts.Sites, name)
+	}
 
-		index++
+	// A running serve owns the database, so it does the check rather than
+	// racing this process to save it.
+	if !*noDaemon {
+		if conn := dialDaemon(databaseFile); conn != nil {
+			if *diffPath != "" {
+				conn.Close()
+				return fmt.Errorf("-diff can't be used while serve is running on this database (add -no-daemon to check here anyway)")
+			}
+			failed, err := handOffCheck(conn, out, daemonRequest{
+				Sites:       opts.Sites,
+				FailedOnly:  opts.FailedOnly,
+				Tag:         opts.Tag,
+				Relative:    opts.Relative,
+				AllNew:      opts.AllNew,
+				MaxDuration: *maxDuration,
+				NoConsole:   *noConsole,
+			})
+			if err != nil {
+				return err
+			}
+			if policy == EXIT_STRICT && len(failed) > 0 {
+				return &sitesFailedError{sites: failed}
+			}
+			return nil
+		}
 	}
 
-	if hasUpdates {
-		if err := saveSites(sites); err != nil {
-			return fmt.Errorf("saving updates: %w", err)
+	entries, err := readEntries()
+	if err != nil {
+		return err
+	}
+
+	selected, ok := startCheckReport(out, sites, opts)
+	if !ok {
+		return nil
+	}
+
+	before := make(SiteData, len(sites))
+	for name, site := range sites {
+		before[name] = site
+	}
+	diff := CheckDiff{StartedAt: time.Now(), Checked: len(selected)}
+	firstNewID := entries.NextID
+
+	failed, err := checkFeeds(newSiteRepo(sites), config, entries, opts)
+	if err != nil {
+		return err
+	}
+
+	if *diffPath != "" {
+		diff.FinishedAt = time.Now()
+		diff.Changes = diffSites(before, sites, selected, entries, firstNewID)
+		if err := writeCheckDiff(*diffPath, diff); err != nil {
+			return err
 		}
-		fmt.Println("✓ Site database updated")
 	}
 
+	if policy == EXIT_STRICT && len(failed) > 0 {
+		return &sitesFailedError{sites: failed}
+	}
 	return nil
 }
 
 func main() {
 	addPtr := flag.Bool("a", false, "Add new site mode.")
+	settings := registerSettingsFlags()
 	flag.Parse()
 
+	resolveConfigFile(settings)
+	config, err := readConfig()
+	if err != nil {
+		fmt.Printf("Error reading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := applySettings(&config, settings); err != nil {
+		fmt.Printf("Error in settings: %v\n", err)
+		os.Exit(1)
+	}
+
+	if flag.Arg(0) == "secret" {
+		if err := runSecretCommandLine(config, flag.Args()[1:]); err != nil {
+			fmt.Printf("Error managing secret: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := resolveDatabaseSecret(config); err != nil {
+		fmt.Printf("Error resolving secrets: %v\n", err)
+		os.Exit(1)
+	}
+	if secretCommands[flag.Arg(0)] {
+		if err := resolveConfigSecrets(&config); err != nil {
+			fmt.Printf("Error resolving secrets: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	tracer = newTracer(config.Tracing)
+	if outputTemplates, err = loadTemplates(config.TemplatesDir); err != nil {
+		fmt.Printf("Error loading templates: %v\n", err)
+		os.Exit(1)
+	}
+
 	sites, err := readSites()
 	if err != nil {
 		fmt.Printf("Error reading sites: %v\n", err)
@@ -446,22 +1376,228 @@ func main() {
 	}
 
 	if *addPtr {
-		if err := addSiteMode(sites); err != nil {
+		if err := addSiteMode(sites, config); err != nil {
 			fmt.Printf("Error in add mode: %v\n", err)
 			os.Exit(1)
 		}
-	} else {
-		if len(sites) == 0 {
-			fmt.Println("No sites configured. Use -a to add sites.")
-			return
-		}
+		return
+	}
 
-		fmt.Printf("Checking %d sites concurrently (timeout: %v, max workers: %d)...\n\n",
-			len(sites), HTTP_TIMEOUT, MAX_WORKERS)
+	command := flag.Arg(0)
+	args := flag.Args()
+	if len(args) > 0 {
+		args = args[1:]
+	}
 
-		if err := checkFeeds(sites); err != nil {
+	switch command {
+	case "", "check":
+		if err := runCheck(sites, config, args); err != nil {
+			var failed *sitesFailedError
+			if errors.As(err, &failed) {
+				if !failed.reported {
+					fmt.Printf("Error checking feeds: %v\n", err)
+				}
+				os.Exit(EXIT_SITES_FAILED)
+			}
 			fmt.Printf("Error checking feeds: %v\n", err)
 			os.Exit(1)
 		}
+	case "preview":
+		if err := runPreview(args); err != nil {
+			fmt.Printf("Error previewing feed: %v\n", err)
+			os.Exit(1)
+		}
+	case "history":
+		if err := runHistory(sites, args); err != nil {
+			fmt.Printf("Error showing history: %v\n", err)
+			os.Exit(1)
+		}
+	case "prune":
+		if err := runPrune(args); err != nil {
+			fmt.Printf("Error pruning entries: %v\n", err)
+			os.Exit(1)
+		}
+	case "maintenance":
+		if err := runMaintenance(sites, config, args); err != nil {
+			fmt.Printf("Error during maintenance: %v\n", err)
+			os.Exit(1)
+		}
+	case "serve":
+		if err := runServe(sites, config, args); err != nil {
+			fmt.Printf("Error in serve mode: %v\n", err)
+			os.Exit(1)
+		}
+	case "report":
+		if err := runReport(sites, config, args); err != nil {
+			fmt.Printf("Error generating report: %v\n", err)
+			os.Exit(1)
+		}
+	case "add-arxiv":
+		if err := addArxivSite(sites, config, args); err != nil {
+			fmt.Printf("Error adding arXiv site: %v\n", err)
+			os.Exit(1)
+		}
+	case "remove":
+		if err := removeSite(sites, config, args); err != nil {
+			fmt.Printf("Error removing site: %v\n", err)
+			os.Exit(1)
+		}
+	case "undo":
+		if err := runUndo(sites, config, args); err != nil {
+			fmt.Printf("Error restoring site: %v\n", err)
+			os.Exit(1)
+		}
+	case "trash":
+		if err := runTrash(sites, config, args); err != nil {
+			fmt.Printf("Error managing trash: %v\n", err)
+			os.Exit(1)
+		}
+	case "alias":
+		if err := runAlias(sites, args); err != nil {
+			fmt.Printf("Error updating aliases: %v\n", err)
+			os.Exit(1)
+		}
+	case "snooze":
+		if err := snoozeSite(sites, args); err != nil {
+			fmt.Printf("Error snoozing site: %v\n", err)
+			os.Exit(1)
+		}
+	case "pause":
+		if err := pauseSites(sites, args, true); err != nil {
+			fmt.Printf("Error pausing sites: %v\n", err)
+			os.Exit(1)
+		}
+	case "resume":
+		if err := pauseSites(sites, args, false); err != nil {
+			fmt.Printf("Error resuming sites: %v\n", err)
+			os.Exit(1)
+		}
+	case "priority":
+		if err := setPriority(sites, args); err != nil {
+			fmt.Printf("Error setting priority: %v\n", err)
+			os.Exit(1)
+		}
+	case "templates":
+		if err := writeTemplates(config, args); err != nil {
+			fmt.Printf("Error writing templates: %v\n", err)
+			os.Exit(1)
+		}
+	case "retag":
+		if err := runRetag(sites, config, args); err != nil {
+			fmt.Printf("Error retagging sites: %v\n", err)
+			os.Exit(1)
+		}
+	case "search":
+		if err := runSearch(sites, config, args); err != nil {
+			fmt.Printf("Error searching: %v\n", err)
+			os.Exit(1)
+		}
+	case "read", "unread":
+		if err := markEntries(args, command == "read"); err != nil {
+			fmt.Printf("Error marking entries: %v\n", err)
+			os.Exit(1)
+		}
+	case "triage":
+		if err := runTriage(sites, args); err != nil {
+			fmt.Printf("Error triaging entries: %v\n", err)
+			os.Exit(1)
+		}
+	case "open":
+		if err := openEntry(args); err != nil {
+			fmt.Printf("Error opening entry: %v\n", err)
+			os.Exit(1)
+		}
+	case "browser":
+		if err := setBrowser(sites, args); err != nil {
+			fmt.Printf("Error setting browser fetch: %v\n", err)
+			os.Exit(1)
+		}
+	case "feed-type":
+		if err := setFeedType(sites, args); err != nil {
+			fmt.Printf("Error setting feed type: %v\n", err)
+			os.Exit(1)
+		}
+	case "stats":
+		if err := printStats(sites, config, args); err != nil {
+			fmt.Printf("Error printing stats: %v\n", err)
+			os.Exit(1)
+		}
+	case "errors":
+		listErrors(sites)
+	case "status":
+		printStatus(sites, config)
+	case "download":
+		if err := runDownload(sites, config, args); err != nil {
+			fmt.Printf("Error downloading enclosures: %v\n", err)
+			os.Exit(1)
+		}
+	case "star", "unstar":
+		if err := starEntries(args, command == "star"); err != nil {
+			fmt.Printf("Error starring entries: %v\n", err)
+			os.Exit(1)
+		}
+	case "next":
+		if err := runNext(sites, args); err != nil {
+			fmt.Printf("Error opening next entry: %v\n", err)
+			os.Exit(1)
+		}
+	case "starred":
+		if err := listStarred(args); err != nil {
+			fmt.Printf("Error listing starred entries: %v\n", err)
+			os.Exit(1)
+		}
+	case "note":
+		if err := runNote(sites, args); err != nil {
+			fmt.Printf("Error updating note: %v\n", err)
+			os.Exit(1)
+		}
+	case "export-notes":
+		if err := exportNotes(sites, config, args); err != nil {
+			fmt.Printf("Error exporting notes: %v\n", err)
+			os.Exit(1)
+		}
+	case "export-opml":
+		if err := exportOPML(sites, config, args); err != nil {
+			fmt.Printf("Error exporting OPML: %v\n", err)
+			os.Exit(1)
+		}
+	case "export-site":
+		if err := exportSite(sites, args); err != nil {
+			fmt.Printf("Error exporting site: %v\n", err)
+			os.Exit(1)
+		}
+	case "import-site":
+		if err := importSite(sites, config, args); err != nil {
+			fmt.Printf("Error importing site: %v\n", err)
+			os.Exit(1)
+		}
+	case "favicons":
+		if err := runFavicons(sites, args); err != nil {
+			fmt.Printf("Error fetching favicons: %v\n", err)
+			os.Exit(1)
+		}
+	case "import-opml":
+		if err := importOPML(sites, args); err != nil {
+			fmt.Printf("Error importing OPML: %v\n", err)
+			os.Exit(1)
+		}
+	case "import-newsblur":
+		if err := importNewsBlur(sites, args); err != nil {
+			fmt.Printf("Error importing NewsBlur export: %v\n", err)
+			os.Exit(1)
+		}
+	case "sync-freshrss":
+		if err := syncFreshRSS(sites, config); err != nil {
+			fmt.Printf("Error syncing with FreshRSS: %v\n", err)
+			os.Exit(1)
+		}
+	case "import-feedly":
+		if err := importFeedly(sites, args); err != nil {
+			fmt.Printf("Error importing Feedly export: %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Printf("Unknown command '%s'. Available commands: check, preview, history, prune, maintenance, serve, status, errors, stats, report, download, star, unstar, read, unread, open, search, templates, retag, starred, next, triage, note, add-arxiv, remove, undo, trash, alias, snooze, pause, resume, priority, feed-type, browser, export-notes, export-opml, import-opml, import-newsblur, import-feedly, sync-freshrss, export-site, import-site, secret, favicons\n", command)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const PREVIEW_CACHE_MAX_AGE = 30 * 24 * time.Hour
+
+// compactableStore is implemented by stores that can report their size and
+// reclaim free space.
+type compactableStore interface {
+	Store
+	size() (int64, error)
+	compact() error
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+func (s *jsonStore) size() (int64, error) {
+	return fileSize(s.sitesPath) + fileSize(s.entriesPath) + fileSize(s.trashPath()), nil
+}
+
+// Saving already rewrote the JSON files, so there is nothing left to do.
+func (s *jsonStore) compact() error {
+	return nil
+}
+
+func (s *boltStore) size() (int64, error) {
+	return fileSize(s.path), nil
+}
+
+func (s *boltStore) compact() error {
+	src, err := s.open()
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.path + ".compact"
+	os.Remove(tmpPath)
+	dst, err := bolt.Open(tmpPath, 0644, &bolt.Options{Timeout: BOLT_OPEN_TIMEOUT})
+	if err != nil {
+		src.Close()
+		return fmt.Errorf("error creating compacted database: %w", err)
+	}
+
+	err = bolt.Compact(dst, src, 0)
+	dst.Close()
+	src.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("error compacting database: %w", err)
+	}
+
+	return os.Rename(tmpPath, s.path)
+}
+
+func (s *postgresStore) size() (int64, error) {
+	db, err := postgresPool(s.dsn)
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	err = db.QueryRow(`SELECT COALESCE(SUM(pg_total_relation_size(c.oid)), 0)::BIGINT FROM pg_class c
+		WHERE c.relkind = 'r' AND c.relname IN ('sites', 'entries', 'validators', 'meta')
+		AND c.relnamespace = to_regnamespace(current_schema())`).Scan(&size)
+	if err != nil {
+		return 0, fmt.Errorf("error reading database size: %w", err)
+	}
+	return size, nil
+}
+
+func (s *postgresStore) compact() error {
+	db, err := postgresPool(s.dsn)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range []string{"sites", "entries", "validators", "meta"} {
+		if _, err := db.Exec(`VACUUM (FULL, ANALYZE) ` + table); err != nil {
+			return fmt.Errorf("error vacuuming %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// removeOrphans drops entries and pruned markers whose site no longer exists
+// and isn't waiting in the trash. Starred and annotated entries are kept.
+func (s *EntryStore) removeOrphans(known map[string]bool) (entries, pruned int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.Entries[:0]
+	for _, entry := range s.Entries {
+		if known[entry.Site] || entry.Saved || entry.Note != "" {
+			kept = append(kept, entry)
+			continue
+		}
+		entries++
+	}
+	s.Entries = kept
+
+	keptKeys := s.Pruned[:0]
+	for _, key := range s.Pruned {
+		site, _, _ := strings.Cut(key, "\x00")
+		if known[site] {
+			keptKeys = append(keptKeys, key)
+			continue
+		}
+		pruned++
+	}
+	s.Pruned = keptKeys
+	if len(s.Pruned) == 0 {
+		s.Pruned = nil
+	}
+
+	return entries, pruned
+}
+
+// cleanPreviewCache removes cached previews that haven't been refreshed in a while.
+func cleanPreviewCache() (int, int64) {
+	path, err := previewCachePath("")
+	if err != nil {
+		return 0, 0
+	}
+
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(path), "*.json"))
+	if err != nil {
+		return 0, 0
+	}
+
+	removed, freed := 0, int64(0)
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil || time.Since(info.ModTime()) < PREVIEW_CACHE_MAX_AGE {
+			continue
+		}
+		if os.Remove(file) == nil {
+			removed++
+			freed += info.Size()
+		}
+	}
+	return removed, freed
+}
+
+func runMaintenance(sites SiteData, config Config, args []string) error {
+	if len(args) != 1 || args[0] != "compact" {
+		return fmt.Errorf("usage: maintenance compact")
+	}
+
+	store := openStore(databaseFile, entriesFile)
+	compactable, ok := store.(compactableStore)
+	if !ok {
+		return fmt.Errorf("this database can't be compacted")
+	}
+
+	before, err := compactable.size()
+	if err != nil {
+		return err
+	}
+
+	entries, err := store.LoadEntries()
+	if err != nil {
+		return err
+	}
+	trash, err := readTrash(store, config)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool, len(sites)+len(trash))
+	for name := range sites {
+		known[name] = true
+	}
+	for _, item := range trash {
+		known[item.Name] = true
+	}
+
+	orphanedEntries, orphanedPruned := entries.removeOrphans(known)
+	fmt.Printf("→ Removed %d entries and %d pruned markers of deleted sites\n", orphanedEntries, orphanedPruned)
+
+	if err := store.SaveSites(sites); err != nil {
+		return fmt.Errorf("saving sites: %w", err)
+	}
+	if err := store.SaveEntries(entries); err != nil {
+		return fmt.Errorf("saving entries: %w", err)
+	}
+	if err := compactable.compact(); err != nil {
+		return err
+	}
+
+	after, err := compactable.size()
+	if err != nil {
+		return err
+	}
+
+	cached, cacheFreed := cleanPreviewCache()
+	if cached > 0 {
+		fmt.Printf("→ Removed %d stale preview cache files (%s)\n", cached, formatBytes(cacheFreed))
+	}
+
+	reclaimed := before - after
+	if reclaimed < 0 {
+		reclaimed = 0
+	}
+	fmt.Printf("✓ Database compacted: %s → %s (%s reclaimed)\n", formatBytes(before), formatBytes(after), formatBytes(reclaimed+cacheFreed))
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const MAX_DERIVED_TITLE_LENGTH = 80
+
+var mastodonHandlePattern = regexp.MustCompile(`^@?([A-Za-z0-9_]+)@([A-Za-z0-9.-]+\.[A-Za-z]{2,})$`)
+
+func mastodonFeedURL(handle string) (string, bool) {
+	match := mastodonHandlePattern.FindStringSubmatch(strings.TrimSpace(handle))
+	if match == nil {
+		return "", false
+	}
+	return fmt.Sprintf("https://%s/@%s.rss", strings.ToLower(match[2]), match[1]), true
+}
+
+func deriveEntryTitle(content string, mediaCount int) string {
+	text := htmlToText(content)
+	if text == "" {
+		switch mediaCount {
+		case 0:
+			return ""
+		case 1:
+			return "[media post]"
+		default:
+			return fmt.Sprintf("[media post, %d attachments]", mediaCount)
+		}
+	}
+
+	if utf8.RuneCountInString(text) <= MAX_DERIVED_TITLE_LENGTH {
+		return text
+	}
+
+	runes := []rune(text)
+	cut := MAX_DERIVED_TITLE_LENGTH
+	for i := cut; i > MAX_DERIVED_TITLE_LENGTH/2; i-- {
+		if runes[i] == ' ' {
+			cut = i
+			break
+		}
+	}
+	return strings.TrimSpace(string(runes[:cut])) + "…"
+}
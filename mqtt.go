@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_MQTT_TOPIC     = "rss-tracker/entries"
+	DEFAULT_MQTT_CLIENT_ID = "rss-tracker"
+	MQTT_KEEPALIVE_SECONDS = 60
+
+	mqttConnect    = 0x10
+	mqttConnack    = 0x20
+	mqttPublish    = 0x30
+	mqttPuback     = 0x40
+	mqttDisconnect = 0xE0
+)
+
+type MQTTConfig struct {
+	Broker   string `json:"broker"`
+	Topic    string `json:"topic,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	QoS      int    `json:"qos,omitempty"`
+	Retain   bool   `json:"retain,omitempty"`
+}
+
+type MQTTPublisher struct {
+	config MQTTConfig
+}
+
+type mqttConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	nextID uint16
+}
+
+func (c MQTTConfig) validate() error {
+	if c.Broker == "" {
+		return fmt.Errorf("mqtt requires broker")
+	}
+	if _, err := url.Parse(c.Broker); err != nil {
+		return fmt.Errorf("mqtt broker: %w", err)
+	}
+	if c.QoS < 0 || c.QoS > 1 {
+		return fmt.Errorf("mqtt qos must be 0 or 1")
+	}
+	if strings.ContainsAny(strings.ReplaceAll(c.Topic, "{site}", ""), "+#") {
+		return fmt.Errorf("mqtt topic cannot contain wildcards")
+	}
+	return nil
+}
+
+// The topic may contain {site}, replaced by the site name with topic
+// separators and wildcards removed.
+func (c MQTTConfig) topicFor(site string) string {
+	topic := c.Topic
+	if topic == "" {
+		topic = DEFAULT_MQTT_TOPIC
+	}
+	return strings.ReplaceAll(topic, "{site}", strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(site))
+}
+
+func (p *MQTTPublisher) Name() string {
+	return "mqtt"
+}
+
+func (p *MQTTPublisher) Publish(events []EntryEvent) error {
+	conn, err := dialMQTT(p.config)
+	if err != nil {
+		return err
+	}
+	defer conn.close()
+
+	for _, event := range events {
+		payload, err := event.payload()
+		if err != nil {
+			return err
+		}
+		if err := conn.publish(p.config.topicFor(event.Site), payload, p.config.QoS, p.config.Retain); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func mqttString(buf *bytes.Buffer, value string) {
+	binary.Write(buf, binary.BigEndian, uint16(len(value)))
+	buf.WriteString(value)
+}
+
+func mqttPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if length == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+func dialMQTT(config MQTTConfig) (*mqttConn, error) {
+	broker, err := url.Parse(config.Broker)
+	if err != nil {
+		return nil, fmt.Errorf("invalid broker: %w", err)
+	}
+
+	host := broker.Host
+	var conn net.Conn
+	switch broker.Scheme {
+	case "tcp", "mqtt", "":
+		if broker.Port() == "" {
+			host = net.JoinHostPort(broker.Hostname(), "1883")
+		}
+		conn, err = net.DialTimeout("tcp", host, httpTimeout)
+	case "ssl", "tls", "mqtts":
+		if broker.Port() == "" {
+			host = net.JoinHostPort(broker.Hostname(), "8883")
+		}
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: httpTimeout}, "tcp", host, &tls.Config{ServerName: broker.Hostname()})
+	default:
+		return nil, fmt.Errorf("unsupported broker scheme '%s'", broker.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connecting to broker: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(httpTimeout))
+
+	clientID := config.ClientID
+	if clientID == "" {
+		clientID = DEFAULT_MQTT_CLIENT_ID
+	}
+
+	var body bytes.Buffer
+	mqttString(&body, "MQTT")
+	body.WriteByte(4)
+	flags := byte(0x02)
+	if config.Username != "" {
+		flags |= 0x80
+		if config.Password != "" {
+			flags |= 0x40
+		}
+	}
+	body.WriteByte(flags)
+	binary.Write(&body, binary.BigEndian, uint16(MQTT_KEEPALIVE_SECONDS))
+	mqttString(&body, clientID)
+	if config.Username != "" {
+		mqttString(&body, config.Username)
+		if config.Password != "" {
+			mqttString(&body, config.Password)
+		}
+	}
+
+	c := &mqttConn{conn: conn, reader: bufio.NewReader(conn), nextID: 1}
+	if _, err := conn.Write(mqttPacket(mqttConnect, body.Bytes())); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("sending CONNECT: %w", err)
+	}
+
+	header, ack, err := c.readPacket()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("reading CONNACK: %w", err)
+	}
+	if header&0xF0 != mqttConnack || len(ack) < 2 {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected reply to CONNECT")
+	}
+	if ack[1] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("broker refused connection (code %d)", ack[1])
+	}
+
+	return c, nil
+}
+
+func (c *mqttConn) readPacket() (byte, []byte, error) {
+	header, err := c.reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, multiplier := 0, 1
+	for i := 0; i < 4; i++ {
+		digit, err := c.reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7F) * multiplier
+		if digit&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+func (c *mqttConn) publish(topic string, payload []byte, qos int, retain bool) error {
+	header := byte(mqttPublish) | byte(qos<<1)
+	if retain {
+		header |= 0x01
+	}
+
+	var body bytes.Buffer
+	mqttString(&body, topic)
+	id := c.nextID
+	if qos > 0 {
+		binary.Write(&body, binary.BigEndian, id)
+		c.nextID++
+	}
+	body.Write(payload)
+
+	c.conn.SetDeadline(time.Now().Add(httpTimeout))
+	if _, err := c.conn.Write(mqttPacket(header, body.Bytes())); err != nil {
+		return fmt.Errorf("sending PUBLISH: %w", err)
+	}
+	if qos == 0 {
+		return nil
+	}
+
+	packetType, ack, err := c.readPacket()
+	if err != nil {
+		return fmt.Errorf("reading PUBACK: %w", err)
+	}
+	if packetType&0xF0 != mqttPuback || len(ack) < 2 || binary.BigEndian.Uint16(ack) != id {
+		return fmt.Errorf("unexpected reply to PUBLISH")
+	}
+	return nil
+}
+
+func (c *mqttConn) close() {
+	c.conn.Write([]byte{mqttDisconnect, 0})
+	c.conn.Close()
+}
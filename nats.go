@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_NATS_SUBJECT = "rss-tracker.entries"
+	NATS_CLIENT_NAME     = "rss-tracker"
+)
+
+type NATSConfig struct {
+	URL      string `json:"url"`
+	Subject  string `json:"subject,omitempty"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+type NATSPublisher struct {
+	config NATSConfig
+}
+
+func (c NATSConfig) validate() error {
+	if c.URL == "" {
+		return fmt.Errorf("nats requires url")
+	}
+	if _, err := url.Parse(c.URL); err != nil {
+		return fmt.Errorf("nats url: %w", err)
+	}
+	if strings.ContainsAny(strings.ReplaceAll(c.Subject, "{site}", ""), "*> \t") {
+		return fmt.Errorf("nats subject cannot contain wildcards or whitespace")
+	}
+	return nil
+}
+
+// The subject may contain {site}, replaced by the site name with token
+// separators, wildcards and whitespace removed.
+func (c NATSConfig) subjectFor(site string) string {
+	subject := c.Subject
+	if subject == "" {
+		subject = DEFAULT_NATS_SUBJECT
+	}
+	return strings.ReplaceAll(subject, "{site}", strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_", "\t", "_").Replace(site))
+}
+
+func (p *NATSPublisher) Name() string {
+	return "nats"
+}
+
+func (p *NATSPublisher) Publish(events []EntryEvent) error {
+	server, err := url.Parse(p.config.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+
+	host := server.Host
+	if server.Port() == "" {
+		host = net.JoinHostPort(server.Hostname(), "4222")
+	}
+
+	conn, err := net.DialTimeout("tcp", host, httpTimeout)
+	if err != nil {
+		return fmt.Errorf("connecting to server: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(httpTimeout))
+
+	reader := bufio.NewReader(conn)
+	info, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(info, "INFO ") {
+		return fmt.Errorf("unexpected greeting from server")
+	}
+
+	var serverInfo struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(info), "INFO ")), &serverInfo)
+
+	if server.Scheme == "tls" || serverInfo.TLSRequired {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: server.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			return fmt.Errorf("TLS handshake: %w", err)
+		}
+		conn = tlsConn
+		reader = bufio.NewReader(conn)
+	}
+
+	options := map[string]any{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     NATS_CLIENT_NAME,
+		"lang":     "go",
+		"version":  "1",
+	}
+	switch {
+	case p.config.Token != "":
+		options["auth_token"] = p.config.Token
+	case p.config.User != "":
+		options["user"] = p.config.User
+		options["pass"] = p.config.Password
+	case server.User != nil:
+		options["user"] = server.User.Username()
+		options["pass"], _ = server.User.Password()
+	}
+	connectOptions, err := json.Marshal(options)
+	if err != nil {
+		return fmt.Errorf("error marshaling options: %w", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CONNECT %s\r\n", connectOptions)
+	for _, event := range events {
+		payload, err := event.payload()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "PUB %s %d\r\n%s\r\n", p.config.subjectFor(event.Site), len(payload), payload)
+	}
+	b.WriteString("PING\r\n")
+
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		return fmt.Errorf("sending events: %w", err)
+	}
+
+	// The server answers the PING only after processing everything before it,
+	// reporting auth or permission problems as -ERR on the way.
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("waiting for server: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("server error: %s", strings.Trim(strings.TrimPrefix(line, "-ERR"), " '"))
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+const (
+	NET_ERROR_DNS     = "dns"
+	NET_ERROR_REFUSED = "refused"
+)
+
+// A site whose name doesn't resolve is left out of checks for a while,
+// doubling with each failure in a row up to the maximum.
+const (
+	DNS_BACKOFF_BASE = time.Hour
+	DNS_BACKOFF_MAX  = 7 * 24 * time.Hour
+)
+
+// netError is a fetch failure whose cause says how long it is likely to
+// last: a name that doesn't resolve usually means the site is gone, while a
+// refused connection usually means the server is down for a moment.
+type netError struct {
+	kind string
+	host string
+	err  error
+}
+
+func (e *netError) Error() string {
+	if e.kind == NET_ERROR_DNS {
+		return fmt.Sprintf("DNS name not found: %s (the site may be gone)", e.host)
+	}
+	if e.host == "" {
+		return "connection refused (probably temporary)"
+	}
+	return fmt.Sprintf("connection refused by %s (probably temporary)", e.host)
+}
+
+func (e *netError) Unwrap() error {
+	return e.err
+}
+
+// classifyNetError returns err as a netError if its cause is one of the
+// kinds above, or nil.
+func classifyNetError(err error) *netError {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return &netError{kind: NET_ERROR_DNS, host: dnsErr.Name, err: err}
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		host := ""
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Addr != nil {
+			host = opErr.Addr.String()
+		}
+		return &netError{kind: NET_ERROR_REFUSED, host: host, err: err}
+	}
+	return nil
+}
+
+// explainNetError replaces err with its netError, if it has one.
+func explainNetError(err error) error {
+	if netErr := classifyNetError(err); netErr != nil {
+		return netErr
+	}
+	return err
+}
+
+func netErrorKind(err error) string {
+	var netErr *netError
+	if errors.As(err, &netErr) {
+		return netErr.kind
+	}
+	return ""
+}
+
+// backOff sets when checks next try a site after a failure of the given
+// kind. Only DNS failures back off; a refused connection or anything else is
+// retried on the normal schedule.
+func (s *Site) backOff(kind string, now time.Time) {
+	if kind != NET_ERROR_DNS {
+		s.RetryAfter = nil
+		return
+	}
+
+	streak := 0
+	for i := len(s.RecentFailures) - 1; i >= 0 && s.RecentFailures[i]; i-- {
+		streak++
+	}
+	delay := DNS_BACKOFF_BASE
+	for i := 1; i < streak && delay < DNS_BACKOFF_MAX; i++ {
+		delay *= 2
+	}
+	retry := now.Add(min(delay, DNS_BACKOFF_MAX))
+	s.RetryAfter = &retry
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	DIAL_TIMEOUT    = 30 * time.Second
+	DIAL_KEEP_ALIVE = 30 * time.Second
+	// USER_AGENT is what feeds, pages and robots.txt are fetched as, unless
+	// a site sets its own User-Agent header.
+	USER_AGENT = "rss-tracker/1.0 (+https://github.com/ahmed-hany94/RSS-Tracker)"
+)
+
+var (
+	ipVersion     string
+	fallbackDelay time.Duration
+
+	feedTransportsMu sync.Mutex
+	feedTransports   = make(map[string]*http.Transport)
+)
+
+func validateIPVersion(version string) error {
+	switch version {
+	case "", "4", "6":
+		return nil
+	}
+	return fmt.Errorf("ip version must be 4 or 6, got '%s'", version)
+}
+
+// feedTransport returns a shared transport that dials only the given address
+// family ("4", "6", or "" for both), so connections are still pooled.
+func feedTransport(version string) *http.Transport {
+	if version == "" {
+		version = ipVersion
+	}
+
+	feedTransportsMu.Lock()
+	defer feedTransportsMu.Unlock()
+
+	if transport, ok := feedTransports[version]; ok {
+		return transport
+	}
+
+	dialer := newFeedDialer(DIAL_TIMEOUT)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if version != "" {
+			network = "tcp" + version
+		}
+		return dialer.DialContext(ctx, network, dnsAddress(addr))
+	}
+
+	feedTransports[version] = transport
+	return transport
+}
+
+// newFeedClient returns a client for fetching feeds and enclosures that
+// follows the configured redirect policy and address family. A site's own
+// ip_version takes precedence over the global setting.
+func newFeedClient(timeout time.Duration, version string) *http.Client {
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     feedTransport(version),
+		CheckRedirect: redirectPolicy.check,
+	}
+}
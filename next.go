@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// runNext opens the highest scored unread entry, the oldest of those, and
+// marks it read.
+func runNext(sites SiteData, args []string) error {
+	fs := flag.NewFlagSet("next", flag.ExitOnError)
+	siteQuery := fs.String("site", "", "Only consider entries from this site (name or alias).")
+	tag := fs.String("tag", "", "Only consider entries from sites with this tag.")
+	printOnly := fs.Bool("print", false, "Print the entry instead of opening it in the browser.")
+	parseInterspersed(fs, args)
+
+	siteName := ""
+	if *siteQuery != "" {
+		name, err := resolveSiteName(sites, *siteQuery, bufio.NewReader(os.Stdin))
+		if err != nil {
+			return err
+		}
+		siteName = name
+	}
+
+	entries, err := readEntries()
+	if err != nil {
+		return err
+	}
+
+	unread := entries.list(func(e Entry) bool {
+		if e.Read || (siteName != "" && e.Site != siteName) {
+			return false
+		}
+		return *tag == "" || hasTag(sites[e.Site].Tags, *tag)
+	})
+	if len(unread) == 0 {
+		fmt.Println("(-_-) Nothing left to read")
+		return nil
+	}
+
+	sortForReading(unread)
+	entry := unread[0]
+
+	if !*printOnly {
+		if err := openInBrowser(entry.Link); err != nil {
+			return fmt.Errorf("opening %s: %w", entry.Link, err)
+		}
+	}
+
+	entries.update(func(e Entry) bool { return e.ID == entry.ID }, func(e *Entry) { e.Read = true })
+	if err := entries.save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("→ %s %s → %s%s\n   %s\n", entryLabel(entry), entry.Site, entryDisplayTitle(entry), formatAuthor(entry.Author), entry.Link)
+	fmt.Printf("%d unread left\n", len(unread)-1)
+	return nil
+}
+
+// sortForReading puts the highest scored entries first, the oldest of those
+// first.
+func sortForReading(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		if !entries[i].Discovered.Equal(entries[j].Discovered) {
+			return entries[i].Discovered.Before(entries[j].Discovered)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func runNote(sites SiteData, args []string) error {
+	fs := flag.NewFlagSet("note", flag.ExitOnError)
+	entryRef := fs.String("entry", "", "Attach the note to this entry (ID or link) instead of a site.")
+	clear := fs.Bool("clear", false, "Remove the note.")
+	rest := parseInterspersed(fs, args)
+
+	if *entryRef != "" {
+		return noteEntry(*entryRef, strings.Join(rest, " "), *clear)
+	}
+
+	if len(rest) == 0 {
+		return fmt.Errorf("usage: note <site> [text] | note -entry <id|link> [text]")
+	}
+
+	name, err := resolveSiteName(sites, rest[0], bufio.NewReader(os.Stdin))
+	if err != nil {
+		return err
+	}
+	site := sites[name]
+	text := strings.TrimSpace(strings.Join(rest[1:], " "))
+
+	switch {
+	case *clear:
+		site.Note = ""
+	case text == "":
+		if site.Note == "" {
+			fmt.Printf("%s has no note\n", name)
+		} else {
+			fmt.Printf("%s ✎ %s\n", name, site.Note)
+		}
+		return nil
+	default:
+		site.Note = text
+	}
+
+	sites[name] = site
+	if err := saveSites(sites); err != nil {
+		return fmt.Errorf("saving sites: %w", err)
+	}
+
+	if *clear {
+		fmt.Printf("✓ Cleared note on %s\n", name)
+	} else {
+		fmt.Printf("✓ %s ✎ %s\n", name, site.Note)
+	}
+	return nil
+}
+
+func noteEntry(ref, text string, clear bool) error {
+	entries, err := readEntries()
+	if err != nil {
+		return err
+	}
+
+	entry, err := findEntry(entries, ref)
+	if err != nil {
+		return err
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" && !clear {
+		if entry.Note == "" {
+			fmt.Printf("%s %s has no note\n", entryLabel(entry), entryDisplayTitle(entry))
+		} else {
+			fmt.Printf("%s %s ✎ %s\n", entryLabel(entry), entryDisplayTitle(entry), entry.Note)
+		}
+		return nil
+	}
+
+	entries.update(func(e Entry) bool {
+		return e.ID == entry.ID
+	}, func(e *Entry) {
+		e.Note = text
+		// Annotated entries are kept like starred ones.
+		if text != "" {
+			e.Saved = true
+		}
+	})
+
+	if err := entries.save(); err != nil {
+		return err
+	}
+
+	if clear {
+		fmt.Printf("✓ Cleared note on %s %s\n", entryLabel(entry), entryDisplayTitle(entry))
+	} else {
+		fmt.Printf("✓ %s %s ✎ %s\n", entryLabel(entry), entryDisplayTitle(entry), text)
+	}
+	return nil
+}
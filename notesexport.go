@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const DEFAULT_NOTE_FILENAME = "{{.Date}} {{.Title}}"
+
+type NotesExportConfig struct {
+	Vault    string `json:"vault"`
+	Folder   string `json:"folder,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+type noteFilenameData struct {
+	ID    int64
+	Title string
+	Site  string
+	Date  string
+}
+
+var unsafeNoteChars = regexp.MustCompile(`[\\/:*?"<>|#^\[\]]+`)
+
+func yamlString(value string) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+func renderEntryNote(entry Entry, siteTags []string) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("---\n")
+	fmt.Fprintf(&buf, "title: %s\n", yamlString(entryDisplayTitle(entry)))
+	fmt.Fprintf(&buf, "source: %s\n", yamlString(entry.Site))
+	fmt.Fprintf(&buf, "url: %s\n", yamlString(entry.Link))
+	if !entry.Published.IsZero() {
+		fmt.Fprintf(&buf, "published: %s\n", entry.Published.Format(time.RFC3339))
+	}
+	fmt.Fprintf(&buf, "discovered: %s\n", entry.Discovered.Format(time.RFC3339))
+
+	tags := append(append([]string{}, siteTags...), entry.Tags...)
+	if len(tags) > 0 {
+		buf.WriteString("tags:\n")
+		for _, tag := range tags {
+			fmt.Fprintf(&buf, "  - %s\n", yamlString(strings.ReplaceAll(tag, " ", "-")))
+		}
+	}
+	if entry.Saved {
+		buf.WriteString("starred: true\n")
+	}
+	buf.WriteString("---\n\n")
+
+	fmt.Fprintf(&buf, "# [%s](%s)\n", entryDisplayTitle(entry), entry.Link)
+	if entry.Note != "" {
+		fmt.Fprintf(&buf, "\n> %s\n", entry.Note)
+	}
+	if text := htmlToText(entry.Content); text != "" {
+		fmt.Fprintf(&buf, "\n%s\n", text)
+	}
+
+	return buf.Bytes()
+}
+
+func noteFilename(tmpl *template.Template, entry Entry) (string, error) {
+	date := entry.Published
+	if date.IsZero() {
+		date = entry.Discovered
+	}
+
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, noteFilenameData{
+		ID:    entry.ID,
+		Title: entryDisplayTitle(entry),
+		Site:  entry.Site,
+		Date:  date.Local().Format("2006-01-02"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("rendering filename: %w", err)
+	}
+
+	name := strings.TrimSpace(unsafeNoteChars.ReplaceAllString(buf.String(), " "))
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		name = fmt.Sprintf("entry %d", entry.ID)
+	}
+	return strings.TrimSuffix(name, ".md") + ".md", nil
+}
+
+func uniqueNotePath(dir, filename string) string {
+	path := filepath.Join(dir, filename)
+	base := strings.TrimSuffix(filename, ".md")
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s (%d).md", base, i))
+	}
+}
+
+func exportNotes(sites SiteData, config Config, args []string) error {
+	notesConfig := NotesExportConfig{}
+	if config.NotesExport != nil {
+		notesConfig = *config.NotesExport
+	}
+	if notesConfig.Filename == "" {
+		notesConfig.Filename = DEFAULT_NOTE_FILENAME
+	}
+
+	fs := flag.NewFlagSet("export-notes", flag.ExitOnError)
+	vault := fs.String("vault", notesConfig.Vault, "Vault directory to write notes into.")
+	folder := fs.String("folder", notesConfig.Folder, "Folder inside the vault.")
+	filename := fs.String("filename", notesConfig.Filename, "Filename template ({{.Date}}, {{.Title}}, {{.Site}}, {{.ID}}).")
+	match := fs.String("match", "", "Export entries whose title or content contains any of these comma-separated keywords instead of starred ones.")
+	site := fs.String("site", "", "Only export entries from this site.")
+	tag := fs.String("tag", "", "Only export entries with this tag (entry or site tag).")
+	fs.Parse(args)
+
+	if *vault == "" {
+		return fmt.Errorf("no vault configured (set notes_export.vault in config or pass -vault)")
+	}
+
+	tmpl, err := template.New("filename").Parse(*filename)
+	if err != nil {
+		return fmt.Errorf("invalid filename template: %w", err)
+	}
+
+	if name, exists := siteForAlias(sites, *site); exists {
+		*site = name
+	}
+
+	keywords := parseTags(*match)
+	selected := func(e Entry) bool {
+		if e.ExportedAt != nil {
+			return false
+		}
+		if *site != "" && !strings.EqualFold(e.Site, *site) {
+			return false
+		}
+		if *tag != "" && !containsFold(e.Tags, *tag) && !containsFold(sites[e.Site].Tags, *tag) {
+			return false
+		}
+		if len(keywords) > 0 {
+			return containsAnyFold(e.Title+"\n"+htmlToText(e.Content), keywords)
+		}
+		return e.Saved
+	}
+
+	entries, err := readEntries()
+	if err != nil {
+		return err
+	}
+
+	pending := entries.list(selected)
+	if len(pending) == 0 {
+		fmt.Println("No new entries to export")
+		return nil
+	}
+
+	dir := filepath.Join(*vault, *folder)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+
+	// Notes written before a failure stay marked as exported, so the next
+	// run doesn't write them again.
+	exported := 0
+	var exportErr error
+	for _, entry := range pending {
+		name, err := noteFilename(tmpl, entry)
+		if err != nil {
+			exportErr = err
+			break
+		}
+
+		path := uniqueNotePath(dir, name)
+		if err := os.WriteFile(path, renderEntryNote(entry, sites[entry.Site].Tags), 0644); err != nil {
+			exportErr = fmt.Errorf("error writing note: %w", err)
+			break
+		}
+
+		now := time.Now()
+		entries.update(func(e Entry) bool {
+			return e.ID == entry.ID
+		}, func(e *Entry) {
+			e.ExportedAt = &now
+		})
+		exported++
+		fmt.Printf("%s → %s\n", entry.Site, path)
+	}
+
+	if exported > 0 {
+		if err := entries.save(); err != nil {
+			return err
+		}
+	}
+	if exportErr != nil {
+		return exportErr
+	}
+
+	fmt.Printf("\n✓ Exported %d notes to %s\n", exported, dir)
+	return nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const TELEGRAM_API_URL = "https://api.telegram.org"
+
+type NotifierConfig struct {
+	Type            string   `json:"type"`
+	WebhookURL      string   `json:"webhook_url,omitempty"`
+	URL             string   `json:"url,omitempty"`
+	BotToken        string   `json:"bot_token,omitempty"`
+	ChatID          string   `json:"chat_id,omitempty"`
+	Token           string   `json:"token,omitempty"`
+	Project         string   `json:"project,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	DigestThreshold int      `json:"digest_threshold,omitempty"`
+}
+
+type Notification struct {
+	SiteName  string
+	Title     string
+	Link      string
+	FeedType  FeedType
+	Priority  *PriorityRule
+	Notifiers []string
+	Score     int
+}
+
+func newEntryNotification(config Config, siteName string, site Site, entry Entry, feedType FeedType) Notification {
+	return Notification{
+		SiteName:  siteName,
+		Title:     entry.Title,
+		Link:      entry.Link,
+		FeedType:  feedType,
+		Priority:  config.matchPriorityRule(entry.Title),
+		Notifiers: config.notifiersFor(site),
+	}
+}
+
+type Notifier interface {
+	Send(message string) error
+}
+
+// TemplatedNotifier renders its own payload from a template, which gets the
+// message and the notifications behind it.
+type TemplatedNotifier interface {
+	SendNotifications(message string, notifications []Notification) error
+}
+
+type SlackNotifier struct {
+	WebhookURL string
+}
+
+type TelegramNotifier struct {
+	BotToken string
+	ChatID   string
+}
+
+type NtfyNotifier struct {
+	URL string
+}
+
+func newNotifier(config NotifierConfig) (Notifier, error) {
+	switch config.Type {
+	case "slack":
+		if config.WebhookURL == "" {
+			return nil, fmt.Errorf("slack notifier requires webhook_url")
+		}
+		return &SlackNotifier{WebhookURL: config.WebhookURL}, nil
+	case "telegram":
+		if config.BotToken == "" || config.ChatID == "" {
+			return nil, fmt.Errorf("telegram notifier requires bot_token and chat_id")
+		}
+		return &TelegramNotifier{BotToken: config.BotToken, ChatID: config.ChatID}, nil
+	case "ntfy":
+		if config.URL == "" {
+			return nil, fmt.Errorf("ntfy notifier requires url")
+		}
+		return &NtfyNotifier{URL: config.URL}, nil
+	case "taskwarrior":
+		return &TaskwarriorNotifier{Project: config.Project, Tags: config.Tags}, nil
+	case "todoist":
+		if config.Token == "" {
+			return nil, fmt.Errorf("todoist notifier requires token")
+		}
+		return &TodoistNotifier{Token: config.Token, URL: config.URL, Project: config.Project, Tags: config.Tags}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier type '%s'", config.Type)
+	}
+}
+
+func (n *SlackNotifier) Send(message string) error {
+	return n.SendNotifications(message, nil)
+}
+
+func (n *SlackNotifier) SendNotifications(message string, notifications []Notification) error {
+	payload, err := outputTemplates.renderText(TEMPLATE_SLACK, map[string]any{
+		"Text":          message,
+		"Notifications": notifications,
+	})
+	if err != nil {
+		return err
+	}
+	return postJSON(n.WebhookURL, json.RawMessage(payload))
+}
+
+func (n *TelegramNotifier) Send(message string) error {
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", TELEGRAM_API_URL, url.PathEscape(n.BotToken))
+	return postJSON(endpoint, map[string]any{
+		"chat_id":                  n.ChatID,
+		"text":                     message,
+		"disable_web_page_preview": true,
+	})
+}
+
+func (n *NtfyNotifier) Send(message string) error {
+	client := &http.Client{Timeout: httpTimeout}
+	resp, err := client.Post(n.URL, "text/plain; charset=utf-8", strings.NewReader(message))
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+func postJSON(endpoint string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshaling payload: %w", err)
+	}
+
+	client := &http.Client{Timeout: httpTimeout}
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+func formatNotification(n Notification) string {
+	return outputTemplates.renderMessage(TEMPLATE_NOTIFICATION, n)
+}
+
+func formatPriorityNotification(n Notification) string {
+	return fmt.Sprintf("🚨 PRIORITY (%s)\n%s", n.Priority.Name, formatNotification(n))
+}
+
+func formatDigest(notifications []Notification) string {
+	return outputTemplates.renderMessage(TEMPLATE_DIGEST_TEXT, map[string]any{"Notifications": notifications})
+}
+
+// sendMessage sends a message, along with the notifications it was made
+// from to notifiers that lay them out themselves.
+func sendMessage(notifier Notifier, message string, notifications ...Notification) error {
+	if templated, ok := notifier.(TemplatedNotifier); ok {
+		return templated.SendNotifications(message, notifications)
+	}
+	return notifier.Send(message)
+}
+
+func dispatchNotifications(out io.Writer, config Config, notifications []Notification) {
+	if len(notifications) == 0 || len(config.Notifiers) == 0 {
+		return
+	}
+
+	sort.Slice(notifications, func(i, j int) bool {
+		if notifications[i].Score != notifications[j].Score {
+			return notifications[i].Score > notifications[j].Score
+		}
+		return notifications[i].SiteName < notifications[j].SiteName
+	})
+
+	for _, name := range sortedNotifierNames(config) {
+		notifierConfig := config.Notifiers[name]
+		notifier, err := newNotifier(notifierConfig)
+		if err != nil {
+			fmt.Fprintf(out, "Notifier '%s' → ERROR: %v\n", name, err)
+			continue
+		}
+
+		if entryNotifier, ok := notifier.(EntryNotifier); ok {
+			for _, n := range notifications {
+				routed := routedTo(n.Notifiers, name)
+				if n.Priority != nil {
+					routed = len(n.Priority.Notifiers) > 0 && n.Priority.routesTo(name)
+				}
+				if !routed {
+					continue
+				}
+				if err := entryNotifier.SendEntry(n); err != nil {
+					fmt.Fprintf(out, "Notifier '%s' → ERROR: %v\n", name, err)
+					break
+				}
+			}
+			continue
+		}
+
+		var regular []Notification
+		for _, n := range notifications {
+			if n.Priority == nil {
+				if routedTo(n.Notifiers, name) {
+					regular = append(regular, n)
+				}
+				continue
+			}
+			if n.Priority.routesTo(name) {
+				if err := sendMessage(notifier, formatPriorityNotification(n), n); err != nil {
+					fmt.Fprintf(out, "Notifier '%s' → ERROR: %v\n", name, err)
+				}
+			}
+		}
+
+		if len(regular) == 0 {
+			continue
+		}
+
+		if len(regular) >= config.digestThreshold(notifierConfig) {
+			if err := sendMessage(notifier, formatDigest(regular), regular...); err != nil {
+				fmt.Fprintf(out, "Notifier '%s' → ERROR: %v\n", name, err)
+			}
+			continue
+		}
+
+		for _, n := range regular {
+			if err := sendMessage(notifier, formatNotification(n), n); err != nil {
+				fmt.Fprintf(out, "Notifier '%s' → ERROR: %v\n", name, err)
+				break
+			}
+		}
+	}
+}
+
+func routedTo(names []string, notifierName string) bool {
+	for _, name := range names {
+		if name == notifierName {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedNotifierNames(config Config) []string {
+	names := make([]string, 0, len(config.Notifiers))
+	for name := range config.Notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
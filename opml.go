@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+type OPML struct {
+	XMLName xml.Name    `xml:"opml"`
+	Version string      `xml:"version,attr"`
+	Head    OPMLHead    `xml:"head"`
+	Body    []OPMLEntry `xml:"body>outline"`
+}
+
+type OPMLHead struct {
+	Title       string `xml:"title"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type OPMLEntry struct {
+	Text        string      `xml:"text,attr"`
+	Title       string      `xml:"title,attr,omitempty"`
+	Type        string      `xml:"type,attr,omitempty"`
+	XMLURL      string      `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL     string      `xml:"htmlUrl,attr,omitempty"`
+	Description string      `xml:"description,attr,omitempty"`
+	Outlines    []OPMLEntry `xml:"outline"`
+}
+
+type opmlFolder struct {
+	children map[string]*opmlFolder
+	feeds    []OPMLEntry
+}
+
+func newOPMLFolder() *opmlFolder {
+	return &opmlFolder{children: make(map[string]*opmlFolder)}
+}
+
+func (f *opmlFolder) folder(path string) *opmlFolder {
+	current := f
+	for _, part := range strings.Split(path, "/") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		child, ok := current.children[part]
+		if !ok {
+			child = newOPMLFolder()
+			current.children[part] = child
+		}
+		current = child
+	}
+	return current
+}
+
+func (f *opmlFolder) outlines() []OPMLEntry {
+	names := make([]string, 0, len(f.children))
+	for name := range f.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var outlines []OPMLEntry
+	for _, name := range names {
+		outlines = append(outlines, OPMLEntry{
+			Text:     name,
+			Title:    name,
+			Outlines: f.children[name].outlines(),
+		})
+	}
+
+	sort.Slice(f.feeds, func(i, j int) bool { return f.feeds[i].Text < f.feeds[j].Text })
+	return append(outlines, f.feeds...)
+}
+
+func buildOPML(sites SiteData, config Config) OPML {
+	root := newOPMLFolder()
+
+	for name, site := range sites {
+		if len(site.Command) > 0 {
+			continue
+		}
+		feedURL, err := resolveFeedURL(site, config)
+		if err != nil {
+			continue
+		}
+
+		outline := OPMLEntry{
+			Text:        name,
+			Title:       name,
+			Type:        "rss",
+			XMLURL:      feedURL,
+			HTMLURL:     site.SiteURL,
+			Description: site.Note,
+		}
+
+		if len(site.Tags) == 0 {
+			root.feeds = append(root.feeds, outline)
+			continue
+		}
+		for _, tag := range site.Tags {
+			folder := root.folder(tag)
+			folder.feeds = append(folder.feeds, outline)
+		}
+	}
+
+	return OPML{
+		Version: "2.0",
+		Head: OPMLHead{
+			Title:       "RSS Tracker subscriptions",
+			DateCreated: time.Now().Format(time.RFC1123Z),
+		},
+		Body: root.outlines(),
+	}
+}
+
+func exportOPML(sites SiteData, config Config, args []string) error {
+	var out io.Writer = os.Stdout
+	if len(args) > 0 && args[0] != "-" {
+		file, err := os.Create(args[0])
+		if err != nil {
+			return fmt.Errorf("error creating file: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	data, err := xml.MarshalIndent(buildOPML(sites, config), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling OPML: %w", err)
+	}
+
+	if _, err := fmt.Fprintf(out, "%s%s\n", xml.Header, data); err != nil {
+		return fmt.Errorf("error writing OPML: %w", err)
+	}
+
+	return nil
+}
+
+func collectOPMLFeeds(outlines []OPMLEntry, path []string, visit func(OPMLEntry, string)) {
+	for _, outline := range outlines {
+		if outline.XMLURL != "" {
+			visit(outline, strings.Join(path, "/"))
+			continue
+		}
+
+		name := outline.Title
+		if name == "" {
+			name = outline.Text
+		}
+		collectOPMLFeeds(outline.Outlines, append(path, name), visit)
+	}
+}
+
+// importedFeed is a feed read from another reader's export. Its tag is the
+// folder it was in, with nested folders joined by "/".
+type importedFeed struct {
+	Title   string
+	URL     string
+	SiteURL string
+	Note    string
+	Tag     string
+}
+
+func importOPML(sites SiteData, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: import-opml <file.opml>")
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+
+	feeds, err := parseOPMLFeeds(data)
+	if err != nil {
+		return err
+	}
+	return importFeeds(sites, feeds)
+}
+
+func parseOPMLFeeds(data []byte) ([]importedFeed, error) {
+	var opml OPML
+	if err := xml.Unmarshal(data, &opml); err != nil {
+		return nil, fmt.Errorf("error parsing OPML: %w", err)
+	}
+
+	var feeds []importedFeed
+	collectOPMLFeeds(opml.Body, nil, func(outline OPMLEntry, tag string) {
+		title := outline.Title
+		if title == "" {
+			title = outline.Text
+		}
+		feeds = append(feeds, importedFeed{
+			Title:   title,
+			URL:     outline.XMLURL,
+			SiteURL: outline.HTMLURL,
+			Note:    outline.Description,
+			Tag:     tag,
+		})
+	})
+	return feeds, nil
+}
+
+func importFeeds(sites SiteData, feeds []importedFeed) error {
+	added, updated := addImportedFeeds(sites, feeds)
+	if added == 0 && updated == 0 {
+		fmt.Println("Nothing to import")
+		return nil
+	}
+
+	if err := saveSites(sites); err != nil {
+		return fmt.Errorf("saving sites: %w", err)
+	}
+
+	fmt.Printf("✓ Imported %d new sites, updated tags on %d existing sites\n", added, updated)
+	return nil
+}
+
+// addImportedFeeds adds the feeds that aren't tracked yet and the tags that
+// are missing on those that are.
+func addImportedFeeds(sites SiteData, feeds []importedFeed) (added, updated int) {
+	byURL := make(map[string]string, len(sites))
+	for name, site := range sites {
+		byURL[site.RSSUrl] = name
+	}
+
+	addedSites := make(map[string]bool)
+	updatedSites := make(map[string]bool)
+	for _, feed := range feeds {
+		name, exists := byURL[feed.URL]
+		if !exists {
+			name = feed.Title
+			if name == "" {
+				name = feed.URL
+			}
+			if _, taken := sites[name]; taken {
+				name = fmt.Sprintf("%s (%s)", name, feed.URL)
+			}
+			sites[name] = Site{RSSUrl: feed.URL, SiteURL: feed.SiteURL, Note: feed.Note}
+			byURL[feed.URL] = name
+			addedSites[name] = true
+		}
+
+		if feed.Tag == "" {
+			continue
+		}
+
+		site := sites[name]
+		tagged := false
+		for _, existing := range site.Tags {
+			if existing == feed.Tag {
+				tagged = true
+				break
+			}
+		}
+		if tagged {
+			continue
+		}
+		site.Tags = append(site.Tags, feed.Tag)
+		sites[name] = site
+		if exists && !addedSites[name] {
+			updatedSites[name] = true
+		}
+	}
+
+	return len(addedSites), len(updatedSites)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	OUTPUT_APPEND   = "append"
+	OUTPUT_TRUNCATE = "truncate"
+)
+
+// checkOutput is where a check writes its report: the console, a file, or
+// both.
+type checkOutput struct {
+	io.Writer
+	file *os.File
+}
+
+// openCheckOutput opens the output file of a check, if any. Each run appended
+// to a file starts with a line saying when it ran.
+func openCheckOutput(path, mode string, console bool) (*checkOutput, error) {
+	if path == "" {
+		if !console {
+			return nil, fmt.Errorf("-no-console needs -output-file")
+		}
+		return &checkOutput{Writer: os.Stdout}, nil
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch mode {
+	case OUTPUT_APPEND:
+		flags |= os.O_APPEND
+	case OUTPUT_TRUNCATE:
+		flags |= os.O_TRUNC
+	default:
+		return nil, fmt.Errorf("-output-mode must be \"%s\" or \"%s\", got '%s'", OUTPUT_APPEND, OUTPUT_TRUNCATE, mode)
+	}
+
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening output file: %w", err)
+	}
+	fmt.Fprintf(file, "=== %s ===\n", time.Now().Format("2006-01-02 15:04:05 MST"))
+
+	out := &checkOutput{Writer: file, file: file}
+	if console {
+		out.Writer = io.MultiWriter(os.Stdout, file)
+	}
+	return out, nil
+}
+
+func (o *checkOutput) Close() error {
+	if o.file == nil {
+		return nil
+	}
+	return o.file.Close()
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	POSTGRES_MAX_OPEN_CONNS = 10
+	POSTGRES_MAX_IDLE_CONNS = 5
+	POSTGRES_CONN_LIFETIME  = 30 * time.Minute
+
+	// Arbitrary key for the advisory lock that serialises migrations.
+	POSTGRES_MIGRATION_LOCK = 726573
+)
+
+var postgresMigrations = []string{
+	`CREATE TABLE sites (
+		owner TEXT NOT NULL DEFAULT '',
+		name  TEXT NOT NULL,
+		data  JSONB NOT NULL,
+		PRIMARY KEY (owner, name)
+	);
+	CREATE TABLE entries (
+		owner TEXT NOT NULL DEFAULT '',
+		id    BIGINT NOT NULL,
+		site  TEXT NOT NULL,
+		data  JSONB NOT NULL,
+		PRIMARY KEY (owner, id)
+	);
+	CREATE INDEX entries_site_idx ON entries (owner, site);
+	CREATE TABLE validators (
+		owner         TEXT NOT NULL DEFAULT '',
+		site          TEXT NOT NULL,
+		etag          TEXT NOT NULL DEFAULT '',
+		last_modified TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (owner, site)
+	);
+	CREATE TABLE meta (
+		owner TEXT NOT NULL DEFAULT '',
+		key   TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (owner, key)
+	);`,
+}
+
+var (
+	postgresPoolsMu sync.Mutex
+	postgresPools   = make(map[string]*sql.DB)
+)
+
+// postgresStore keeps state in PostgreSQL. Rows are scoped by owner so the
+// users of a multi-user server can share one database.
+type postgresStore struct {
+	dsn   string
+	owner string
+}
+
+func isPostgresDSN(database string) bool {
+	return strings.HasPrefix(database, "postgres://") || strings.HasPrefix(database, "postgresql://")
+}
+
+func postgresPool(dsn string) (*sql.DB, error) {
+	postgresPoolsMu.Lock()
+	defer postgresPoolsMu.Unlock()
+
+	if db, ok := postgresPools[dsn]; ok {
+		return db, nil
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+	db.SetMaxOpenConns(POSTGRES_MAX_OPEN_CONNS)
+	db.SetMaxIdleConns(POSTGRES_MAX_IDLE_CONNS)
+	db.SetConnMaxLifetime(POSTGRES_CONN_LIFETIME)
+
+	if err := migratePostgres(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	postgresPools[dsn] = db
+	return db, nil
+}
+
+func migratePostgres(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error connecting to database: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, POSTGRES_MIGRATION_LOCK); err != nil {
+		return fmt.Errorf("error locking migrations: %w", err)
+	}
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("error creating migrations table: %w", err)
+	}
+
+	var current int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("error reading schema version: %w", err)
+	}
+
+	for version := current + 1; version <= len(postgresMigrations); version++ {
+		if _, err := tx.Exec(postgresMigrations[version-1]); err != nil {
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		fmt.Printf("✓ Applied database migration %d\n", version)
+	}
+
+	return tx.Commit()
+}
+
+func (s *postgresStore) LoadSites() (SiteData, error) {
+	db, err := postgresPool(s.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT s.name, s.data, COALESCE(v.etag, ''), COALESCE(v.last_modified, '')
+		FROM sites s LEFT JOIN validators v ON v.owner = s.owner AND v.site = s.name
+		WHERE s.owner = $1`, s.owner)
+	if err != nil {
+		return nil, fmt.Errorf("error reading sites: %w", err)
+	}
+	defer rows.Close()
+
+	sites := make(SiteData)
+	for rows.Next() {
+		var name string
+		var data []byte
+		var site Site
+		var etag, lastModified string
+		if err := rows.Scan(&name, &data, &etag, &lastModified); err != nil {
+			return nil, fmt.Errorf("error reading sites: %w", err)
+		}
+		if err := json.Unmarshal(data, &site); err != nil {
+			return nil, fmt.Errorf("error parsing site '%s': %w", name, err)
+		}
+		site.ETag, site.LastModified = etag, lastModified
+		sites[name] = site
+	}
+
+	return sites, rows.Err()
+}
+
+func (s *postgresStore) SaveSites(sites SiteData) error {
+	db, err := postgresPool(s.dsn)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error saving sites: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"sites", "validators"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE owner = $1`, s.owner); err != nil {
+			return fmt.Errorf("error saving sites: %w", err)
+		}
+	}
+
+	for name, site := range sites {
+		etag, lastModified := site.ETag, site.LastModified
+		site.ETag, site.LastModified = "", ""
+
+		data, err := json.Marshal(site)
+		if err != nil {
+			return fmt.Errorf("error marshaling site '%s': %w", name, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO sites (owner, name, data) VALUES ($1, $2, $3)`, s.owner, name, data); err != nil {
+			return fmt.Errorf("error saving site '%s': %w", name, err)
+		}
+
+		if etag != "" || lastModified != "" {
+			if _, err := tx.Exec(`INSERT INTO validators (owner, site, etag, last_modified) VALUES ($1, $2, $3, $4)`,
+				s.owner, name, etag, lastModified); err != nil {
+				return fmt.Errorf("error saving site '%s': %w", name, err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *postgresStore) LoadEntries() (*EntryStore, error) {
+	db, err := postgresPool(s.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	store := newEntryStore(s)
+
+	var nextID string
+	err = db.QueryRow(`SELECT value FROM meta WHERE owner = $1 AND key = 'next_id'`, s.owner).Scan(&nextID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error reading entries: %w", err)
+	}
+	if id, err := strconv.ParseInt(nextID, 10, 64); err == nil {
+		store.NextID = id
+	}
+
+	var pruned []byte
+	err = db.QueryRow(`SELECT value FROM meta WHERE owner = $1 AND key = 'pruned'`, s.owner).Scan(&pruned)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error reading entries: %w", err)
+	}
+	if len(pruned) > 0 {
+		if err := json.Unmarshal(pruned, &store.Pruned); err != nil {
+			return nil, fmt.Errorf("error parsing pruned entries: %w", err)
+		}
+	}
+
+	rows, err := db.Query(`SELECT data FROM entries WHERE owner = $1 ORDER BY id`, s.owner)
+	if err != nil {
+		return nil, fmt.Errorf("error reading entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		var entry Entry
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("error reading entries: %w", err)
+		}
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("error parsing entry: %w", err)
+		}
+		store.Entries = append(store.Entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading entries: %w", err)
+	}
+
+	store.index()
+	return store, nil
+}
+
+func (s *postgresStore) SaveEntries(entries *EntryStore) error {
+	db, err := postgresPool(s.dsn)
+	if err != nil {
+		return err
+	}
+
+	entries.mu.RLock()
+	defer entries.mu.RUnlock()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error saving entries: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO entries (owner, id, site, data) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (owner, id) DO UPDATE SET site = EXCLUDED.site, data = EXCLUDED.data
+		WHERE entries.data IS DISTINCT FROM EXCLUDED.data`)
+	if err != nil {
+		return fmt.Errorf("error saving entries: %w", err)
+	}
+	defer stmt.Close()
+
+	ids := make([]int64, 0, len(entries.Entries))
+	for _, entry := range entries.Entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("error marshaling entry %d: %w", entry.ID, err)
+		}
+		if _, err := stmt.Exec(s.owner, entry.ID, entry.Site, data); err != nil {
+			return fmt.Errorf("error saving entry %d: %w", entry.ID, err)
+		}
+		ids = append(ids, entry.ID)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM entries WHERE owner = $1 AND NOT (id = ANY($2))`, s.owner, pq.Array(ids)); err != nil {
+		return fmt.Errorf("error saving entries: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO meta (owner, key, value) VALUES ($1, 'next_id', $2)
+		ON CONFLICT (owner, key) DO UPDATE SET value = EXCLUDED.value`, s.owner, strconv.FormatInt(entries.NextID, 10)); err != nil {
+		return fmt.Errorf("error saving entries: %w", err)
+	}
+
+	pruned, err := json.Marshal(entries.Pruned)
+	if err != nil {
+		return fmt.Errorf("error marshaling pruned entries: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO meta (owner, key, value) VALUES ($1, 'pruned', $2)
+		ON CONFLICT (owner, key) DO UPDATE SET value = EXCLUDED.value`, s.owner, string(pruned)); err != nil {
+		return fmt.Errorf("error saving entries: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *postgresStore) LoadTrash() ([]TrashedSite, error) {
+	db, err := postgresPool(s.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	err = db.QueryRow(`SELECT value FROM meta WHERE owner = $1 AND key = 'trash'`, s.owner).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading trash: %w", err)
+	}
+
+	var trash []TrashedSite
+	if err := json.Unmarshal(data, &trash); err != nil {
+		return nil, fmt.Errorf("error parsing trash: %w", err)
+	}
+	return trash, nil
+}
+
+func (s *postgresStore) SaveTrash(trash []TrashedSite) error {
+	db, err := postgresPool(s.dsn)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(trash)
+	if err != nil {
+		return fmt.Errorf("error marshaling trash: %w", err)
+	}
+	if _, err := db.Exec(`INSERT INTO meta (owner, key, value) VALUES ($1, 'trash', $2)
+		ON CONFLICT (owner, key) DO UPDATE SET value = EXCLUDED.value`, s.owner, string(data)); err != nil {
+		return fmt.Errorf("error saving trash: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const DEFAULT_PREVIEW_ENTRIES = 10
+
+type previewCacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	Body         []byte    `json:"body"`
+}
+
+func previewCachePath(feedURL string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(feedURL))
+	return filepath.Join(dir, "rss-tracker", "preview", hex.EncodeToString(sum[:])+".json"), nil
+}
+
+func readPreviewCache(path string) *previewCacheEntry {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var cached previewCacheEntry
+	if json.Unmarshal(data, &cached) != nil {
+		return nil
+	}
+	return &cached
+}
+
+func writePreviewCache(path string, cached previewCacheEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// fetchPreview downloads a feed, revalidating a cached copy with its
+// ETag/Last-Modified when one exists. It reports whether the cache was used.
+func fetchPreview(feedURL string, useCache bool) ([]byte, bool, error) {
+	var cachePath string
+	var cached *previewCacheEntry
+	if useCache {
+		if path, err := previewCachePath(feedURL); err == nil {
+			cachePath = path
+			cached = readPreviewCache(path)
+		}
+	}
+
+	site := Site{RSSUrl: feedURL}
+	if cached != nil {
+		site.ETag, site.LastModified = cached.ETag, cached.LastModified
+	}
+
+	req, err := newFeedRequest(site, feedURL)
+	if err != nil {
+		return nil, false, err
+	}
+
+	client := newFeedClient(httpTimeout, "")
+	resp, err := client.Do(req)
+	if err != nil {
+		if cached != nil {
+			fmt.Printf("(-_-) Fetch failed, showing cached copy from %s: %v\n\n", formatAgo(cached.FetchedAt), err)
+			return cached.Body, true, nil
+		}
+		return nil, false, fmt.Errorf("URL fetch error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return cached.Body, true, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	body, err := decodeFeedBody(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading response: %w", err)
+	}
+
+	if cachePath != "" {
+		etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			writePreviewCache(cachePath, previewCacheEntry{
+				URL:          feedURL,
+				ETag:         etag,
+				LastModified: lastModified,
+				FetchedAt:    time.Now(),
+				Body:         body,
+			})
+		}
+	}
+
+	return body, false, nil
+}
+
+func runPreview(args []string) error {
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	limit := fs.Int("n", DEFAULT_PREVIEW_ENTRIES, "Number of entries to show.")
+	relative := fs.Bool("relative", false, "Show publication times relative to now (e.g. \"4h ago\").")
+	noCache := fs.Bool("no-cache", false, "Always download the feed, ignoring the preview cache.")
+	rest := parseInterspersed(fs, args)
+
+	if len(rest) != 1 {
+		return fmt.Errorf("usage: preview <url> [-n 10]")
+	}
+	feedURL := rest[0]
+
+	var body []byte
+	var fromCache bool
+	var err error
+	if isGeminiSource(feedURL) {
+		_, _, body, err = fetchGemini(feedURL, httpTimeout)
+	} else {
+		body, fromCache, err = fetchPreview(feedURL, !*noCache)
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := parseSiteBody(Site{}, feedURL, body)
+	if err != nil {
+		return fmt.Errorf("parse error: %w", err)
+	}
+
+	title := result.FeedTitle
+	if title == "" {
+		title = feedURL
+	}
+	source := ""
+	if fromCache {
+		source = ", cached"
+	}
+	fmt.Printf("%s (%s, %d entries%s)\n", title, feedTypeString(result.FeedType), len(result.Entries), source)
+	if result.SiteURL != "" {
+		fmt.Printf("→ %s\n", result.SiteURL)
+	}
+	fmt.Println()
+
+	entries := result.Entries
+	if *limit > 0 && len(entries) > *limit {
+		entries = entries[:*limit]
+	}
+	if len(entries) == 0 {
+		fmt.Println("No entries found")
+		return nil
+	}
+
+	for i, entry := range entries {
+		title := entry.Title
+		if title == "" {
+			title = "Untitled"
+		}
+		fmt.Printf("%d. %s%s%s\n   %s\n", i+1, title, formatAuthor(entry.Author), formatPublished(entry.Published, *relative), entry.Link)
+	}
+	return nil
+}
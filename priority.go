@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	PRIORITY_HIGH             = "high"
+	PRIORITY_NORMAL           = "normal"
+	PRIORITY_LOW              = "low"
+	DEFAULT_PRIORITY_INTERVAL = 5 * time.Minute
+)
+
+func validatePriority(priority string) error {
+	switch strings.ToLower(strings.TrimSpace(priority)) {
+	case "", PRIORITY_HIGH, PRIORITY_NORMAL, PRIORITY_LOW:
+		return nil
+	}
+	return fmt.Errorf("priority must be \"high\", \"normal\" or \"low\", got '%s'", priority)
+}
+
+// priorityRank orders sites for dispatch: high first, low last.
+func priorityRank(site Site) int {
+	switch strings.ToLower(strings.TrimSpace(site.Priority)) {
+	case PRIORITY_HIGH:
+		return 0
+	case PRIORITY_LOW:
+		return 2
+	default:
+		return 1
+	}
+}
+
+func sortByPriority(sites SiteData, names []string) {
+	sort.SliceStable(names, func(i, j int) bool {
+		ri, rj := priorityRank(sites[names[i]]), priorityRank(sites[names[j]])
+		if ri != rj {
+			return ri < rj
+		}
+		return names[i] < names[j]
+	})
+}
+
+// highPrioritySites returns the high priority sites that are not snoozed or
+// paused.
+func highPrioritySites(sites SiteData) []string {
+	now := time.Now()
+	var names []string
+	for name, site := range sites {
+		if priorityRank(site) != 0 || site.onHold(now) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// priorityInterval is how often serve checks high priority sites between
+// full cycles.
+func (c Config) priorityInterval() time.Duration {
+	if c.PriorityInterval == "" {
+		return DEFAULT_PRIORITY_INTERVAL
+	}
+	interval, err := parseDuration(c.PriorityInterval)
+	if err != nil {
+		return DEFAULT_PRIORITY_INTERVAL
+	}
+	return interval
+}
+
+func setPriority(sites SiteData, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: priority <site> <high|normal|low>")
+	}
+	priority := strings.ToLower(args[1])
+	if err := validatePriority(priority); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	name, err := resolveSiteName(sites, args[0], reader)
+	if err != nil {
+		return err
+	}
+
+	site := sites[name]
+	if priority == PRIORITY_NORMAL {
+		priority = ""
+	}
+	site.Priority = priority
+	sites[name] = site
+	if err := saveSites(sites); err != nil {
+		return fmt.Errorf("saving sites: %w", err)
+	}
+
+	if priority == "" {
+		priority = PRIORITY_NORMAL
+	}
+	fmt.Printf("✓ '%s' now has %s priority\n", name, priority)
+	return nil
+}
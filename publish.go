@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+type EntryEvent struct {
+	ID         int64      `json:"id"`
+	Site       string     `json:"site"`
+	Title      string     `json:"title"`
+	Link       string     `json:"link"`
+	Published  *time.Time `json:"published,omitempty"`
+	Discovered time.Time  `json:"discovered"`
+	Tags       []string   `json:"tags,omitempty"`
+	SiteTags   []string   `json:"site_tags,omitempty"`
+}
+
+type Publisher interface {
+	Name() string
+	Publish(events []EntryEvent) error
+}
+
+func newEntryEvent(entry Entry, site Site) EntryEvent {
+	event := EntryEvent{
+		ID:         entry.ID,
+		Site:       entry.Site,
+		Title:      entryDisplayTitle(entry),
+		Link:       entry.Link,
+		Discovered: entry.Discovered,
+		Tags:       entry.Tags,
+		SiteTags:   site.Tags,
+	}
+	if !entry.Published.IsZero() {
+		event.Published = &entry.Published
+	}
+	return event
+}
+
+func (e EntryEvent) payload() ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling event: %w", err)
+	}
+	return data, nil
+}
+
+func (c Config) publishers() []Publisher {
+	var publishers []Publisher
+	if c.MQTT != nil {
+		publishers = append(publishers, &MQTTPublisher{config: *c.MQTT})
+	}
+	if c.NATS != nil {
+		publishers = append(publishers, &NATSPublisher{config: *c.NATS})
+	}
+	return publishers
+}
+
+func publishEntries(out io.Writer, config Config, events []EntryEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	for _, publisher := range config.publishers() {
+		if err := publisher.Publish(events); err != nil {
+			fmt.Fprintf(out, "Publisher '%s' → ERROR: %v\n", publisher.Name(), err)
+		}
+	}
+}
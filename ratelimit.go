@@ -0,0 +1,141 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_REQUESTS_PER_MINUTE = 300
+	DEFAULT_RATE_BURST          = 60
+	DEFAULT_MAX_REQUEST_BYTES   = 1 << 20
+	SERVE_MAX_HEADER_BYTES      = 64 << 10
+	RATE_LIMIT_IDLE             = 10 * time.Minute
+)
+
+// ServeLimits caps how hard a single client can use serve mode. A negative
+// requests_per_minute or max_request_bytes turns that limit off.
+type ServeLimits struct {
+	RequestsPerMinute int   `json:"requests_per_minute,omitempty"`
+	Burst             int   `json:"burst,omitempty"`
+	MaxRequestBytes   int64 `json:"max_request_bytes,omitempty"`
+	TrustProxy        bool  `json:"trust_proxy,omitempty"`
+}
+
+type rateBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// limitedHandler applies a token bucket per client IP and a request body
+// limit in front of every serve endpoint.
+type limitedHandler struct {
+	next       http.Handler
+	perSecond  float64
+	burst      float64
+	maxBody    int64
+	trustProxy bool
+
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+	swept   time.Time
+}
+
+func limitRequests(next http.Handler, limits *ServeLimits) *limitedHandler {
+	var l ServeLimits
+	if limits != nil {
+		l = *limits
+	}
+	if l.RequestsPerMinute == 0 {
+		l.RequestsPerMinute = DEFAULT_REQUESTS_PER_MINUTE
+	}
+	if l.Burst <= 0 {
+		l.Burst = DEFAULT_RATE_BURST
+	}
+	if l.MaxRequestBytes == 0 {
+		l.MaxRequestBytes = DEFAULT_MAX_REQUEST_BYTES
+	}
+
+	return &limitedHandler{
+		next:       next,
+		perSecond:  float64(l.RequestsPerMinute) / 60,
+		burst:      float64(l.Burst),
+		maxBody:    l.MaxRequestBytes,
+		trustProxy: l.TrustProxy,
+		buckets:    make(map[string]*rateBucket),
+	}
+}
+
+// clientIP is the peer address, or behind a trusted reverse proxy the address
+// the proxy appended to X-Forwarded-For.
+func (h *limitedHandler) clientIP(r *http.Request) string {
+	if h.trustProxy {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// allow takes a token from the client's bucket, or reports how long until
+// one is available.
+func (h *limitedHandler) allow(ip string) (bool, time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(h.swept) > RATE_LIMIT_IDLE {
+		for key, bucket := range h.buckets {
+			if now.Sub(bucket.updated) > RATE_LIMIT_IDLE {
+				delete(h.buckets, key)
+			}
+		}
+		h.swept = now
+	}
+
+	bucket, ok := h.buckets[ip]
+	if !ok {
+		bucket = &rateBucket{tokens: h.burst, updated: now}
+		h.buckets[ip] = bucket
+	}
+	bucket.tokens = math.Min(h.burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*h.perSecond)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / h.perSecond * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+func (h *limitedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.perSecond > 0 {
+		if ok, wait := h.allow(h.clientIP(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "too many requests"})
+			return
+		}
+	}
+
+	if h.maxBody > 0 {
+		if r.ContentLength > h.maxBody {
+			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "request body too large"})
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxBody)
+	}
+
+	h.next.ServeHTTP(w, r)
+}
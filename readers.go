@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// newsBlurExport is the JSON NewsBlur gives for an account's feeds. Folders
+// are a list of feed IDs and objects mapping a folder name to another such
+// list.
+type newsBlurExport struct {
+	Feeds map[string]struct {
+		Title   string `json:"feed_title"`
+		Address string `json:"feed_address"`
+		Link    string `json:"feed_link"`
+	} `json:"feeds"`
+	Folders []json.RawMessage `json:"folders"`
+}
+
+func importNewsBlur(sites SiteData, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: import-newsblur <file.opml|file.json>")
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+
+	var feeds []importedFeed
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		feeds, err = parseNewsBlurJSON(data)
+	} else {
+		feeds, err = parseOPMLFeeds(data)
+	}
+	if err != nil {
+		return err
+	}
+	return importFeeds(sites, feeds)
+}
+
+func parseNewsBlurJSON(data []byte) ([]importedFeed, error) {
+	var export newsBlurExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("error parsing NewsBlur export: %w", err)
+	}
+
+	var feeds []importedFeed
+	filed := make(map[string]bool)
+	var walk func(items []json.RawMessage, path []string) error
+	walk = func(items []json.RawMessage, path []string) error {
+		for _, item := range items {
+			var id int64
+			if err := json.Unmarshal(item, &id); err == nil {
+				key := strconv.FormatInt(id, 10)
+				feed, ok := export.Feeds[key]
+				if !ok || feed.Address == "" {
+					continue
+				}
+				filed[key] = true
+				feeds = append(feeds, importedFeed{
+					Title:   feed.Title,
+					URL:     feed.Address,
+					SiteURL: feed.Link,
+					Tag:     strings.Join(path, "/"),
+				})
+				continue
+			}
+
+			var folders map[string][]json.RawMessage
+			if err := json.Unmarshal(item, &folders); err != nil {
+				return fmt.Errorf("error parsing NewsBlur folders: %w", err)
+			}
+			names := make([]string, 0, len(folders))
+			for name := range folders {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if err := walk(folders[name], append(path, name)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(export.Folders, nil); err != nil {
+		return nil, err
+	}
+
+	// Feeds missing from the folders are still subscriptions.
+	keys := make([]string, 0, len(export.Feeds))
+	for key := range export.Feeds {
+		if !filed[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		feed := export.Feeds[key]
+		if feed.Address == "" {
+			continue
+		}
+		feeds = append(feeds, importedFeed{Title: feed.Title, URL: feed.Address, SiteURL: feed.Link})
+	}
+
+	return feeds, nil
+}
+
+func importFeedly(sites SiteData, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: import-feedly <file.opml>")
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+
+	feeds, err := parseOPMLFeeds(data)
+	if err != nil {
+		return err
+	}
+	// Feedly names feeds "feed/<url>" and some exports keep the prefix.
+	for i := range feeds {
+		feeds[i].URL = strings.TrimPrefix(feeds[i].URL, "feed/")
+	}
+	return importFeeds(sites, feeds)
+}
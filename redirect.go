@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const DEFAULT_MAX_REDIRECTS = 10
+
+type RedirectConfig struct {
+	Max         *int `json:"max,omitempty"`
+	NoDowngrade bool `json:"no_downgrade,omitempty"`
+	SameHost    bool `json:"same_host,omitempty"`
+}
+
+type RedirectPolicy struct {
+	Max         int
+	NoDowngrade bool
+	SameHost    bool
+}
+
+var redirectPolicy = RedirectPolicy{Max: DEFAULT_MAX_REDIRECTS}
+
+func (p RedirectPolicy) check(req *http.Request, via []*http.Request) error {
+	if len(via) > p.Max {
+		if p.Max == 0 {
+			return fmt.Errorf("redirects are disabled")
+		}
+		return fmt.Errorf("stopped after %d redirects", p.Max)
+	}
+
+	previous := via[len(via)-1]
+	if p.NoDowngrade && previous.URL.Scheme == "https" && req.URL.Scheme != "https" {
+		return fmt.Errorf("refusing redirect from https to %s", req.URL.Scheme)
+	}
+
+	if p.SameHost && !strings.EqualFold(req.URL.Hostname(), via[0].URL.Hostname()) {
+		return fmt.Errorf("refusing cross-host redirect from %s to %s", via[0].URL.Hostname(), req.URL.Hostname())
+	}
+
+	return nil
+}
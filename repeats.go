@@ -0,0 +1,132 @@
+package main
+
+import "time"
+
+// repeatWindow is how long after an entry is found a feed republishing its
+// link (usually with an edited title) doesn't notify again. Zero turns it off.
+func (c Config) repeatWindow() time.Duration {
+	if c.RepeatWindow == "" {
+		return 0
+	}
+	window, err := parseDuration(c.RepeatWindow)
+	if err != nil {
+		return 0
+	}
+	return window
+}
+
+// recentLinks indexes the entries of a site found since cutoff by link, to
+// the most recently found one, leaving out those in skip. The caller holds
+// the lock.
+func (s *EntryStore) recentLinks(siteName string, cutoff time.Time, skip map[int64]bool) map[string]int {
+	links := make(map[string]int)
+	for i, entry := range s.Entries {
+		if entry.Site != siteName || entry.Link == "" || skip[entry.ID] || entry.Discovered.Before(cutoff) {
+			continue
+		}
+		if earlier, ok := links[entry.Link]; !ok || entry.Discovered.After(s.Entries[earlier].Discovered) {
+			links[entry.Link] = i
+		}
+	}
+	return links
+}
+
+// refreshEntry updates a stored entry from the feed entry republishing it,
+// reporting whether anything changed.
+func refreshEntry(entry *Entry, feedEntry FeedEntry) bool {
+	updated := false
+	if feedEntry.Title != "" && feedEntry.Title != entry.Title {
+		entry.Title, updated = feedEntry.Title, true
+	}
+	if feedEntry.Content != "" && feedEntry.Content != entry.Content {
+		entry.Content, updated = feedEntry.Content, true
+	}
+	if !feedEntry.Published.IsZero() && !feedEntry.Published.Equal(entry.Published) {
+		entry.Published, updated = feedEntry.Published, true
+	}
+	if feedEntry.Author != "" && feedEntry.Author != entry.Author {
+		entry.Author, updated = feedEntry.Author, true
+	}
+	return updated
+}
+
+// foldRepeats finds the entries just added that republish the link of an
+// entry stored within window (the feed gave them a new GUID). The stored
+// entry is updated from the feed and the added one dropped again, its key
+// staying seen. It returns what is left of added, the republished links and
+// whether the store changed.
+func (s *EntryStore) foldRepeats(siteName string, feedEntries []FeedEntry, added []Entry, window time.Duration) ([]Entry, map[string]bool, bool) {
+	if len(added) == 0 {
+		return added, nil, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	addedIDs := make(map[int64]bool, len(added))
+	addedLinks := make(map[string]bool, len(added))
+	for _, entry := range added {
+		addedIDs[entry.ID] = true
+		addedLinks[entry.Link] = true
+	}
+
+	links := s.recentLinks(siteName, time.Now().Add(-window), addedIDs)
+	repeats := make(map[string]bool)
+	for _, feedEntry := range feedEntries {
+		if !addedLinks[feedEntry.Link] || repeats[feedEntry.Link] {
+			continue
+		}
+		earlier, ok := links[feedEntry.Link]
+		if !ok {
+			continue
+		}
+		repeats[feedEntry.Link] = true
+		refreshEntry(&s.Entries[earlier], feedEntry)
+	}
+	if len(repeats) == 0 {
+		return added, nil, false
+	}
+
+	kept := s.Entries[:0]
+	for _, entry := range s.Entries {
+		if addedIDs[entry.ID] && repeats[entry.Link] {
+			s.addPruned(entryKey(entry.Site, entry.GUID, entry.Link))
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	s.Entries = kept
+
+	var left []Entry
+	for _, entry := range added {
+		if !repeats[entry.Link] {
+			left = append(left, entry)
+		}
+	}
+	return left, repeats, true
+}
+
+// refreshRepeat tells whether the entry a site is about to announce is one
+// stored within window, republished without a new GUID (an edited title or
+// date, or an older post back on top); recorded are the entries the check
+// itself added. If so the stored entry is updated from it. It is only called
+// when there is something to announce, and returns whether it is a repeat
+// and whether the store changed.
+func (s *EntryStore) refreshRepeat(siteName string, announced FeedEntry, recorded []Entry, window time.Duration) (bool, bool) {
+	if announced.Link == "" {
+		return false, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	skip := make(map[int64]bool, len(recorded))
+	for _, entry := range recorded {
+		skip[entry.ID] = true
+	}
+	earlier, ok := s.recentLinks(siteName, time.Now().Add(-window), skip)[announced.Link]
+	if !ok {
+		return false, false
+	}
+	return true, refreshEntry(&s.Entries[earlier], announced)
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net/smtp"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_REPORT_PERIOD = "7d"
+	REPORT_TOP_DOMAINS    = 5
+	UNTAGGED_GROUP        = "Untagged"
+)
+
+type SMTPConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+type reportGroup struct {
+	Name    string
+	Entries []Entry
+}
+
+type reportDomain struct {
+	Domain string
+	Count  int
+}
+
+type Report struct {
+	Since      time.Time
+	Until      time.Time
+	Total      int
+	Sites      int
+	Groups     []reportGroup
+	TopDomains []reportDomain
+	Searches   []reportGroup
+	Icons      map[string]template.URL
+}
+
+func buildReport(sites SiteData, entries *EntryStore, since time.Time) Report {
+	report := Report{Since: since, Until: time.Now()}
+
+	discovered := entries.list(func(e Entry) bool {
+		return !e.Backfill && !e.Discovered.Before(since)
+	})
+	sort.Slice(discovered, func(i, j int) bool {
+		if discovered[i].Score != discovered[j].Score {
+			return discovered[i].Score > discovered[j].Score
+		}
+		if !discovered[i].Discovered.Equal(discovered[j].Discovered) {
+			return discovered[i].Discovered.After(discovered[j].Discovered)
+		}
+		return discovered[i].ID > discovered[j].ID
+	})
+
+	groups := make(map[string][]Entry)
+	domains := make(map[string]int)
+	activeSites := make(map[string]bool)
+
+	for _, entry := range discovered {
+		activeSites[entry.Site] = true
+
+		tags := sites[entry.Site].Tags
+		if len(tags) == 0 {
+			tags = []string{UNTAGGED_GROUP}
+		}
+		for _, tag := range tags {
+			groups[tag] = append(groups[tag], entry)
+		}
+
+		if u, err := url.Parse(entry.Link); err == nil && u.Host != "" {
+			domains[strings.TrimPrefix(u.Host, "www.")]++
+		}
+	}
+
+	report.Total = len(discovered)
+	report.Sites = len(activeSites)
+
+	for name, groupEntries := range groups {
+		report.Groups = append(report.Groups, reportGroup{Name: name, Entries: groupEntries})
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		if (report.Groups[i].Name == UNTAGGED_GROUP) != (report.Groups[j].Name == UNTAGGED_GROUP) {
+			return report.Groups[j].Name == UNTAGGED_GROUP
+		}
+		return report.Groups[i].Name < report.Groups[j].Name
+	})
+
+	for domain, count := range domains {
+		report.TopDomains = append(report.TopDomains, reportDomain{Domain: domain, Count: count})
+	}
+	sort.Slice(report.TopDomains, func(i, j int) bool {
+		if report.TopDomains[i].Count != report.TopDomains[j].Count {
+			return report.TopDomains[i].Count > report.TopDomains[j].Count
+		}
+		return report.TopDomains[i].Domain < report.TopDomains[j].Domain
+	})
+	if len(report.TopDomains) > REPORT_TOP_DOMAINS {
+		report.TopDomains = report.TopDomains[:REPORT_TOP_DOMAINS]
+	}
+
+	return report
+}
+
+// addSavedSearches lists the report's entries that match each saved search.
+func (r *Report) addSavedSearches(config Config, sites SiteData, entries *EntryStore) {
+	for _, name := range savedSearchNames(config) {
+		matches := config.SavedSearches[name].matcher(sites)
+		found := entries.list(func(e Entry) bool {
+			return !e.Backfill && !e.Discovered.Before(r.Since) && matches(e)
+		})
+		if len(found) == 0 {
+			continue
+		}
+		sort.Slice(found, func(i, j int) bool {
+			if found[i].Score != found[j].Score {
+				return found[i].Score > found[j].Score
+			}
+			return found[i].ID > found[j].ID
+		})
+		r.Searches = append(r.Searches, reportGroup{Name: name, Entries: found})
+	}
+}
+
+// reportIcons returns favicons as data URIs for the sites in a report, so
+// the HTML works offline and in email.
+func reportIcons(sites SiteData, r Report) map[string]template.URL {
+	icons := make(map[string]template.URL)
+	for _, group := range r.Groups {
+		for _, entry := range group.Entries {
+			if _, done := icons[entry.Site]; done {
+				continue
+			}
+			favicon, _ := loadFavicon(sites[entry.Site], false)
+			icons[entry.Site] = template.URL(favicon.dataURI())
+		}
+	}
+	return icons
+}
+
+func (r Report) title() string {
+	return fmt.Sprintf("RSS Tracker report %s – %s", r.Since.Format("2006-01-02"), r.Until.Format("2006-01-02"))
+}
+
+func entryDisplayTitle(entry Entry) string {
+	if entry.Title == "" {
+		return "Untitled"
+	}
+	return entry.Title
+}
+
+func renderMarkdownReport(w io.Writer, r Report) {
+	fmt.Fprintf(w, "# %s\n\n", r.title())
+	fmt.Fprintf(w, "%d new entries from %d sites.\n", r.Total, r.Sites)
+
+	if len(r.TopDomains) > 0 {
+		fmt.Fprintf(w, "\n## Top domains\n\n")
+		for _, d := range r.TopDomains {
+			fmt.Fprintf(w, "- %s (%d)\n", d.Domain, d.Count)
+		}
+	}
+
+	for _, group := range r.Groups {
+		fmt.Fprintf(w, "\n## %s (%d)\n\n", group.Name, len(group.Entries))
+		renderMarkdownEntries(w, group.Entries)
+	}
+
+	for _, search := range r.Searches {
+		fmt.Fprintf(w, "\n## Saved search: %s (%d)\n\n", search.Name, len(search.Entries))
+		renderMarkdownEntries(w, search.Entries)
+	}
+}
+
+func renderMarkdownEntries(w io.Writer, entries []Entry) {
+	for _, entry := range entries {
+		title := strings.NewReplacer("[", "\\[", "]", "\\]").Replace(entryDisplayTitle(entry))
+		fmt.Fprintf(w, "- [%s](%s) — %s, %s\n", title, entry.Link, entry.Site, entry.Discovered.Local().Format("Mon Jan 2"))
+		if entry.Note != "" {
+			fmt.Fprintf(w, "  > %s\n", entry.Note)
+		}
+	}
+}
+
+// The built-in digest.html template.
+const htmlReportSource = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Report.Total}} new entries from {{.Report.Sites}} sites.</p>
+{{- if .Report.TopDomains}}
+<h2>Top domains</h2>
+<ul>
+{{- range .Report.TopDomains}}
+<li>{{.Domain}} ({{.Count}})</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- range .Report.Groups}}
+<h2>{{.Name}} ({{len .Entries}})</h2>
+<ul>
+{{- range .Entries}}
+<li>{{with index $.Report.Icons .Site}}<img src="{{.}}" width="16" height="16" alt=""> {{end}}<a href="{{.Link}}">{{title .}}</a> — {{.Site}}, {{.Discovered.Local.Format "Mon Jan 2"}}{{if .Note}}<br><em>{{.Note}}</em>{{end}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- range .Report.Searches}}
+<h2>Saved search: {{.Name}} ({{len .Entries}})</h2>
+<ul>
+{{- range .Entries}}
+<li><a href="{{.Link}}">{{title .}}</a> — {{.Site}}, {{.Discovered.Local.Format "Mon Jan 2"}}</li>
+{{- end}}
+</ul>
+{{- end}}
+</body>
+</html>
+`
+
+func renderHTMLReport(w io.Writer, r Report) error {
+	return outputTemplates.html.Execute(w, map[string]any{
+		"Title":  r.title(),
+		"Report": r,
+	})
+}
+
+func sendEmail(config *SMTPConfig, subject, contentType string, body []byte) error {
+	if config == nil || config.Host == "" || config.From == "" || len(config.To) == 0 {
+		return fmt.Errorf("smtp is not configured (host, from and to are required)")
+	}
+
+	port := config.Port
+	if port == 0 {
+		port = 587
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(config.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: %s; charset=utf-8\r\n\r\n", contentType)
+	msg.Write(body)
+
+	var auth smtp.Auth
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+
+	addr := fmt.Sprintf("%s:%d", config.Host, port)
+	if err := smtp.SendMail(addr, auth, config.From, config.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("sending email: %w", err)
+	}
+	return nil
+}
+
+func runReport(sites SiteData, config Config, args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	period := fs.String("since", DEFAULT_REPORT_PERIOD, "Report on entries discovered within this period.")
+	format := fs.String("format", "markdown", "Report format: markdown or html.")
+	output := fs.String("output", "", "Write the report to this file instead of stdout.")
+	email := fs.Bool("email", false, "Email the report using the smtp config.")
+	fs.Parse(args)
+
+	duration, err := parseDuration(*period)
+	if err != nil {
+		return err
+	}
+
+	entries, err := readEntries()
+	if err != nil {
+		return err
+	}
+
+	report := buildReport(sites, entries, time.Now().Add(-duration))
+	report.addSavedSearches(config, sites, entries)
+
+	var buf bytes.Buffer
+	contentType := "text/plain"
+	switch *format {
+	case "markdown", "md":
+		renderMarkdownReport(&buf, report)
+	case "html":
+		report.Icons = reportIcons(sites, report)
+		if err := renderHTMLReport(&buf, report); err != nil {
+			return fmt.Errorf("rendering report: %w", err)
+		}
+		contentType = "text/html"
+	default:
+		return fmt.Errorf("unknown report format '%s'", *format)
+	}
+
+	if *email {
+		subject, err := outputTemplates.renderText(TEMPLATE_EMAIL_SUBJECT, map[string]any{
+			"Title":  report.title(),
+			"Report": report,
+		})
+		if err != nil {
+			return err
+		}
+		if err := sendEmail(config.SMTP, subject, contentType, buf.Bytes()); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Report emailed to %s\n", strings.Join(config.SMTP.To, ", "))
+	}
+
+	if *output != "" {
+		if err := os.WriteFile(*output, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("error writing report: %w", err)
+		}
+		fmt.Printf("✓ Report written to %s\n", *output)
+	}
+
+	if !*email && *output == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ROBOTS_CACHE_TTL = 24 * time.Hour
+	MAX_ROBOTS_SIZE  = 512 << 10
+	MAX_CRAWL_DELAY  = time.Minute
+)
+
+// CrawlConfig sets how politely pages that aren't feeds (watch: and sitemap
+// sites) are fetched. Delay is the least time between two fetches from the
+// same host; a longer Crawl-delay in robots.txt wins.
+type CrawlConfig struct {
+	Delay        string `json:"delay,omitempty"`
+	IgnoreRobots bool   `json:"ignore_robots,omitempty"`
+}
+
+type robotsRule struct {
+	allow   bool
+	length  int
+	pattern *regexp.Regexp
+}
+
+type robotsPolicy struct {
+	rules   []robotsRule
+	delay   time.Duration
+	fetched time.Time
+}
+
+// errCrawlPastDeadline is returned when waiting out a host's crawl delay
+// would run past the check's time budget.
+var errCrawlPastDeadline = errors.New("crawl delay runs past the time budget")
+
+var (
+	crawlDelay   time.Duration
+	ignoreRobots bool
+
+	robotsMu      sync.Mutex
+	robotsCache   = make(map[string]*robotsPolicy)
+	hostNextFetch = make(map[string]time.Time)
+)
+
+func applyCrawlConfig(config *CrawlConfig) error {
+	if config == nil {
+		return nil
+	}
+	if config.Delay != "" {
+		delay, err := parseDuration(config.Delay)
+		if err != nil {
+			return fmt.Errorf("config crawl.delay: %w", err)
+		}
+		if delay < 0 {
+			return fmt.Errorf("config crawl.delay must not be negative, got '%s'", config.Delay)
+		}
+		crawlDelay = delay
+	}
+	ignoreRobots = config.IgnoreRobots
+	return nil
+}
+
+// robotsPattern compiles a robots.txt path pattern, where "*" matches any
+// characters and a trailing "$" anchors the end of the path.
+func robotsPattern(path string) *regexp.Regexp {
+	anchored := strings.HasSuffix(path, "$")
+	path = strings.TrimSuffix(path, "$")
+
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(path), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// siteUserAgent is the User-Agent a site's requests are sent with: its own
+// header if it has one, or else USER_AGENT.
+func siteUserAgent(site Site) string {
+	for name, value := range site.Headers {
+		if !strings.EqualFold(name, "User-Agent") {
+			continue
+		}
+		if value, err := secrets.resolve(value); err == nil && value != "" {
+			return value
+		}
+	}
+	return USER_AGENT
+}
+
+// robotsAgent is the name robots.txt knows a User-Agent by: its product
+// token, lower-cased, so "rss-tracker/1.0 (...)" is "rss-tracker".
+func robotsAgent(userAgent string) string {
+	token, _, _ := strings.Cut(strings.TrimSpace(userAgent), " ")
+	token, _, _ = strings.Cut(token, "/")
+	return strings.ToLower(token)
+}
+
+// parseRobots reads the groups of a robots.txt that apply to agent: those
+// naming it, or else those for "*".
+func parseRobots(body []byte, agent string) *robotsPolicy {
+	type group struct {
+		agents []string
+		rules  []robotsRule
+		delay  time.Duration
+	}
+
+	var groups []*group
+	var current *group
+	inRules := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			if current == nil || inRules {
+				current = &group{}
+				groups = append(groups, current)
+				inRules = false
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch key {
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{
+				allow:   key == "allow",
+				length:  len(value),
+				pattern: robotsPattern(value),
+			})
+		case "crawl-delay":
+			inRules = true
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.delay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+
+	merge := func(agent string) *robotsPolicy {
+		var policy *robotsPolicy
+		for _, g := range groups {
+			for _, name := range g.agents {
+				if name != agent {
+					continue
+				}
+				if policy == nil {
+					policy = &robotsPolicy{}
+				}
+				policy.rules = append(policy.rules, g.rules...)
+				policy.delay = max(policy.delay, g.delay)
+				break
+			}
+		}
+		return policy
+	}
+
+	if policy := merge(agent); agent != "" && policy != nil {
+		return policy
+	}
+	if policy := merge("*"); policy != nil {
+		return policy
+	}
+	return &robotsPolicy{}
+}
+
+// allows applies the longest matching rule to a path; on a tie Allow wins.
+func (p *robotsPolicy) allows(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+
+	allowed, longest := true, -1
+	for _, rule := range p.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > longest || rule.length == longest && rule.allow {
+			allowed, longest = rule.allow, rule.length
+		}
+	}
+	return allowed
+}
+
+// fetchRobots reads a host's robots.txt. A missing one allows everything; a
+// server error is returned, so the page waits for the next check rather than
+// being fetched against rules that couldn't be read.
+func fetchRobots(robotsURL, userAgent, version string, timeout time.Duration) (*robotsPolicy, error) {
+	req, err := http.NewRequest(http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("robots.txt: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	client := newFeedClient(timeout, version)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("robots.txt: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		// Only the first MAX_ROBOTS_SIZE bytes count, as crawlers agree.
+		body, err := io.ReadAll(io.LimitReader(resp.Body, MAX_ROBOTS_SIZE))
+		if err != nil {
+			return nil, fmt.Errorf("robots.txt: %w", err)
+		}
+		return parseRobots(body, robotsAgent(userAgent)), nil
+	case resp.StatusCode >= 400 && resp.StatusCode <= 499:
+		return &robotsPolicy{}, nil
+	default:
+		return nil, fmt.Errorf("robots.txt: HTTP status: %s", resp.Status)
+	}
+}
+
+// robotsFor returns the robots.txt policy of the page's host for userAgent,
+// reading it at most once a day.
+func robotsFor(u *url.URL, userAgent, version string, timeout time.Duration, span *Span) (*robotsPolicy, error) {
+	robotsURL := u.Scheme + "://" + u.Host + "/robots.txt"
+	key := robotsURL + " " + robotsAgent(userAgent)
+
+	robotsMu.Lock()
+	policy, ok := robotsCache[key]
+	robotsMu.Unlock()
+	if ok && time.Since(policy.fetched) < ROBOTS_CACHE_TTL {
+		return policy, nil
+	}
+
+	robots := startSpan(span, "robots")
+	policy, err := fetchRobots(robotsURL, userAgent, version, timeout)
+	robots.fail(err)
+	robots.finish()
+	if err != nil {
+		return nil, err
+	}
+
+	policy.fetched = time.Now()
+	robotsMu.Lock()
+	robotsCache[key] = policy
+	robotsMu.Unlock()
+	return policy, nil
+}
+
+// waitForHost blocks until delay has passed since the last fetch from host
+// was allowed to start. If that would be after deadline (when set), it
+// returns errCrawlPastDeadline at once, leaving the host's turn to others.
+func waitForHost(host string, delay time.Duration, deadline time.Time) error {
+	robotsMu.Lock()
+	now := time.Now()
+	next := hostNextFetch[host]
+	if next.Before(now) {
+		next = now
+	}
+	if !deadline.IsZero() && !next.Before(deadline) {
+		robotsMu.Unlock()
+		return errCrawlPastDeadline
+	}
+	hostNextFetch[host] = next.Add(delay)
+	robotsMu.Unlock()
+
+	time.Sleep(time.Until(next))
+	return nil
+}
+
+// crawlPolitely checks robots.txt for a page that isn't a feed and waits out
+// the host's crawl delay before it is fetched, though not past deadline.
+// Feeds are meant to be polled and go straight through.
+func crawlPolitely(site Site, pageURL string, timeout time.Duration, deadline time.Time, span *Span) error {
+	if site.Type == SITE_TYPE_FEED {
+		return nil
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" {
+		return nil
+	}
+
+	delay := crawlDelay
+	if !ignoreRobots {
+		policy, err := robotsFor(u, siteUserAgent(site), site.IPVersion, timeout, span)
+		if err != nil {
+			return err
+		}
+		path := u.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		if u.RawQuery != "" {
+			path += "?" + u.RawQuery
+		}
+		if !policy.allows(path) {
+			return fmt.Errorf("disallowed by %s://%s/robots.txt", u.Scheme, u.Host)
+		}
+		delay = max(delay, policy.delay)
+	}
+
+	return waitForHost(u.Host, min(delay, MAX_CRAWL_DELAY), deadline)
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+type Rule struct {
+	Name    string    `json:"name"`
+	Match   RuleMatch `json:"match"`
+	Actions []string  `json:"actions"`
+}
+
+type RuleMatch struct {
+	Feed     []string `json:"feed,omitempty"`
+	Tag      []string `json:"tag,omitempty"`
+	Title    []string `json:"title,omitempty"`
+	Category []string `json:"category,omitempty"`
+	Author   []string `json:"author,omitempty"`
+}
+
+type ruleOutcome struct {
+	notifications []Notification
+	notified      map[string][]string
+	ignored       map[string]bool
+	open          []string
+	scores        map[string]int
+}
+
+func containsFold(values []string, target string) bool {
+	for _, value := range values {
+		if strings.EqualFold(strings.TrimSpace(value), target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) validate(config Config) error {
+	m := r.Match
+	if len(m.Feed)+len(m.Tag)+len(m.Title)+len(m.Category)+len(m.Author) == 0 {
+		return fmt.Errorf("rule '%s' has no match criteria", r.Name)
+	}
+	if len(r.Actions) == 0 {
+		return fmt.Errorf("rule '%s' has no actions", r.Name)
+	}
+
+	for _, action := range r.Actions {
+		kind, arg, _ := strings.Cut(action, ":")
+		switch kind {
+		case "tag":
+			if arg == "" {
+				return fmt.Errorf("rule '%s': tag action needs a name (tag:<name>)", r.Name)
+			}
+		case "notify":
+			if _, exists := config.Notifiers[arg]; !exists {
+				return fmt.Errorf("rule '%s': unknown notifier '%s'", r.Name, arg)
+			}
+		case "score":
+			if _, err := strconv.Atoi(strings.TrimPrefix(arg, "+")); err != nil {
+				return fmt.Errorf("rule '%s': score action needs a whole number (score:<n>)", r.Name)
+			}
+		case "ignore", "archive", "open":
+		default:
+			return fmt.Errorf("rule '%s': unknown action '%s'", r.Name, action)
+		}
+	}
+
+	return nil
+}
+
+func (r Rule) matches(siteName string, site Site, entry Entry) bool {
+	m := r.Match
+
+	if len(m.Feed) > 0 && !containsFold(m.Feed, siteName) {
+		aliased := false
+		for _, alias := range site.Aliases {
+			if containsFold(m.Feed, alias) {
+				aliased = true
+				break
+			}
+		}
+		if !aliased {
+			return false
+		}
+	}
+
+	if len(m.Tag) > 0 {
+		found := false
+		for _, tag := range site.Tags {
+			if containsFold(m.Tag, tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(m.Title) > 0 && !containsAnyFold(entry.Title, m.Title) {
+		return false
+	}
+
+	if len(m.Author) > 0 && !containsAnyFold(entry.Author, m.Author) {
+		return false
+	}
+
+	if len(m.Category) > 0 {
+		found := false
+		for _, category := range entry.Categories {
+			if containsFold(m.Category, category) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c Config) applyRules(siteName string, site Site, newEntries []Entry, feedType FeedType, entries *EntryStore) ruleOutcome {
+	outcome := ruleOutcome{
+		notified: make(map[string][]string),
+		ignored:  make(map[string]bool),
+		scores:   make(map[string]int),
+	}
+	if len(c.Rules) == 0 {
+		return outcome
+	}
+
+	for _, entry := range newEntries {
+		var tags, channels []string
+		read, saved, ignored, open := false, false, false, false
+		score := 0
+
+		for _, rule := range c.Rules {
+			if !rule.matches(siteName, site, entry) {
+				continue
+			}
+
+			for _, action := range rule.Actions {
+				kind, arg, _ := strings.Cut(action, ":")
+				switch kind {
+				case "tag":
+					tags = append(tags, arg)
+				case "notify":
+					channels = append(channels, arg)
+				case "ignore":
+					ignored, read = true, true
+				case "archive":
+					read, saved = true, true
+				case "open":
+					open = true
+				case "score":
+					points, _ := strconv.Atoi(strings.TrimPrefix(arg, "+"))
+					score += points
+				}
+			}
+		}
+
+		if len(tags) > 0 || read || saved || score != 0 {
+			entries.update(func(e Entry) bool {
+				return e.ID == entry.ID
+			}, func(e *Entry) {
+				for _, tag := range tags {
+					if !containsFold(e.Tags, tag) {
+						e.Tags = append(e.Tags, tag)
+					}
+				}
+				e.Read = e.Read || read
+				e.Saved = e.Saved || saved
+				e.Score += score
+			})
+		}
+		if score != 0 {
+			outcome.scores[entry.Link] = score
+		}
+
+		if ignored {
+			outcome.ignored[entry.Link] = true
+			continue
+		}
+
+		if len(channels) > 0 {
+			outcome.notified[entry.Link] = channels
+			outcome.notifications = append(outcome.notifications, Notification{
+				SiteName:  siteName,
+				Title:     entryDisplayTitle(entry),
+				Link:      entry.Link,
+				FeedType:  feedType,
+				Notifiers: channels,
+				Score:     score,
+			})
+		}
+
+		if open && entry.Link != "" {
+			outcome.open = append(outcome.open, entry.Link)
+		}
+	}
+
+	return outcome
+}
+
+func (o ruleOutcome) filter(notification Notification) (Notification, bool) {
+	notification.Score = o.scores[notification.Link]
+	if o.ignored[notification.Link] {
+		return notification, false
+	}
+
+	channels := o.notified[notification.Link]
+	if len(channels) == 0 {
+		return notification, true
+	}
+
+	var remaining []string
+	for _, name := range notification.Notifiers {
+		if !containsFold(channels, name) {
+			remaining = append(remaining, name)
+		}
+	}
+	notification.Notifiers = remaining
+	return notification, len(remaining) > 0 || notification.Priority != nil
+}
+
+func openInBrowser(link string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", link)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", link)
+	default:
+		cmd = exec.Command("xdg-open", link)
+	}
+	return cmd.Start()
+}
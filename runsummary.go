@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RunSummaryConfig posts a summary of every check run to URL, for dashboards
+// rather than people. Header values may be secret references.
+type RunSummaryConfig struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type runSummaryError struct {
+	Site  string `json:"site"`
+	Error string `json:"error"`
+	Kind  string `json:"kind,omitempty"`
+}
+
+// RunSummary is what a check run came to. Statuses counts the sites by
+// outcome (new_entry, unchanged, failed, ...).
+type RunSummary struct {
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	DurationMS int64             `json:"duration_ms"`
+	Sites      int               `json:"sites"`
+	Failed     int               `json:"failed"`
+	Statuses   map[string]int    `json:"statuses"`
+	NewEntries []EntryEvent      `json:"new_entries"`
+	Errors     []runSummaryError `json:"errors"`
+	SaveError  string            `json:"save_error,omitempty"`
+}
+
+// newRunSummary starts a summary if one is to be posted; the methods of a nil
+// summary do nothing.
+func newRunSummary(config Config) *RunSummary {
+	if config.RunSummary == nil {
+		return nil
+	}
+	return &RunSummary{
+		StartedAt:  time.Now(),
+		Statuses:   make(map[string]int),
+		NewEntries: []EntryEvent{},
+		Errors:     []runSummaryError{},
+	}
+}
+
+func (s *RunSummary) add(outcome CheckOutcome, sites *SiteRepo) {
+	if s == nil {
+		return
+	}
+
+	s.Sites++
+	s.Statuses[outcome.Status]++
+	site, _ := sites.Get(outcome.SiteName)
+	for _, entry := range outcome.NewEntries {
+		s.NewEntries = append(s.NewEntries, newEntryEvent(entry, site))
+	}
+	for _, warning := range outcome.Warnings {
+		s.Errors = append(s.Errors, runSummaryError{Site: outcome.SiteName, Error: warning.Error()})
+	}
+	if outcome.Status == CHECK_FAILED {
+		s.Failed++
+		s.Errors = append(s.Errors, runSummaryError{Site: outcome.SiteName, Error: outcome.Err.Error(), Kind: netErrorKind(outcome.Err)})
+	}
+}
+
+// post sends the summary once the run is over, saveErr being how saving its
+// state went. A failure is reported but doesn't fail the run.
+func (s *RunSummary) post(config *RunSummaryConfig, saveErr error, out io.Writer) {
+	if s == nil {
+		return
+	}
+
+	s.FinishedAt = time.Now()
+	s.DurationMS = s.FinishedAt.Sub(s.StartedAt).Milliseconds()
+	if saveErr != nil {
+		s.SaveError = saveErr.Error()
+	}
+
+	if err := postRunSummary(config, s); err != nil {
+		fmt.Fprintf(out, "Run summary → ERROR: %v\n", err)
+	}
+}
+
+func postRunSummary(config *RunSummaryConfig, summary *RunSummary) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("error marshaling summary: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, config.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range config.Headers {
+		value, err := secrets.resolve(value)
+		if err != nil {
+			return fmt.Errorf("header %s: %w", name, err)
+		}
+		req.Header.Set(name, value)
+	}
+
+	client := &http.Client{Timeout: httpTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+func validateRunSummary(config *RunSummaryConfig) error {
+	if config == nil {
+		return nil
+	}
+	if !strings.HasPrefix(config.URL, "http://") && !strings.HasPrefix(config.URL, "https://") && !isSecretRef(config.URL) {
+		return fmt.Errorf("run_summary url must be an http or https URL")
+	}
+	return nil
+}
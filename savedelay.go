@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const DEFAULT_SAVE_DELAY = 30 * time.Second
+
+// saveDelay is how long serve may hold the state of its check cycles before
+// writing it. Zero writes after every cycle.
+func (c Config) saveDelay() time.Duration {
+	if c.SaveDelay == "" {
+		return DEFAULT_SAVE_DELAY
+	}
+	if c.SaveDelay == "0" {
+		return 0
+	}
+	delay, err := parseDuration(c.SaveDelay)
+	if err != nil {
+		return DEFAULT_SAVE_DELAY
+	}
+	return delay
+}
+
+// stateSaver coalesces the writes of consecutive check cycles: the first
+// cycle with changes schedules a save, and later ones until it runs are
+// written along with it.
+type stateSaver struct {
+	saving  sync.Mutex
+	mu      sync.Mutex
+	delay   time.Duration
+	timer   *time.Timer
+	sites   bool
+	entries bool
+	flush   func(sites, entries bool) error
+}
+
+var (
+	saversMu     sync.Mutex
+	savers       []*stateSaver
+	saversSignal sync.Once
+)
+
+func newStateSaver(delay time.Duration, flush func(sites, entries bool) error) *stateSaver {
+	saver := &stateSaver{delay: delay, flush: flush}
+
+	saversMu.Lock()
+	savers = append(savers, saver)
+	saversMu.Unlock()
+
+	// Pending changes are written before serve exits on Ctrl-C or SIGTERM.
+	saversSignal.Do(func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			code := 0
+			saversMu.Lock()
+			for _, saver := range savers {
+				if err := saver.run(); err != nil {
+					fmt.Printf("Error saving state: %v\n", err)
+					code = 1
+				}
+			}
+			saversMu.Unlock()
+			os.Exit(code)
+		}()
+	})
+	return saver
+}
+
+func (d *stateSaver) schedule(sites, entries bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.sites = d.sites || sites
+	d.entries = d.entries || entries
+	if (d.sites || d.entries) && d.timer == nil {
+		d.timer = time.AfterFunc(d.delay, func() {
+			if err := d.run(); err != nil {
+				fmt.Printf("Error saving state: %v\n", err)
+			}
+		})
+	}
+}
+
+// run writes whatever is pending now.
+func (d *stateSaver) run() error {
+	d.saving.Lock()
+	defer d.saving.Unlock()
+
+	d.mu.Lock()
+	sites, entries := d.sites, d.entries
+	d.sites, d.entries = false, false
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.mu.Unlock()
+
+	if !sites && !entries {
+		return nil
+	}
+	return d.flush(sites, entries)
+}
+
+func (s *Server) flushState(sites, entries bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return saveCheckState(os.Stdout, s.store, s.sites.Snapshot(), s.entries, false, sites, entries)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	SAVE_ATTEMPTS    = 3
+	SAVE_RETRY_DELAY = time.Second
+)
+
+// saveFallbackDir is where state that can't be saved is written instead;
+// empty means the system's temporary directory.
+var saveFallbackDir string
+
+// saveOrRecover runs save, trying again after a failure since some pass (a
+// backup holding a lock, space being freed). If it keeps failing, the state
+// is written to a file in the fallback directory, or failing that to stderr,
+// so what the run found can be recovered by hand. The error is returned
+// either way.
+func saveOrRecover(what string, marshal func() ([]byte, error), save func() error) error {
+	var err error
+	for attempt := 1; attempt <= SAVE_ATTEMPTS; attempt++ {
+		if err = save(); err == nil {
+			return nil
+		}
+		if attempt < SAVE_ATTEMPTS {
+			time.Sleep(SAVE_RETRY_DELAY)
+		}
+	}
+
+	data, marshalErr := marshal()
+	if marshalErr != nil {
+		return err
+	}
+
+	dir := saveFallbackDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	path := filepath.Join(dir, fmt.Sprintf("rss-tracker-%s.unsaved-%s.json", what, time.Now().Format("20060102-150405")))
+	if writeErr := os.WriteFile(path, data, 0600); writeErr == nil {
+		fmt.Fprintf(os.Stderr, "(-_-) Couldn't save the %s: %v\n    They were written to %s; copy them back once the problem is fixed.\n", what, err, path)
+		return fmt.Errorf("%w (unsaved %s kept in %s)", err, what, path)
+	}
+
+	fmt.Fprintf(os.Stderr, "(-_-) Couldn't save the %s, nor write them to %s: %v\n    The unsaved %s follow as JSON:\n%s\n", what, dir, err, what, data)
+	return fmt.Errorf("%w (unsaved %s written to stderr)", err, what)
+}
+
+func saveStoreSites(store Store, sites SiteData) error {
+	return saveOrRecover("sites",
+		func() ([]byte, error) { return json.MarshalIndent(sites, "", "  ") },
+		func() error { return store.SaveSites(sites) })
+}
+
+func (s *EntryStore) marshalIndent() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return json.MarshalIndent(s, "", "  ")
+}
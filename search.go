@@ -0,0 +1,199 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const DEFAULT_SEARCH_ENTRIES = 20
+
+// SavedSearch is a named search kept in config.json. Query terms are
+// separated by spaces or "+" and must all match; a term starting with "-"
+// must not. Tags and Sites narrow the search to some sites.
+type SavedSearch struct {
+	Query  string   `json:"query"`
+	Tags   []string `json:"tags,omitempty"`
+	Sites  []string `json:"sites,omitempty"`
+	Unread bool     `json:"unread,omitempty"`
+}
+
+func validateSavedSearch(search SavedSearch) error {
+	include, _ := searchTerms(search.Query)
+	if len(include) == 0 {
+		return fmt.Errorf("query needs at least one term to match")
+	}
+	return nil
+}
+
+// searchTerms splits a query into the terms that must and must not match.
+func searchTerms(query string) (include, exclude []string) {
+	fields := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return r == '+' || r == ' ' || r == '\t'
+	})
+	for _, field := range fields {
+		if term, ok := strings.CutPrefix(field, "-"); ok {
+			if term != "" {
+				exclude = append(exclude, term)
+			}
+			continue
+		}
+		include = append(include, field)
+	}
+	return include, exclude
+}
+
+// matcher returns whether an entry matches the search. Its title, content,
+// author and note are searched.
+func (search SavedSearch) matcher(sites SiteData) func(Entry) bool {
+	include, exclude := searchTerms(search.Query)
+
+	var allowed map[string]bool
+	if len(search.Tags) > 0 || len(search.Sites) > 0 {
+		allowed = make(map[string]bool)
+		for _, name := range search.Sites {
+			allowed[name] = true
+		}
+		for name, site := range sites {
+			for _, tag := range site.Tags {
+				for _, wanted := range search.Tags {
+					if tag == strings.TrimSpace(wanted) {
+						allowed[name] = true
+					}
+				}
+			}
+		}
+	}
+
+	return func(e Entry) bool {
+		if search.Unread && e.Read || allowed != nil && !allowed[e.Site] {
+			return false
+		}
+		text := strings.ToLower(e.Title + "\n" + htmlToText(e.Content) + "\n" + e.Author + "\n" + e.Note)
+		for _, term := range include {
+			if !strings.Contains(text, term) {
+				return false
+			}
+		}
+		for _, term := range exclude {
+			if strings.Contains(text, term) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// searchEntries returns the stored entries matching a search, newest first.
+func searchEntries(entries *EntryStore, sites SiteData, search SavedSearch) []Entry {
+	matches := entries.list(search.matcher(sites))
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].ID > matches[j].ID })
+	return matches
+}
+
+func savedSearchNames(config Config) []string {
+	names := make([]string, 0, len(config.SavedSearches))
+	for name := range config.SavedSearches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupSavedSearch(config Config, name string) (SavedSearch, error) {
+	search, ok := config.SavedSearches[name]
+	if !ok {
+		if len(config.SavedSearches) == 0 {
+			return SavedSearch{}, fmt.Errorf("no saved search '%s' (add saved_searches to config.json)", name)
+		}
+		return SavedSearch{}, fmt.Errorf("no saved search '%s' (saved searches: %s)", name, strings.Join(savedSearchNames(config), ", "))
+	}
+	return search, nil
+}
+
+func runSearch(sites SiteData, config Config, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	saved := fs.String("saved", "", "Run the saved search with this name.")
+	tags := fs.String("tag", "", "Only search sites with one of these comma-separated tags.")
+	unread := fs.Bool("unread", false, "Only search unread entries.")
+	limit := fs.Int("n", DEFAULT_SEARCH_ENTRIES, "Number of entries to show (0 for all).")
+	rest := parseInterspersed(fs, args)
+
+	var search SavedSearch
+	label := strings.Join(rest, " ")
+	switch {
+	case *saved != "" && len(rest) > 0:
+		return fmt.Errorf("give either a query or -saved, not both")
+	case *saved != "":
+		var err error
+		if search, err = lookupSavedSearch(config, *saved); err != nil {
+			return err
+		}
+		label = *saved
+	case len(rest) > 0:
+		search = SavedSearch{Query: label}
+	default:
+		return fmt.Errorf("usage: search <terms...> [-tag t] [-unread] | search -saved <name>")
+	}
+	if *tags != "" {
+		search.Tags = strings.Split(*tags, ",")
+	}
+	if *unread {
+		search.Unread = true
+	}
+	if err := validateSavedSearch(search); err != nil {
+		return err
+	}
+
+	entries, err := readEntries()
+	if err != nil {
+		return err
+	}
+
+	matches := searchEntries(entries, sites, search)
+	total := len(matches)
+	if *limit > 0 && len(matches) > *limit {
+		matches = matches[:*limit]
+	}
+
+	fmt.Printf("Search '%s' → %d matching entries\n\n", label, total)
+	for _, entry := range matches {
+		marker := " "
+		switch {
+		case entry.Saved:
+			marker = "★"
+		case !entry.Read:
+			marker = "•"
+		}
+
+		published := entry.Published
+		if published.IsZero() {
+			published = entry.Discovered
+		}
+		fmt.Printf("%s %s %s → %s%s%s\n   %s\n", marker, entryLabel(entry), entry.Site, entryDisplayTitle(entry), formatAuthor(entry.Author), formatPublished(published, false), entry.Link)
+	}
+
+	if len(matches) < total {
+		fmt.Printf("\n... %d older entries (use -n 0 to show all)\n", total-len(matches))
+	}
+	return nil
+}
+
+// handleSearchFeed serves /searches/<name>.xml, a saved search as an RSS
+// feed.
+func (s *Server) handleSearchFeed(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/searches/"), ".xml")
+	search, err := lookupSavedSearch(s.config, name)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+
+	s.mu.RLock()
+	sites := s.sites.Snapshot()
+	s.mu.RUnlock()
+
+	writeEntryFeed(w, r, "RSS Tracker search: "+name, search.Query, searchEntries(s.entries, sites, search))
+}
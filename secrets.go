@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+const (
+	SECRET_PREFIX   = "secret:"
+	KEYRING_SERVICE = "rss-tracker"
+)
+
+type SecretsConfig struct {
+	Backend     string `json:"backend"`
+	AgeFile     string `json:"age_file,omitempty"`
+	AgeIdentity string `json:"age_identity,omitempty"`
+}
+
+type secretResolver struct {
+	mu         sync.Mutex
+	config     SecretsConfig
+	cache      map[string]string
+	ageSecrets map[string]string
+}
+
+var secrets = &secretResolver{cache: make(map[string]string)}
+
+func isSecretRef(value string) bool {
+	return strings.HasPrefix(value, SECRET_PREFIX)
+}
+
+func (r *secretResolver) resolve(value string) (string, error) {
+	if !isSecretRef(value) {
+		return value, nil
+	}
+	name := strings.TrimPrefix(value, SECRET_PREFIX)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cached, ok := r.cache[name]; ok {
+		return cached, nil
+	}
+
+	var secret string
+	var err error
+	switch r.config.Backend {
+	case "", "keyring":
+		secret, err = keyringGet(name)
+	case "age":
+		secret, err = r.ageGet(name)
+	default:
+		err = fmt.Errorf("unknown secrets backend '%s'", r.config.Backend)
+	}
+	if err != nil {
+		return "", fmt.Errorf("secret '%s': %w", name, err)
+	}
+
+	r.cache[name] = secret
+	return secret, nil
+}
+
+func (r *secretResolver) ageGet(name string) (string, error) {
+	if r.ageSecrets == nil {
+		if r.config.AgeFile == "" || r.config.AgeIdentity == "" {
+			return "", fmt.Errorf("age backend requires age_file and age_identity")
+		}
+
+		out, err := runSecretCommand(nil, "age", "--decrypt", "-i", r.config.AgeIdentity, r.config.AgeFile)
+		if err != nil {
+			return "", err
+		}
+
+		r.ageSecrets = make(map[string]string)
+		if err := json.Unmarshal(out, &r.ageSecrets); err != nil {
+			return "", fmt.Errorf("error parsing decrypted secrets: %w", err)
+		}
+	}
+
+	secret, ok := r.ageSecrets[name]
+	if !ok {
+		return "", fmt.Errorf("not found in %s", r.config.AgeFile)
+	}
+	return secret, nil
+}
+
+func runSecretCommand(stdin []byte, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %s", name, msg)
+		}
+		return nil, fmt.Errorf("%s failed: %w", name, err)
+	}
+	return out, nil
+}
+
+func keyringGet(name string) (string, error) {
+	var out []byte
+	var err error
+
+	switch runtime.GOOS {
+	case "darwin":
+		out, err = runSecretCommand(nil, "security", "find-generic-password", "-s", KEYRING_SERVICE, "-a", name, "-w")
+	case "windows":
+		return "", fmt.Errorf("the OS keyring is not supported on Windows; use the age backend")
+	default:
+		out, err = runSecretCommand(nil, "secret-tool", "lookup", "service", KEYRING_SERVICE, "account", name)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// keyringPrompts reports whether the keyring asks for a secret's value
+// itself. The Keychain's security takes it after -w, where it would show in
+// the process list, so it is left to prompt for it on the terminal instead.
+func keyringPrompts() bool {
+	return runtime.GOOS == "darwin"
+}
+
+// keyringSet stores a secret. Where keyringPrompts, value is unused.
+func keyringSet(name, value string) error {
+	var err error
+
+	switch runtime.GOOS {
+	case "darwin":
+		cmd := exec.Command("security", "add-generic-password", "-U", "-s", KEYRING_SERVICE, "-a", name, "-w")
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err = cmd.Run(); err != nil {
+			err = fmt.Errorf("security failed: %w", err)
+		}
+	case "windows":
+		return fmt.Errorf("the OS keyring is not supported on Windows; use the age backend")
+	default:
+		_, err = runSecretCommand([]byte(value), "secret-tool", "store", "--label", KEYRING_SERVICE+": "+name, "service", KEYRING_SERVICE, "account", name)
+	}
+	return err
+}
+
+func keyringDelete(name string) error {
+	var err error
+
+	switch runtime.GOOS {
+	case "darwin":
+		_, err = runSecretCommand(nil, "security", "delete-generic-password", "-s", KEYRING_SERVICE, "-a", name)
+	case "windows":
+		return fmt.Errorf("the OS keyring is not supported on Windows; use the age backend")
+	default:
+		_, err = runSecretCommand(nil, "secret-tool", "clear", "service", KEYRING_SERVICE, "account", name)
+	}
+	return err
+}
+
+// secretCommands are the commands that use the secrets in the config: to
+// notify and publish, serve, mail reports and sync with FreshRSS. Only they
+// resolve them, so a locked keyring doesn't get in the way of the others.
+var secretCommands = map[string]bool{"": true, "check": true, "serve": true, "report": true, "sync-freshrss": true}
+
+func useSecretsConfig(config Config) {
+	if config.Secrets != nil {
+		secrets.config = *config.Secrets
+	}
+}
+
+// resolveDatabaseSecret resolves the database when it is a secret reference,
+// like a PostgreSQL URL with a password in it. Every command opens the
+// database, so it is resolved whatever the command.
+func resolveDatabaseSecret(config Config) error {
+	if !isSecretRef(databaseFile) {
+		return nil
+	}
+	useSecretsConfig(config)
+
+	database, err := secrets.resolve(databaseFile)
+	if err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	databaseFile = database
+	return nil
+}
+
+func resolveConfigSecrets(config *Config) error {
+	useSecretsConfig(*config)
+
+	resolveField := func(field *string, what string) error {
+		value, err := secrets.resolve(*field)
+		if err != nil {
+			return fmt.Errorf("%s: %w", what, err)
+		}
+		*field = value
+		return nil
+	}
+
+	for name, notifierConfig := range config.Notifiers {
+		for _, field := range []struct {
+			value *string
+			what  string
+		}{
+			{&notifierConfig.WebhookURL, "webhook_url"},
+			{&notifierConfig.URL, "url"},
+			{&notifierConfig.BotToken, "bot_token"},
+			{&notifierConfig.ChatID, "chat_id"},
+			{&notifierConfig.Token, "token"},
+		} {
+			if err := resolveField(field.value, fmt.Sprintf("notifier '%s' %s", name, field.what)); err != nil {
+				return err
+			}
+		}
+		config.Notifiers[name] = notifierConfig
+	}
+
+	if err := resolveField(&config.APIToken, "api_token"); err != nil {
+		return err
+	}
+	if config.ServeAuth != nil {
+		if err := resolveField(&config.ServeAuth.Password, "serve_auth password"); err != nil {
+			return err
+		}
+		for i := range config.ServeAuth.Tokens {
+			if err := resolveField(&config.ServeAuth.Tokens[i], "serve_auth token"); err != nil {
+				return err
+			}
+		}
+	}
+	for name, user := range config.Users {
+		if err := resolveField(&user.Password, fmt.Sprintf("user '%s' password", name)); err != nil {
+			return err
+		}
+		for i := range user.Tokens {
+			if err := resolveField(&user.Tokens[i], fmt.Sprintf("user '%s' token", name)); err != nil {
+				return err
+			}
+		}
+		if err := resolveField(&user.Database, fmt.Sprintf("user '%s' database", name)); err != nil {
+			return err
+		}
+		config.Users[name] = user
+	}
+	if config.MQTT != nil {
+		if err := resolveField(&config.MQTT.Password, "mqtt password"); err != nil {
+			return err
+		}
+	}
+	if config.NATS != nil {
+		if err := resolveField(&config.NATS.Password, "nats password"); err != nil {
+			return err
+		}
+		if err := resolveField(&config.NATS.Token, "nats token"); err != nil {
+			return err
+		}
+	}
+	if config.SMTP != nil {
+		if err := resolveField(&config.SMTP.Password, "smtp password"); err != nil {
+			return err
+		}
+	}
+	if config.Fever != nil {
+		if err := resolveField(&config.Fever.Password, "fever password"); err != nil {
+			return err
+		}
+	}
+	if config.RunSummary != nil {
+		if err := resolveField(&config.RunSummary.URL, "run_summary url"); err != nil {
+			return err
+		}
+	}
+	if config.FreshRSS != nil {
+		if err := resolveField(&config.FreshRSS.Password, "freshrss password"); err != nil {
+			return err
+		}
+	}
+	if config.GReader != nil {
+		if err := resolveField(&config.GReader.Password, "greader password"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func runSecretCommandLine(config Config, args []string) error {
+	if config.Secrets != nil && config.Secrets.Backend == "age" {
+		return fmt.Errorf("the age backend is read-only here: edit the JSON object and re-encrypt it with 'age -r <recipient> -o %s'", config.Secrets.AgeFile)
+	}
+	if len(args) != 2 || (args[0] != "set" && args[0] != "delete") {
+		return fmt.Errorf("usage: secret set <name> | secret delete <name>")
+	}
+
+	name := args[1]
+	if args[0] == "delete" {
+		if err := keyringDelete(name); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Deleted secret '%s'\n", name)
+		return nil
+	}
+
+	if keyringPrompts() {
+		fmt.Printf("Enter value for '%s' when asked\n", name)
+		if err := keyringSet(name, ""); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Stored secret '%s'; reference it as \"%s%s\"\n", name, SECRET_PREFIX, name)
+		return nil
+	}
+
+	fmt.Printf("Enter value for '%s': ", name)
+	value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	value = strings.TrimRight(value, "\r\n")
+	if err != nil && value == "" {
+		return fmt.Errorf("error reading secret: %w", err)
+	}
+	if value == "" {
+		return fmt.Errorf("secret value cannot be empty")
+	}
+
+	if err := keyringSet(name, value); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n✓ Stored secret '%s'; reference it as \"%s%s\"\n", name, SECRET_PREFIX, name)
+	return nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_SERVE_ADDR     = "127.0.0.1:8080"
+	DEFAULT_CHECK_INTERVAL = 30 * time.Minute
+)
+
+type Server struct {
+	mu            sync.RWMutex
+	sites         *SiteRepo
+	config        Config
+	entries       *EntryStore
+	lastRefreshed time.Time
+	oidc          *oidcVerifier
+	store         Store
+	database      string
+	live          liveHub
+	saver         *stateSaver
+	// checking keeps check cycles from overlapping. A cycle holds mu only
+	// while it applies each site's result.
+	checking sync.Mutex
+}
+
+func runServe(sites SiteData, config Config, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", DEFAULT_SERVE_ADDR, "Address to listen on.")
+	interval := fs.Duration("interval", DEFAULT_CHECK_INTERVAL, "Time between background checks (0 disables them).")
+	tlsCert := fs.String("tls-cert", "", "TLS certificate file (PEM).")
+	tlsKey := fs.String("tls-key", "", "TLS private key file (PEM).")
+	acmeDomains := fs.String("acme-domain", "", "Obtain certificates automatically via ACME for these comma-separated domains.")
+	acmeEmail := fs.String("acme-email", "", "Contact email for the ACME account.")
+	acmeCache := fs.String("acme-cache", DEFAULT_ACME_CACHE, "Directory to cache ACME certificates in.")
+	adminAddr := fs.String("admin-addr", "", "Serve pprof and expvar on this localhost address (e.g. 127.0.0.1:6060).")
+	fs.Parse(args)
+
+	tlsOpts := TLSOptions{
+		CertFile:    *tlsCert,
+		KeyFile:     *tlsKey,
+		ACMEDomains: parseTags(*acmeDomains),
+		ACMEEmail:   *acmeEmail,
+		ACMECache:   *acmeCache,
+	}
+	if err := tlsOpts.validate(); err != nil {
+		return err
+	}
+
+	if *adminAddr != "" {
+		if err := validateAdminAddr(*adminAddr); err != nil {
+			return err
+		}
+		startAdminServer(*adminAddr)
+	}
+
+	if len(config.Users) > 0 {
+		handler, err := newMultiUserHandler(config, *interval)
+		if err != nil {
+			return err
+		}
+		return tlsOpts.listenAndServe(*addr, limitRequests(allowCORS(handler, config.CORSOrigins), config.ServeLimits), *interval)
+	}
+
+	entries, err := readEntries()
+	if err != nil {
+		return err
+	}
+
+	server := &Server{
+		sites:    newSiteRepo(sites),
+		config:   config,
+		entries:  entries,
+		store:    openStore(databaseFile, ""),
+		database: databaseFile,
+	}
+	if config.ServeAuth != nil {
+		if server.oidc, err = newOIDCVerifier(config.ServeAuth.OIDC); err != nil {
+			return err
+		}
+	}
+
+	if err := server.listenDaemon(databaseFile); err != nil {
+		return err
+	}
+	if *interval > 0 {
+		go server.checkLoop(*interval)
+	}
+
+	return tlsOpts.listenAndServe(*addr, limitRequests(allowCORS(server.routes(), config.CORSOrigins), config.ServeLimits), *interval)
+}
+
+func (s *Server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fever/", s.handleFever)
+	s.registerGReader(mux)
+	mux.HandleFunc("/api/sites", s.requireAuth(s.handleSites))
+	mux.HandleFunc("/api/entries", s.requireAuth(s.handleListEntries))
+	mux.HandleFunc("/api/entries/", s.requireAuth(s.handleEntry))
+	mux.HandleFunc("/searches/", s.requireAuth(s.handleSearchFeed))
+	mux.HandleFunc("/feeds.xml", s.requireAuth(s.handleAggregateFeed))
+	mux.HandleFunc("/feeds/", s.requireAuth(s.handleTagFeed))
+	mux.HandleFunc("/api/live", s.requireAuth(s.handleLive))
+	mux.HandleFunc("/api/page", s.requireAuth(s.handlePageLookup))
+	mux.HandleFunc("/api/unread", s.requireAuth(s.handleUnreadCount))
+	mux.HandleFunc("/subscribe", s.requireAuth(s.handleSubscribe))
+	return mux
+}
+
+func (s *Server) checkLoop(interval time.Duration) {
+	if delay := s.config.saveDelay(); delay > 0 {
+		s.saver = newStateSaver(delay, s.flushState)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// High priority sites are checked again in between full cycles.
+	var priority <-chan time.Time
+	if priorityInterval := s.config.priorityInterval(); priorityInterval < interval {
+		priorityTicker := time.NewTicker(priorityInterval)
+		defer priorityTicker.Stop()
+		priority = priorityTicker.C
+	}
+
+	s.runCheckCycle()
+	s.refreshFavicons()
+	for {
+		select {
+		case <-ticker.C:
+			s.runCheckCycle()
+			s.refreshFavicons()
+		case <-priority:
+			s.runPriorityCycle()
+		}
+	}
+}
+
+// refreshFavicons fetches icons that are missing or older than a week, on a
+// copy of the sites so requests are not blocked meanwhile.
+func (s *Server) refreshFavicons() {
+	refreshFavicons(s.sites.Snapshot(), false)
+}
+
+func (s *Server) runCheckCycle() {
+	s.checking.Lock()
+	defer s.checking.Unlock()
+
+	if count := s.sites.Len(); count > 0 {
+		s.runChecks(nil, count)
+	}
+	s.mu.Lock()
+	s.lastRefreshed = time.Now()
+	s.mu.Unlock()
+}
+
+// runPriorityCycle checks only the high priority sites.
+func (s *Server) runPriorityCycle() {
+	s.checking.Lock()
+	defer s.checking.Unlock()
+
+	s.mu.RLock()
+	names := highPrioritySites(s.sites.Snapshot())
+	s.mu.RUnlock()
+	if len(names) > 0 {
+		s.runChecks(names, len(names))
+	}
+}
+
+// runChecks checks the named sites, or all of them when names is nil. The
+// caller holds s.checking.
+func (s *Server) runChecks(names []string, count int) {
+	if _, err := s.checkSites(CheckOptions{Sites: names}, count); err != nil {
+		fmt.Printf("Error checking feeds: %v\n", err)
+	}
+}
+
+// checkSites runs a check cycle against the server's state and returns the
+// sites that failed. The caller holds s.checking.
+func (s *Server) checkSites(opts CheckOptions, count int) ([]string, error) {
+	live := s.live.active()
+	var before liveSnapshot
+	if live {
+		before = s.liveSnapshot()
+		s.live.publish(LiveEvent{Type: "cycle_started", Sites: count})
+	}
+
+	opts.Store, opts.Saver, opts.StateLock = s.store, s.saver, &s.mu
+	start := time.Now()
+	failed, err := checkFeeds(s.sites, s.config, s.entries, opts)
+	recordCheckCycle(count, time.Since(start))
+	if live {
+		s.publishCycle(before, time.Since(start))
+	}
+	return failed, err
+}
+
+func siteID(name string) int64 {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int64(h.Sum32() & 0x7fffffff)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) feedURL(site Site) string {
+	feedURL, err := resolveFeedURL(site, s.config)
+	if err != nil {
+		return site.RSSUrl
+	}
+	return feedURL
+}
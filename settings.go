@@ -0,0 +1,260 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	ENV_CONFIG       = "RSS_TRACKER_CONFIG"
+	ENV_DB           = "RSS_TRACKER_DB"
+	ENV_ENTRIES      = "RSS_TRACKER_ENTRIES"
+	ENV_TIMEOUT      = "RSS_TRACKER_TIMEOUT"
+	ENV_WORKERS      = "RSS_TRACKER_WORKERS"
+	ENV_HOST_WORKERS = "RSS_TRACKER_HOST_WORKERS"
+	ENV_BRIDGE       = "RSS_TRACKER_RSS_BRIDGE_URL"
+	ENV_STALE        = "RSS_TRACKER_STALE_AFTER"
+	ENV_DIGEST       = "RSS_TRACKER_DIGEST_THRESHOLD"
+	ENV_DEFAULTS     = "RSS_TRACKER_DEFAULT_NOTIFIERS"
+)
+
+var (
+	configFile     = CONFIG_FILE
+	databaseFile   = DATABASE_FILE
+	entriesFile    = ENTRIES_FILE
+	httpTimeout    = HTTP_TIMEOUT
+	maxWorkers     = MAX_WORKERS
+	maxHostWorkers = MAX_HOST_WORKERS
+)
+
+type settingsFlags struct {
+	config       *string
+	db           *string
+	entries      *string
+	timeout      *string
+	workers      *int
+	hostWorkers  *int
+	maxRedirects *int
+	noDowngrade  *bool
+	sameHost     *bool
+	ip4          *bool
+	ip6          *bool
+}
+
+func registerSettingsFlags() settingsFlags {
+	return settingsFlags{
+		config:       flag.String("config", CONFIG_FILE, "Config file path (env "+ENV_CONFIG+")."),
+		db:           flag.String("db", DATABASE_FILE, "Site database path (env "+ENV_DB+")."),
+		entries:      flag.String("entries", ENTRIES_FILE, "Entry store path (env "+ENV_ENTRIES+")."),
+		timeout:      flag.String("timeout", HTTP_TIMEOUT.String(), "Default HTTP timeout (env "+ENV_TIMEOUT+")."),
+		workers:      flag.Int("workers", MAX_WORKERS, "Maximum concurrent checks (env "+ENV_WORKERS+")."),
+		hostWorkers:  flag.Int("host-workers", MAX_HOST_WORKERS, "Maximum concurrent checks per host (env "+ENV_HOST_WORKERS+")."),
+		maxRedirects: flag.Int("max-redirects", DEFAULT_MAX_REDIRECTS, "Maximum redirects to follow when fetching feeds (0 disables redirects)."),
+		noDowngrade:  flag.Bool("no-downgrade", false, "Refuse redirects from https to http."),
+		sameHost:     flag.Bool("same-host-redirects", false, "Refuse redirects to a different host."),
+		ip4:          flag.Bool("ip4", false, "Connect to feeds over IPv4 only."),
+		ip6:          flag.Bool("ip6", false, "Connect to feeds over IPv6 only."),
+	}
+}
+
+func setFlags() map[string]bool {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return set
+}
+
+func parseTimeoutSetting(value string) (time.Duration, error) {
+	timeout, err := parseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("timeout must be positive, got '%s'", value)
+	}
+	return timeout, nil
+}
+
+func parseWorkersSetting(value string) (int, error) {
+	workers, err := strconv.Atoi(value)
+	if err != nil || workers <= 0 {
+		return 0, fmt.Errorf("workers must be a positive integer, got '%s'", value)
+	}
+	return workers, nil
+}
+
+func resolveConfigFile(flags settingsFlags) {
+	if value := os.Getenv(ENV_CONFIG); value != "" {
+		configFile = value
+	}
+	if setFlags()["config"] {
+		configFile = *flags.config
+	}
+}
+
+func applySettings(config *Config, flags settingsFlags) error {
+	set := setFlags()
+
+	if config.Database != "" {
+		databaseFile = config.Database
+	}
+	if config.Entries != "" {
+		entriesFile = config.Entries
+	}
+	if config.Timeout != "" {
+		timeout, err := parseTimeoutSetting(config.Timeout)
+		if err != nil {
+			return fmt.Errorf("config timeout: %w", err)
+		}
+		httpTimeout = timeout
+	}
+	if config.Workers != 0 {
+		if config.Workers < 0 {
+			return fmt.Errorf("config workers must be positive, got %d", config.Workers)
+		}
+		maxWorkers = config.Workers
+	}
+	if config.Redirects != nil {
+		if config.Redirects.Max != nil {
+			if *config.Redirects.Max < 0 {
+				return fmt.Errorf("config redirects.max must not be negative, got %d", *config.Redirects.Max)
+			}
+			redirectPolicy.Max = *config.Redirects.Max
+		}
+		redirectPolicy.NoDowngrade = config.Redirects.NoDowngrade
+		redirectPolicy.SameHost = config.Redirects.SameHost
+	}
+	if err := applyBrowserConfig(config.Browser); err != nil {
+		return err
+	}
+	if err := applyCrawlConfig(config.Crawl); err != nil {
+		return err
+	}
+	if err := applyDNSConfig(config.DNS); err != nil {
+		return err
+	}
+	saveFallbackDir = config.SaveFallback
+	if err := validateIPVersion(config.IPVersion); err != nil {
+		return fmt.Errorf("config ip_version: %w", err)
+	}
+	ipVersion = config.IPVersion
+	if config.FallbackDelay != "" {
+		delay, err := parseDuration(config.FallbackDelay)
+		if err != nil {
+			return fmt.Errorf("config fallback_delay: %w", err)
+		}
+		fallbackDelay = delay
+	}
+	if config.HostWorkers != 0 {
+		if config.HostWorkers < 0 {
+			return fmt.Errorf("config host_workers must be positive, got %d", config.HostWorkers)
+		}
+		maxHostWorkers = config.HostWorkers
+	}
+
+	if value := os.Getenv(ENV_DB); value != "" {
+		databaseFile = value
+	}
+	if value := os.Getenv(ENV_ENTRIES); value != "" {
+		entriesFile = value
+	}
+	if value := os.Getenv(ENV_TIMEOUT); value != "" {
+		timeout, err := parseTimeoutSetting(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ENV_TIMEOUT, err)
+		}
+		httpTimeout = timeout
+	}
+	if value := os.Getenv(ENV_WORKERS); value != "" {
+		workers, err := parseWorkersSetting(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ENV_WORKERS, err)
+		}
+		maxWorkers = workers
+	}
+	if value := os.Getenv(ENV_HOST_WORKERS); value != "" {
+		workers, err := parseWorkersSetting(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ENV_HOST_WORKERS, err)
+		}
+		maxHostWorkers = workers
+	}
+	if value := os.Getenv(ENV_BRIDGE); value != "" {
+		config.RSSBridgeURL = value
+	}
+	if value := os.Getenv(ENV_STALE); value != "" {
+		if _, err := parseDuration(value); err != nil && value != "off" {
+			return fmt.Errorf("%s: %w", ENV_STALE, err)
+		}
+		config.StaleAfter = value
+	}
+	if value := os.Getenv(ENV_DIGEST); value != "" {
+		threshold, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: invalid number '%s'", ENV_DIGEST, value)
+		}
+		config.DigestThreshold = threshold
+	}
+	if value, ok := os.LookupEnv(ENV_DEFAULTS); ok {
+		config.DefaultNotify = parseTags(value)
+		if config.DefaultNotify == nil {
+			config.DefaultNotify = []string{}
+		}
+		for _, name := range config.DefaultNotify {
+			if _, exists := config.Notifiers[name]; !exists {
+				return fmt.Errorf("%s: unknown notifier '%s'", ENV_DEFAULTS, name)
+			}
+		}
+	}
+
+	if set["db"] {
+		databaseFile = *flags.db
+	}
+	if set["entries"] {
+		entriesFile = *flags.entries
+	}
+	if set["timeout"] {
+		timeout, err := parseTimeoutSetting(*flags.timeout)
+		if err != nil {
+			return fmt.Errorf("-timeout: %w", err)
+		}
+		httpTimeout = timeout
+	}
+	if set["workers"] {
+		if *flags.workers <= 0 {
+			return fmt.Errorf("-workers must be positive, got %d", *flags.workers)
+		}
+		maxWorkers = *flags.workers
+	}
+	if set["max-redirects"] {
+		if *flags.maxRedirects < 0 {
+			return fmt.Errorf("-max-redirects must not be negative, got %d", *flags.maxRedirects)
+		}
+		redirectPolicy.Max = *flags.maxRedirects
+	}
+	if set["no-downgrade"] {
+		redirectPolicy.NoDowngrade = *flags.noDowngrade
+	}
+	if set["same-host-redirects"] {
+		redirectPolicy.SameHost = *flags.sameHost
+	}
+	if *flags.ip4 && *flags.ip6 {
+		return fmt.Errorf("-ip4 and -ip6 can't be combined")
+	}
+	if *flags.ip4 {
+		ipVersion = "4"
+	}
+	if *flags.ip6 {
+		ipVersion = "6"
+	}
+	if set["host-workers"] {
+		if *flags.hostWorkers <= 0 {
+			return fmt.Errorf("-host-workers must be positive, got %d", *flags.hostWorkers)
+		}
+		maxHostWorkers = *flags.hostWorkers
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type SiteExport struct {
+	Name string `json:"name"`
+	Site Site   `json:"site"`
+}
+
+func exportSite(sites SiteData, args []string) error {
+	fs := flag.NewFlagSet("export-site", flag.ExitOnError)
+	output := fs.String("output", "", "Write the export to this file instead of stdout.")
+	credentials := fs.Bool("credentials", false, "Include the feed username, password and request headers.")
+	rest := parseInterspersed(fs, args)
+
+	if len(rest) != 1 {
+		return fmt.Errorf("usage: export-site <site> [-output file.json] [-credentials]")
+	}
+
+	name, err := resolveSiteName(sites, rest[0], bufio.NewReader(os.Stdin))
+	if err != nil {
+		return err
+	}
+
+	site := sites[name]
+	if !*credentials {
+		site.Username, site.Password = "", ""
+		site.Headers = nil
+	}
+
+	data, err := json.MarshalIndent(SiteExport{Name: name, Site: site}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling site: %w", err)
+	}
+	data = append(data, '\n')
+
+	if *output == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		return fmt.Errorf("error writing file: %w", err)
+	}
+
+	fmt.Printf("✓ Exported '%s' to %s\n", name, *output)
+	return nil
+}
+
+func importSite(sites SiteData, config Config, args []string) error {
+	fs := flag.NewFlagSet("import-site", flag.ExitOnError)
+	rename := fs.String("name", "", "Import the site under this name.")
+	replace := fs.Bool("replace", false, "Overwrite an existing site with the same name.")
+	allowCommand := fs.Bool("allow-command", false, "Allow importing a site that runs a local command.")
+	rest := parseInterspersed(fs, args)
+
+	if len(rest) != 1 {
+		return fmt.Errorf("usage: import-site <file.json|-> [-name name] [-replace]")
+	}
+
+	var data []byte
+	var err error
+	if rest[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(rest[0])
+	}
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+
+	var export SiteExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return fmt.Errorf("error parsing site: %w", err)
+	}
+	if export.Site.RSSUrl == "" && export.Site.Bridge == nil && len(export.Site.Command) == 0 {
+		return fmt.Errorf("the file does not contain a site")
+	}
+	if len(export.Site.Command) > 0 && !*allowCommand {
+		return fmt.Errorf("the site runs the command '%s'; review it and pass -allow-command to import it", strings.Join(export.Site.Command, " "))
+	}
+
+	name := strings.TrimSpace(export.Name)
+	if *rename != "" {
+		name = strings.TrimSpace(*rename)
+	}
+	if name == "" {
+		name = export.Site.RSSUrl
+	}
+
+	if _, exists := sites[name]; exists && !*replace {
+		return fmt.Errorf("site '%s' already exists (use -name or -replace)", name)
+	}
+	if owner, exists := siteForAlias(sites, name); exists && owner != name {
+		return fmt.Errorf("'%s' is already an alias of '%s'", name, owner)
+	}
+
+	// Drop aliases that would clash with existing sites.
+	var aliases []string
+	for _, alias := range export.Site.Aliases {
+		_, taken := sites[alias]
+		owner, aliased := siteForAlias(sites, alias)
+		if taken || (aliased && owner != name) {
+			fmt.Printf("Skipping alias '%s': already in use\n", alias)
+			continue
+		}
+		aliases = append(aliases, alias)
+	}
+	export.Site.Aliases = aliases
+
+	for _, notifier := range export.Site.Notifiers {
+		if _, ok := config.Notifiers[notifier]; !ok {
+			fmt.Printf("Note: notifier '%s' is not configured here\n", notifier)
+		}
+	}
+
+	sites[name] = export.Site
+	if err := saveSites(sites); err != nil {
+		return fmt.Errorf("saving sites: %w", err)
+	}
+
+	feedURL, _ := resolveFeedURL(export.Site, config)
+	fmt.Printf("✓ Imported '%s' (%s)\n", name, feedURL)
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	SITE_TYPE_SITEMAP = "sitemap"
+	SITEMAP_PREFIX    = "sitemap:"
+)
+
+type Sitemap struct {
+	XMLName xml.Name     `xml:"urlset"`
+	URLs    []SitemapURL `xml:"url"`
+}
+
+type SitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	Sitemaps []SitemapURL `xml:"sitemap"`
+}
+
+type SitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+func sitemapTitle(loc string) string {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return loc
+	}
+
+	name := path.Base(strings.TrimSuffix(u.Path, "/"))
+	name = strings.TrimSuffix(name, path.Ext(name))
+	if name == "" || name == "." || name == "/" {
+		return u.Host
+	}
+
+	return strings.NewReplacer("-", " ", "_", " ").Replace(name)
+}
+
+func parseSitemap(body []byte) (*FeedResult, error) {
+	if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("decompressing sitemap: %w", err)
+		}
+		body, err = readFeedBody(reader)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing sitemap: %w", err)
+		}
+	}
+
+	var index SitemapIndex
+	if err := xml.Unmarshal(body, &index); err == nil {
+		if len(index.Sitemaps) == 0 {
+			return nil, fmt.Errorf("empty sitemap index")
+		}
+		return nil, fmt.Errorf("this is a sitemap index with %d sitemaps; track one of them instead (e.g. %s)",
+			len(index.Sitemaps), strings.TrimSpace(index.Sitemaps[0].Loc))
+	}
+
+	var sitemap Sitemap
+	if err := xml.Unmarshal(body, &sitemap); err != nil {
+		return nil, fmt.Errorf("parsing sitemap: %w", err)
+	}
+
+	result := &FeedResult{FeedType: FeedTypeSitemap}
+	for _, u := range sitemap.URLs {
+		loc := strings.TrimSpace(u.Loc)
+		if loc == "" {
+			continue
+		}
+		result.Entries = append(result.Entries, FeedEntry{
+			Title:     sitemapTitle(loc),
+			Link:      loc,
+			Published: parseFeedDate(u.LastMod),
+		})
+	}
+
+	sort.SliceStable(result.Entries, func(i, j int) bool {
+		return result.Entries[i].Published.After(result.Entries[j].Published)
+	})
+
+	if len(result.Entries) > 0 {
+		result.Title = result.Entries[0].Title
+		result.LatestLink = result.Entries[0].Link
+	}
+
+	return result, nil
+}
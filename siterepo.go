@@ -0,0 +1,143 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SiteRepo guards the sites of a check or a server that several goroutines
+// read and change. Sites go in and out as deep copies, so a Site from Get or
+// Snapshot can be used, and even changed, without holding the lock. Changes
+// should go through Update, which applies them to the current site rather
+// than to a copy that may have been changed meanwhile.
+type SiteRepo struct {
+	mu    sync.RWMutex
+	sites SiteData
+}
+
+// newSiteRepo wraps sites without copying them; the caller should not touch
+// the map directly while the repo is in use.
+func newSiteRepo(sites SiteData) *SiteRepo {
+	if sites == nil {
+		sites = make(SiteData)
+	}
+	return &SiteRepo{sites: sites}
+}
+
+func (r *SiteRepo) Get(name string) (Site, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	site, ok := r.sites[name]
+	return site.clone(), ok
+}
+
+// Update changes a site in place. It reports false, without calling update,
+// when the site no longer exists.
+func (r *SiteRepo) Update(name string, update func(site *Site)) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	site, ok := r.sites[name]
+	if !ok {
+		return false
+	}
+	update(&site)
+	r.sites[name] = site
+	return true
+}
+
+func (r *SiteRepo) Put(name string, site Site) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sites[name] = site.clone()
+}
+
+func (r *SiteRepo) Delete(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sites, name)
+}
+
+func (r *SiteRepo) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.sites)
+}
+
+func (r *SiteRepo) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.sites))
+	for name := range r.sites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Snapshot returns a copy of all sites, to range over or save.
+func (r *SiteRepo) Snapshot() SiteData {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sites := make(SiteData, len(r.sites))
+	for name, site := range r.sites {
+		sites[name] = site.clone()
+	}
+	return sites
+}
+
+// clone copies a site along with the slices, maps and pointers it holds, so
+// the copy shares nothing with the original. Nil stays nil and empty stays
+// empty, which matters for Notifiers.
+func (s Site) clone() Site {
+	s.Tags = slices.Clone(s.Tags)
+	s.Notifiers = slices.Clone(s.Notifiers)
+	s.Aliases = slices.Clone(s.Aliases)
+	s.Command = slices.Clone(s.Command)
+	s.Latencies = slices.Clone(s.Latencies)
+	s.RecentFailures = slices.Clone(s.RecentFailures)
+	s.Headers = maps.Clone(s.Headers)
+	s.SnoozedUntil = cloneTime(s.SnoozedUntil)
+	s.LastErrorAt = cloneTime(s.LastErrorAt)
+	s.RetryAfter = cloneTime(s.RetryAfter)
+	s.LastPublished = cloneTime(s.LastPublished)
+	s.StaleAlertedAt = cloneTime(s.StaleAlertedAt)
+	if s.Bridge != nil {
+		bridge := *s.Bridge
+		bridge.Params = maps.Clone(bridge.Params)
+		s.Bridge = &bridge
+	}
+	if s.Filter != nil {
+		filter := *s.Filter
+		filter.Keywords = slices.Clone(filter.Keywords)
+		filter.Exclude = slices.Clone(filter.Exclude)
+		filter.Authors = slices.Clone(filter.Authors)
+		filter.ExcludeAuthors = slices.Clone(filter.ExcludeAuthors)
+		filter.Languages = slices.Clone(filter.Languages)
+		filter.ExcludeLangs = slices.Clone(filter.ExcludeLangs)
+		s.Filter = &filter
+	}
+	if s.MailCursor != nil {
+		cursor := *s.MailCursor
+		s.MailCursor = &cursor
+	}
+	if s.Mail != nil {
+		mail := *s.Mail
+		mail.From = slices.Clone(mail.From)
+		s.Mail = &mail
+	}
+	return s
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	copied := *t
+	return &copied
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSiteRepoCopiesShareNothing(t *testing.T) {
+	snoozed := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	repo := newSiteRepo(SiteData{"blog": {
+		Tags:         []string{"news"},
+		Notifiers:    []string{},
+		Headers:      map[string]string{"X-Key": "a"},
+		SnoozedUntil: &snoozed,
+		Filter:       &EntryFilter{Keywords: []string{"go"}},
+		Bridge:       &BridgeSource{Name: "Reddit", Params: map[string]string{"r": "golang"}},
+	}})
+
+	got, _ := repo.Get("blog")
+	snapshot := repo.Snapshot()["blog"]
+	for _, site := range []Site{got, snapshot} {
+		site.Tags[0] = "changed"
+		site.Headers["X-Key"] = "changed"
+		*site.SnoozedUntil = time.Time{}
+		site.Filter.Keywords[0] = "changed"
+		site.Bridge.Params["r"] = "changed"
+	}
+
+	site, _ := repo.Get("blog")
+	if site.Tags[0] != "news" || site.Headers["X-Key"] != "a" || !site.SnoozedUntil.Equal(snoozed) ||
+		site.Filter.Keywords[0] != "go" || site.Bridge.Params["r"] != "golang" {
+		t.Fatalf("changing a copy changed the repo: %+v", site)
+	}
+	if site.Notifiers == nil {
+		t.Fatal("an empty notifiers list came back nil")
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+type staleSite struct {
+	SiteName      string
+	LastPublished time.Time
+	Threshold     time.Duration
+	Notifiers     []string
+}
+
+func (s *Site) updateLastPublished(entries []FeedEntry) bool {
+	var newest time.Time
+	for _, entry := range entries {
+		if entry.Published.After(newest) {
+			newest = entry.Published
+		}
+	}
+
+	if newest.IsZero() || (s.LastPublished != nil && !newest.After(*s.LastPublished)) {
+		return false
+	}
+
+	s.LastPublished = &newest
+	return true
+}
+
+func (c Config) staleThreshold(site Site) time.Duration {
+	value := c.StaleAfter
+	if site.StaleAfter != "" {
+		value = site.StaleAfter
+	}
+	if value == "" || value == "off" {
+		return 0
+	}
+
+	threshold, err := parseDuration(value)
+	if err != nil {
+		return 0
+	}
+	return threshold
+}
+
+func formatDays(d time.Duration) string {
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+func reportStaleSites(out io.Writer, sites *SiteRepo, checked []string, config Config) bool {
+	now := time.Now()
+	changed := false
+
+	var stale, newlyStale []staleSite
+	for _, name := range checked {
+		site, ok := sites.Get(name)
+		if !ok {
+			continue
+		}
+		threshold := config.staleThreshold(site)
+		if threshold <= 0 || site.LastPublished == nil {
+			continue
+		}
+
+		if now.Sub(*site.LastPublished) < threshold {
+			if site.StaleAlertedAt != nil {
+				sites.Update(name, func(site *Site) { site.StaleAlertedAt = nil })
+				changed = true
+			}
+			continue
+		}
+
+		entry := staleSite{
+			SiteName:      name,
+			LastPublished: *site.LastPublished,
+			Threshold:     threshold,
+			Notifiers:     config.notifiersFor(site),
+		}
+		stale = append(stale, entry)
+
+		if site.StaleAlertedAt == nil && !site.Muted {
+			newlyStale = append(newlyStale, entry)
+			sites.Update(name, func(site *Site) { site.StaleAlertedAt = &now })
+			changed = true
+		}
+	}
+
+	if len(stale) == 0 {
+		return changed
+	}
+
+	sort.Slice(stale, func(i, j int) bool { return stale[i].LastPublished.Before(stale[j].LastPublished) })
+
+	fmt.Fprintf(out, "\nStale feeds (%d):\n", len(stale))
+	for _, s := range stale {
+		fmt.Fprintf(out, "  %s → last post %s ago on %s (threshold %s)\n",
+			s.SiteName, formatDays(now.Sub(s.LastPublished)), s.LastPublished.Format("2006-01-02"), formatDays(s.Threshold))
+	}
+	fmt.Fprintln(out)
+
+	dispatchStaleAlerts(out, config, newlyStale)
+	return changed
+}
+
+func dispatchStaleAlerts(out io.Writer, config Config, stale []staleSite) {
+	if len(stale) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for _, name := range sortedNotifierNames(config) {
+		if config.Notifiers[name].isTask() {
+			continue
+		}
+
+		var lines []string
+		for _, s := range stale {
+			if routedTo(s.Notifiers, name) {
+				lines = append(lines, fmt.Sprintf("• %s — last post %s ago (%s)",
+					s.SiteName, formatDays(now.Sub(s.LastPublished)), s.LastPublished.Format("2006-01-02")))
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		notifier, err := newNotifier(config.Notifiers[name])
+		if err != nil {
+			fmt.Fprintf(out, "Notifier '%s' → ERROR: %v\n", name, err)
+			continue
+		}
+
+		message := fmt.Sprintf("RSS Tracker: %d feeds went stale\n\n%s", len(lines), strings.Join(lines, "\n"))
+		if err := notifier.Send(message); err != nil {
+			fmt.Fprintf(out, "Notifier '%s' → ERROR: %v\n", name, err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type starredEntry struct {
+	ID        int64  `json:"id"`
+	Site      string `json:"site"`
+	Title     string `json:"title"`
+	Link      string `json:"link"`
+	Published string `json:"published,omitempty"`
+	Note      string `json:"note,omitempty"`
+}
+
+func findEntry(entries *EntryStore, ref string) (Entry, error) {
+	ref = strings.TrimSpace(strings.TrimPrefix(ref, "#"))
+
+	matches := entries.list(func(e Entry) bool { return e.Ref == ref })
+	if len(matches) == 0 {
+		if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+			matches = entries.list(func(e Entry) bool { return e.ID == id })
+		} else {
+			matches = entries.list(func(e Entry) bool { return e.Link == ref })
+		}
+	}
+
+	if len(matches) == 0 {
+		return Entry{}, fmt.Errorf("no entry matches '%s' (use an entry reference, ID or link)", ref)
+	}
+	return matches[0], nil
+}
+
+func starEntries(args []string, starred bool) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: star <ref|id|link>... | unstar <ref|id|link>...")
+	}
+
+	entries, err := readEntries()
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range args {
+		entry, err := findEntry(entries, ref)
+		if err != nil {
+			return err
+		}
+
+		entries.update(func(e Entry) bool {
+			return e.ID == entry.ID
+		}, func(e *Entry) {
+			e.Saved = starred
+		})
+
+		if starred {
+			fmt.Printf("★ %s %s → %s\n", entryLabel(entry), entry.Site, entryDisplayTitle(entry))
+		} else {
+			fmt.Printf("☆ %s %s → %s\n", entryLabel(entry), entry.Site, entryDisplayTitle(entry))
+		}
+	}
+
+	return entries.save()
+}
+
+func renderStarredMarkdown(w io.Writer, starred []Entry) {
+	fmt.Fprintf(w, "# Starred entries\n\n")
+	for _, entry := range starred {
+		title := strings.NewReplacer("[", "\\[", "]", "\\]").Replace(entryDisplayTitle(entry))
+		fmt.Fprintf(w, "- [%s](%s) — %s", title, entry.Link, entry.Site)
+		if !entry.Published.IsZero() {
+			fmt.Fprintf(w, ", %s", entry.Published.Local().Format("2006-01-02"))
+		}
+		fmt.Fprintln(w)
+		if entry.Note != "" {
+			fmt.Fprintf(w, "  > %s\n", entry.Note)
+		}
+	}
+}
+
+func renderStarredJSON(w io.Writer, starred []Entry) error {
+	list := make([]starredEntry, 0, len(starred))
+	for _, entry := range starred {
+		item := starredEntry{
+			ID:    entry.ID,
+			Site:  entry.Site,
+			Title: entryDisplayTitle(entry),
+			Link:  entry.Link,
+			Note:  entry.Note,
+		}
+		if !entry.Published.IsZero() {
+			item.Published = entry.Published.Format(time.RFC3339)
+		}
+		list = append(list, item)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(list)
+}
+
+func listStarred(args []string) error {
+	fs := flag.NewFlagSet("starred", flag.ExitOnError)
+	format := fs.String("format", "", "Export format: markdown or json (default: plain list).")
+	output := fs.String("output", "", "Write the export to this file instead of stdout.")
+	fs.Parse(args)
+
+	entries, err := readEntries()
+	if err != nil {
+		return err
+	}
+
+	starred := entries.list(func(e Entry) bool { return e.Saved })
+	sort.Slice(starred, func(i, j int) bool {
+		return starred[i].ID > starred[j].ID
+	})
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("error creating file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	switch *format {
+	case "":
+		if len(starred) == 0 {
+			fmt.Fprintln(w, "No starred entries")
+		}
+		for _, entry := range starred {
+			fmt.Fprintf(w, "★ %s %s → %s - %s\n", entryLabel(entry), entry.Site, entryDisplayTitle(entry), entry.Link)
+			if entry.Note != "" {
+				fmt.Fprintf(w, "   ✎ %s\n", entry.Note)
+			}
+		}
+	case "markdown", "md":
+		renderStarredMarkdown(w, starred)
+	case "json":
+		if err := renderStarredJSON(w, starred); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown format '%s'", *format)
+	}
+
+	if *output != "" {
+		fmt.Printf("✓ %d starred entries written to %s\n", len(starred), *output)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+)
+
+type latencyStats struct {
+	SiteName string
+	Samples  int
+	P50      time.Duration
+	P95      time.Duration
+}
+
+func percentile(sorted []int64, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(p*float64(len(sorted))+0.999999) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return time.Duration(sorted[rank]) * time.Millisecond
+}
+
+func computeLatencyStats(sites SiteData) []latencyStats {
+	var stats []latencyStats
+
+	for name, site := range sites {
+		if len(site.Latencies) == 0 {
+			continue
+		}
+
+		sorted := append([]int64(nil), site.Latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		stats = append(stats, latencyStats{
+			SiteName: name,
+			Samples:  len(sorted),
+			P50:      percentile(sorted, 0.50),
+			P95:      percentile(sorted, 0.95),
+		})
+	}
+
+	return stats
+}
+
+func printStats(sites SiteData, config Config, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	slow := fs.Bool("slow", false, "List the slowest sites by p95 latency.")
+	health := fs.Bool("health", false, "List sites by health score, least healthy first.")
+	limit := fs.Int("n", 10, "Number of sites to list with --slow or --health.")
+	fs.Parse(args)
+
+	if *health {
+		if len(sites) == 0 {
+			fmt.Println("No sites configured. Use -a to add sites.")
+			return nil
+		}
+		printHealth(sites, config, *limit)
+		return nil
+	}
+
+	stats := computeLatencyStats(sites)
+	if len(stats) == 0 {
+		fmt.Println("No latency data recorded yet. Run a check first.")
+		return nil
+	}
+
+	if *slow {
+		sort.Slice(stats, func(i, j int) bool {
+			if stats[i].P95 != stats[j].P95 {
+				return stats[i].P95 > stats[j].P95
+			}
+			return stats[i].SiteName < stats[j].SiteName
+		})
+		if *limit > 0 && len(stats) > *limit {
+			stats = stats[:*limit]
+		}
+	} else {
+		sort.Slice(stats, func(i, j int) bool { return stats[i].SiteName < stats[j].SiteName })
+	}
+
+	for i, s := range stats {
+		health := computeHealth(s.SiteName, sites[s.SiteName], config)
+		fmt.Printf("%d. %s → p50 %v, p95 %v (%d samples), health %d\n", i+1, s.SiteName, s.P50, s.P95, s.Samples, health.Score)
+	}
+
+	return nil
+}
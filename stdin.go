@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+type stdinSite struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type JSONEntry struct {
+	Title     string     `json:"title"`
+	Link      string     `json:"link"`
+	Published *time.Time `json:"published,omitempty"`
+}
+
+type JSONCheckResult struct {
+	Name       string      `json:"name"`
+	URL        string      `json:"url"`
+	FeedType   string      `json:"feed_type,omitempty"`
+	Title      string      `json:"latest_title,omitempty"`
+	LatestLink string      `json:"latest_link,omitempty"`
+	Entries    []JSONEntry `json:"entries,omitempty"`
+	ElapsedMs  int64       `json:"elapsed_ms,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+func parseStdinSites(data []byte) (SiteData, error) {
+	sites := make(SiteData)
+	trimmed := bytes.TrimSpace(data)
+
+	switch {
+	case len(trimmed) == 0:
+		return sites, nil
+
+	case trimmed[0] == '{':
+		if err := json.Unmarshal(trimmed, &sites); err != nil {
+			return nil, fmt.Errorf("error parsing JSON sites: %w", err)
+		}
+
+	case trimmed[0] == '[':
+		var list []stdinSite
+		if err := json.Unmarshal(trimmed, &list); err != nil {
+			var urls []string
+			if json.Unmarshal(trimmed, &urls) != nil {
+				return nil, fmt.Errorf("error parsing JSON site list: %w", err)
+			}
+			list = nil
+			for _, u := range urls {
+				list = append(list, stdinSite{URL: u})
+			}
+		}
+		for _, item := range list {
+			name := item.Name
+			if name == "" {
+				name = item.URL
+			}
+			sites[name] = Site{RSSUrl: item.URL}
+		}
+
+	default:
+		scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			fields := strings.Fields(line)
+			url := fields[len(fields)-1]
+			name := strings.TrimSpace(strings.TrimSuffix(line, url))
+			if name == "" {
+				name = url
+			}
+			sites[name] = Site{RSSUrl: url}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("error reading site list: %w", err)
+		}
+	}
+
+	return sites, nil
+}
+
+func toJSONCheckResult(result CheckResult, config Config) JSONCheckResult {
+	feedURL, _ := resolveFeedURL(result.Site, config)
+	out := JSONCheckResult{
+		Name:      result.SiteName,
+		URL:       feedURL,
+		ElapsedMs: result.Result.Elapsed.Milliseconds(),
+	}
+
+	if result.Result.Error != nil {
+		out.Error = result.Result.Error.Error()
+		return out
+	}
+
+	out.FeedType = feedTypeString(result.Result.FeedType)
+	out.Title = result.Result.Title
+	out.LatestLink = result.Result.LatestLink
+	for _, entry := range result.Result.Entries {
+		jsonEntry := JSONEntry{Title: entry.Title, Link: entry.Link}
+		if !entry.Published.IsZero() {
+			published := entry.Published
+			jsonEntry.Published = &published
+		}
+		out.Entries = append(out.Entries, jsonEntry)
+	}
+	return out
+}
+
+// runStdinCheck checks the sites read from in, writing the results to out as
+// JSON, and returns the sites that failed.
+func runStdinCheck(config Config, in io.Reader, out io.Writer) ([]string, error) {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return nil, fmt.Errorf("error reading stdin: %w", err)
+	}
+
+	sites, err := parseStdinSites(data)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(sites))
+	for name := range sites {
+		names = append(names, name)
+	}
+
+	results := []JSONCheckResult{}
+	for result := range dispatchChecks(sites, names, config, time.Time{}, nil) {
+		results = append(results, toJSONCheckResult(result, config))
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	var failed []string
+	for _, result := range results {
+		if result.Error != "" {
+			failed = append(failed, result.Name)
+		}
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return failed, encoder.Encode(results)
+}
+
+// readMemorySites builds the sites for an in-memory run from feed URLs given
+// as arguments, or from a site list on stdin when there are none.
+func readMemorySites(urls []string, in io.Reader) (SiteData, error) {
+	if len(urls) == 0 {
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return nil, fmt.Errorf("error reading stdin: %w", err)
+		}
+		return parseStdinSites(data)
+	}
+
+	sites := make(SiteData)
+	for _, url := range urls {
+		sites[url] = Site{RSSUrl: url}
+	}
+	return sites, nil
+}
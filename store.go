@@ -0,0 +1,470 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	BOLT_OPEN_TIMEOUT = 5 * time.Second
+	MEMORY_DATABASE   = ":memory:"
+)
+
+var (
+	boltSitesBucket      = []byte("sites")
+	boltEntriesBucket    = []byte("entries")
+	boltValidatorsBucket = []byte("validators")
+	boltMetaBucket       = []byte("meta")
+	boltTrashBucket      = []byte("trash")
+	boltNextIDKey        = []byte("next_id")
+	boltTrashKey         = []byte("sites")
+	boltPrunedKey        = []byte("pruned")
+)
+
+type Store interface {
+	LoadSites() (SiteData, error)
+	SaveSites(sites SiteData) error
+	LoadEntries() (*EntryStore, error)
+	SaveEntries(entries *EntryStore) error
+	LoadTrash() ([]TrashedSite, error)
+	SaveTrash(trash []TrashedSite) error
+}
+
+// jsonStore keeps sites and entries in two JSON files.
+type jsonStore struct {
+	sitesPath   string
+	entriesPath string
+}
+
+// boltStore keeps sites, entries and HTTP validators in one bbolt database.
+type boltStore struct {
+	path string
+}
+
+// memoryStore keeps state for the lifetime of the process only.
+type memoryStore struct {
+	sites   SiteData
+	entries []byte
+	trash   []TrashedSite
+}
+
+var memory = &memoryStore{}
+
+type httpValidators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+func isBoltPath(path string) bool {
+	switch filepath.Ext(path) {
+	case ".db", ".bolt":
+		return true
+	}
+	return false
+}
+
+func openStore(database, entries string) Store {
+	if database == MEMORY_DATABASE {
+		return memory
+	}
+	if isPostgresDSN(database) {
+		return &postgresStore{dsn: database}
+	}
+	if isBoltPath(database) {
+		return &boltStore{path: database}
+	}
+	if entries == "" {
+		entries = entriesFile
+	}
+	return &jsonStore{sitesPath: database, entriesPath: entries}
+}
+
+func (s *jsonStore) LoadSites() (SiteData, error) {
+	data, err := os.ReadFile(s.sitesPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make(SiteData), nil
+		}
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	if len(data) == 0 {
+		return make(SiteData), nil
+	}
+
+	var sites SiteData
+	if err := json.Unmarshal(data, &sites); err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %w", err)
+	}
+
+	return sites, nil
+}
+
+func (s *jsonStore) SaveSites(sites SiteData) error {
+	data, err := json.MarshalIndent(sites, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling JSON: %w", err)
+	}
+
+	return writeFileAtomic(s.sitesPath, data)
+}
+
+func (s *jsonStore) LoadEntries() (*EntryStore, error) {
+	store := newEntryStore(s)
+
+	data, err := os.ReadFile(s.entriesPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading entries: %w", err)
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, store); err != nil {
+			return nil, fmt.Errorf("error parsing entries: %w", err)
+		}
+	}
+
+	store.index()
+	return store, nil
+}
+
+func (s *jsonStore) SaveEntries(entries *EntryStore) error {
+	data, err := entries.marshalIndent()
+	if err != nil {
+		return fmt.Errorf("error marshaling entries: %w", err)
+	}
+
+	return writeFileAtomic(s.entriesPath, data)
+}
+
+// writeFileAtomic replaces a file only once its new contents are fully
+// written, so a full disk leaves the old file intact instead of truncated.
+// A symlink is followed, and the file keeps its permissions.
+func writeFileAtomic(path string, data []byte) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, mode); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func (s *memoryStore) LoadSites() (SiteData, error) {
+	sites := make(SiteData, len(s.sites))
+	for name, site := range s.sites {
+		sites[name] = site
+	}
+	return sites, nil
+}
+
+func (s *memoryStore) SaveSites(sites SiteData) error {
+	s.sites = make(SiteData, len(sites))
+	for name, site := range sites {
+		s.sites[name] = site
+	}
+	return nil
+}
+
+func (s *memoryStore) LoadEntries() (*EntryStore, error) {
+	store := newEntryStore(s)
+	if len(s.entries) > 0 {
+		if err := json.Unmarshal(s.entries, store); err != nil {
+			return nil, fmt.Errorf("error parsing entries: %w", err)
+		}
+	}
+	store.index()
+	return store, nil
+}
+
+func (s *memoryStore) SaveEntries(entries *EntryStore) error {
+	entries.mu.RLock()
+	data, err := json.Marshal(entries)
+	entries.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("error marshaling entries: %w", err)
+	}
+	s.entries = data
+	return nil
+}
+
+func (s *memoryStore) LoadTrash() ([]TrashedSite, error) {
+	return append([]TrashedSite(nil), s.trash...), nil
+}
+
+func (s *memoryStore) SaveTrash(trash []TrashedSite) error {
+	s.trash = append([]TrashedSite(nil), trash...)
+	return nil
+}
+
+// The trash lives next to the sites file: sites.json keeps it in sites.trash.json.
+func (s *jsonStore) trashPath() string {
+	return strings.TrimSuffix(s.sitesPath, filepath.Ext(s.sitesPath)) + ".trash.json"
+}
+
+func (s *jsonStore) LoadTrash() ([]TrashedSite, error) {
+	data, err := os.ReadFile(s.trashPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading trash: %w", err)
+	}
+
+	var trash []TrashedSite
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &trash); err != nil {
+			return nil, fmt.Errorf("error parsing trash: %w", err)
+		}
+	}
+	return trash, nil
+}
+
+func (s *jsonStore) SaveTrash(trash []TrashedSite) error {
+	if len(trash) == 0 {
+		if err := os.Remove(s.trashPath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(trash, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling trash: %w", err)
+	}
+	return os.WriteFile(s.trashPath(), data, 0644)
+}
+
+func (s *boltStore) open() (*bolt.DB, error) {
+	db, err := bolt.Open(s.path, 0644, &bolt.Options{Timeout: BOLT_OPEN_TIMEOUT})
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+	return db, nil
+}
+
+func (s *boltStore) view(fn func(tx *bolt.Tx) error) error {
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		return nil
+	}
+
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+func (s *boltStore) update(fn func(tx *bolt.Tx) error) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(fn)
+}
+
+// resetBucket drops and recreates a bucket so deleted keys don't linger.
+func resetBucket(tx *bolt.Tx, name []byte) (*bolt.Bucket, error) {
+	if tx.Bucket(name) != nil {
+		if err := tx.DeleteBucket(name); err != nil {
+			return nil, err
+		}
+	}
+	return tx.CreateBucket(name)
+}
+
+func (s *boltStore) LoadSites() (SiteData, error) {
+	sites := make(SiteData)
+
+	err := s.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltSitesBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		validators := tx.Bucket(boltValidatorsBucket)
+		return bucket.ForEach(func(name, data []byte) error {
+			var site Site
+			if err := json.Unmarshal(data, &site); err != nil {
+				return fmt.Errorf("error parsing site '%s': %w", name, err)
+			}
+
+			if validators != nil {
+				if raw := validators.Get(name); raw != nil {
+					var v httpValidators
+					if err := json.Unmarshal(raw, &v); err == nil {
+						site.ETag, site.LastModified = v.ETag, v.LastModified
+					}
+				}
+			}
+
+			sites[string(name)] = site
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sites, nil
+}
+
+func (s *boltStore) SaveSites(sites SiteData) error {
+	return s.update(func(tx *bolt.Tx) error {
+		bucket, err := resetBucket(tx, boltSitesBucket)
+		if err != nil {
+			return err
+		}
+		validators, err := resetBucket(tx, boltValidatorsBucket)
+		if err != nil {
+			return err
+		}
+
+		for name, site := range sites {
+			v := httpValidators{ETag: site.ETag, LastModified: site.LastModified}
+			site.ETag, site.LastModified = "", ""
+
+			data, err := json.Marshal(site)
+			if err != nil {
+				return fmt.Errorf("error marshaling site '%s': %w", name, err)
+			}
+			if err := bucket.Put([]byte(name), data); err != nil {
+				return err
+			}
+
+			if v.ETag != "" || v.LastModified != "" {
+				data, err := json.Marshal(v)
+				if err != nil {
+					return err
+				}
+				if err := validators.Put([]byte(name), data); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) LoadEntries() (*EntryStore, error) {
+	store := newEntryStore(s)
+
+	err := s.view(func(tx *bolt.Tx) error {
+		if meta := tx.Bucket(boltMetaBucket); meta != nil {
+			if raw := meta.Get(boltNextIDKey); raw != nil {
+				if id, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+					store.NextID = id
+				}
+			}
+			if raw := meta.Get(boltPrunedKey); raw != nil {
+				if err := json.Unmarshal(raw, &store.Pruned); err != nil {
+					return fmt.Errorf("error parsing pruned entries: %w", err)
+				}
+			}
+		}
+
+		bucket := tx.Bucket(boltEntriesBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, data []byte) error {
+			var entry Entry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return fmt.Errorf("error parsing entry %d: %w", binary.BigEndian.Uint64(key), err)
+			}
+			store.Entries = append(store.Entries, entry)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	store.index()
+	return store, nil
+}
+
+func (s *boltStore) SaveEntries(entries *EntryStore) error {
+	entries.mu.RLock()
+	defer entries.mu.RUnlock()
+
+	return s.update(func(tx *bolt.Tx) error {
+		bucket, err := resetBucket(tx, boltEntriesBucket)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries.Entries {
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return fmt.Errorf("error marshaling entry %d: %w", entry.ID, err)
+			}
+			key := make([]byte, 8)
+			binary.BigEndian.PutUint64(key, uint64(entry.ID))
+			if err := bucket.Put(key, data); err != nil {
+				return err
+			}
+		}
+
+		meta, err := tx.CreateBucketIfNotExists(boltMetaBucket)
+		if err != nil {
+			return err
+		}
+		if err := meta.Put(boltNextIDKey, []byte(strconv.FormatInt(entries.NextID, 10))); err != nil {
+			return err
+		}
+		pruned, err := json.Marshal(entries.Pruned)
+		if err != nil {
+			return err
+		}
+		return meta.Put(boltPrunedKey, pruned)
+	})
+}
+
+func (s *boltStore) LoadTrash() ([]TrashedSite, error) {
+	var trash []TrashedSite
+	err := s.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltTrashBucket)
+		if bucket == nil {
+			return nil
+		}
+		if raw := bucket.Get(boltTrashKey); raw != nil {
+			if err := json.Unmarshal(raw, &trash); err != nil {
+				return fmt.Errorf("error parsing trash: %w", err)
+			}
+		}
+		return nil
+	})
+	return trash, err
+}
+
+func (s *boltStore) SaveTrash(trash []TrashedSite) error {
+	data, err := json.Marshal(trash)
+	if err != nil {
+		return fmt.Errorf("error marshaling trash: %w", err)
+	}
+
+	return s.update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(boltTrashBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(boltTrashKey, data)
+	})
+}
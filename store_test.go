@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// A site's notifiers list set to [] means console only, while no list at all
+// means the defaults, so saving must keep the two apart.
+func TestSiteNotifiersRoundTrip(t *testing.T) {
+	for _, file := range []string{"sites.json", "sites.db"} {
+		t.Run(file, func(t *testing.T) {
+			store := openStore(filepath.Join(t.TempDir(), file), filepath.Join(t.TempDir(), "entries.json"))
+			sites := SiteData{
+				"console":  {RSSUrl: "https://example.com/a.xml", Notifiers: []string{}},
+				"defaults": {RSSUrl: "https://example.com/b.xml"},
+				"phone":    {RSSUrl: "https://example.com/c.xml", Notifiers: []string{"phone"}},
+			}
+			if err := store.SaveSites(sites); err != nil {
+				t.Fatal(err)
+			}
+
+			loaded, err := store.LoadSites()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := loaded["console"].Notifiers; got == nil || len(got) != 0 {
+				t.Errorf("[] loaded back as %#v", got)
+			}
+			if got := loaded["defaults"].Notifiers; got != nil {
+				t.Errorf("no notifiers loaded back as %#v", got)
+			}
+			if got := loaded["phone"].Notifiers; len(got) != 1 || got[0] != "phone" {
+				t.Errorf("[\"phone\"] loaded back as %#v", got)
+			}
+
+			config := Config{Notifiers: map[string]NotifierConfig{"phone": {}}}
+			if got := config.notifiersFor(loaded["console"]); len(got) != 0 {
+				t.Errorf("console only site routed to %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+var sharedURLPattern = regexp.MustCompile(`https?://\S+`)
+
+type subscribeCandidate struct {
+	DiscoveredFeed
+	Subscribed string
+}
+
+type subscribePage struct {
+	PageURL string
+	Token   string
+	Error   string
+	Feeds   []subscribeCandidate
+	Added   string
+	AddedAs string
+	Existed bool
+}
+
+var subscribeTemplate = template.Must(template.New("subscribe").Funcs(template.FuncMap{
+	"feedType": feedTypeString,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Subscribe</title></head>
+<body>
+<h1>Subscribe</h1>
+{{- if .Error}}
+<p><strong>{{.Error}}</strong></p>
+{{- end}}
+{{- if .AddedAs}}
+<p>{{if .Existed}}Already subscribed as{{else}}✓ Subscribed to{{end}} <a href="{{.Added}}">{{.AddedAs}}</a>.</p>
+{{- else if .Feeds}}
+<p>Feeds found on <a href="{{.PageURL}}">{{.PageURL}}</a>:</p>
+{{- range .Feeds}}
+<form method="post" action="/subscribe{{if $.Token}}?token={{$.Token}}{{end}}">
+<input type="hidden" name="feed" value="{{.URL}}">
+<p><strong>{{if .Title}}{{.Title}}{{else}}{{.URL}}{{end}}</strong> ({{feedType .FeedType}})<br><small>{{.URL}}</small></p>
+{{- if .Subscribed}}
+<p>Already subscribed as <em>{{.Subscribed}}</em>.</p>
+{{- else}}
+<p><label>Name <input name="name" value="{{.Title}}"></label>
+<label>Tags <input name="tags" placeholder="news, go"></label>
+<button type="submit">Subscribe</button></p>
+{{- end}}
+</form>
+{{- end}}
+{{- else}}
+<form method="get" action="/subscribe">
+{{- if .Token}}
+<input type="hidden" name="token" value="{{.Token}}">
+{{- end}}
+<p><input name="url" value="{{.PageURL}}" placeholder="https://example.com/blog" size="40"> <button type="submit">Find feeds</button></p>
+</form>
+{{- end}}
+</body>
+</html>
+`))
+
+func renderSubscribePage(w http.ResponseWriter, status int, page subscribePage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	subscribeTemplate.Execute(w, page)
+}
+
+// sharedPageURL takes the page from ?url=, or from ?text= as sent by share
+// targets that put the link in the shared text.
+func sharedPageURL(query url.Values) string {
+	if pageURL := query.Get("url"); pageURL != "" {
+		return pageURL
+	}
+	return sharedURLPattern.FindString(query.Get("text"))
+}
+
+// handleSubscribe serves /subscribe: GET lists the feeds a page offers and
+// POST subscribes to the one picked.
+func (s *Server) handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	page := subscribePage{Token: r.URL.Query().Get("token")}
+
+	switch r.Method {
+	case http.MethodGet:
+		page.PageURL = sharedPageURL(r.URL.Query())
+		if page.PageURL == "" {
+			renderSubscribePage(w, http.StatusOK, page)
+			return
+		}
+
+		feeds, err := discoverFeeds(page.PageURL)
+		if err != nil {
+			page.Error = err.Error()
+			renderSubscribePage(w, http.StatusUnprocessableEntity, page)
+			return
+		}
+		for _, feed := range feeds {
+			page.Feeds = append(page.Feeds, subscribeCandidate{DiscoveredFeed: feed, Subscribed: s.subscribedAs(feed.URL)})
+		}
+		renderSubscribePage(w, http.StatusOK, page)
+
+	case http.MethodPost:
+		// Browsers send basic auth credentials with cross-site form posts too.
+		if !originAllowed(r, s.config.CORSOrigins) {
+			page.Error = "cross-origin requests are not allowed"
+			renderSubscribePage(w, http.StatusForbidden, page)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			page.Error = fmt.Sprintf("invalid form: %v", err)
+			renderSubscribePage(w, http.StatusBadRequest, page)
+			return
+		}
+
+		feed, err := discoverFeed(r.PostForm.Get("feed"))
+		if err != nil {
+			page.Error = err.Error()
+			renderSubscribePage(w, http.StatusUnprocessableEntity, page)
+			return
+		}
+
+		name, created, err := s.addDiscoveredSite(feed, r.PostForm.Get("name"), parseTags(r.PostForm.Get("tags")))
+		if err != nil {
+			page.Error = err.Error()
+			renderSubscribePage(w, http.StatusInternalServerError, page)
+			return
+		}
+		if created {
+			fmt.Printf("✓ Added '%s' via subscribe page (%s)\n", name, feed.URL)
+		}
+		page.Added, page.AddedAs, page.Existed = feed.URL, name, !created
+		renderSubscribePage(w, http.StatusOK, page)
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const TODOIST_API_URL = "https://api.todoist.com/rest/v2/tasks"
+
+// Task notifiers turn each routed entry into a "Read: <title>" task instead of
+// a message, so they are never digested and only receive explicitly routed entries.
+type EntryNotifier interface {
+	SendEntry(n Notification) error
+}
+
+type TaskwarriorNotifier struct {
+	Project string
+	Tags    []string
+}
+
+type TodoistNotifier struct {
+	Token   string
+	URL     string
+	Project string
+	Tags    []string
+}
+
+func (c NotifierConfig) isTask() bool {
+	return c.Type == "taskwarrior" || c.Type == "todoist"
+}
+
+func taskTitle(n Notification) string {
+	title := n.Title
+	if title == "" {
+		title = "Untitled"
+	}
+	return "Read: " + title
+}
+
+func (n *TaskwarriorNotifier) SendEntry(notification Notification) error {
+	task := map[string]any{
+		"description": taskTitle(notification),
+		"entry":       time.Now().UTC().Format("20060102T150405Z"),
+		"status":      "pending",
+		"annotations": []map[string]string{{
+			"entry":       time.Now().UTC().Format("20060102T150405Z"),
+			"description": notification.Link,
+		}},
+	}
+	if n.Project != "" {
+		task["project"] = n.Project
+	}
+	if len(n.Tags) > 0 {
+		task["tags"] = n.Tags
+	}
+
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("error marshaling task: %w", err)
+	}
+
+	cmd := exec.Command("task", "rc.confirmation=off", "rc.verbose=nothing", "import", "-")
+	cmd.Stdin = bytes.NewReader(data)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("task import failed: %s", msg)
+		}
+		return fmt.Errorf("task import failed: %w", err)
+	}
+
+	return nil
+}
+
+func (n *TaskwarriorNotifier) Send(message string) error {
+	title, link, _ := strings.Cut(message, "\n")
+	return n.SendEntry(Notification{Title: title, Link: link})
+}
+
+func (n *TodoistNotifier) SendEntry(notification Notification) error {
+	task := map[string]any{
+		"content":     fmt.Sprintf("[%s](%s)", taskTitle(notification), notification.Link),
+		"description": notification.SiteName,
+	}
+	if n.Project != "" {
+		task["project_id"] = n.Project
+	}
+	if len(n.Tags) > 0 {
+		task["labels"] = n.Tags
+	}
+
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("error marshaling task: %w", err)
+	}
+
+	endpoint := n.URL
+	if endpoint == "" {
+		endpoint = TODOIST_API_URL
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+n.Token)
+
+	client := &http.Client{Timeout: httpTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (n *TodoistNotifier) Send(message string) error {
+	title, link, _ := strings.Cut(message, "\n")
+	return n.SendEntry(Notification{Title: title, Link: link})
+}
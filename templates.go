@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+const (
+	DEFAULT_TEMPLATES_DIR = "templates"
+
+	TEMPLATE_NOTIFICATION  = "notification.txt"
+	TEMPLATE_DIGEST_TEXT   = "digest.txt"
+	TEMPLATE_DIGEST_HTML   = "digest.html"
+	TEMPLATE_SLACK         = "slack.json"
+	TEMPLATE_EMAIL_SUBJECT = "email_subject.txt"
+)
+
+// builtinTemplates are used for every template the templates directory
+// doesn't have.
+var builtinTemplates = map[string]string{
+	TEMPLATE_NOTIFICATION: `{{.SiteName}} → NEW ENTRY: {{untitled .Title}}
+{{.Link}}
+`,
+	TEMPLATE_DIGEST_TEXT: `RSS Tracker: {{len .Notifications}} new entries
+{{range .Notifications}}
+• {{.SiteName}} — {{untitled .Title}}
+  {{.Link}}{{end}}
+`,
+	TEMPLATE_DIGEST_HTML: htmlReportSource,
+	TEMPLATE_SLACK: `{"text": {{json .Text}}}
+`,
+	TEMPLATE_EMAIL_SUBJECT: `{{.Title}}
+`,
+}
+
+// templateNames lists the templates in the order they are written out.
+var templateNames = []string{TEMPLATE_NOTIFICATION, TEMPLATE_DIGEST_TEXT, TEMPLATE_DIGEST_HTML, TEMPLATE_SLACK, TEMPLATE_EMAIL_SUBJECT}
+
+type templateSet struct {
+	text map[string]*texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var outputTemplates = mustLoadBuiltinTemplates()
+
+var textTemplateFuncs = texttemplate.FuncMap{
+	"untitled": func(title string) string {
+		if title == "" {
+			return "Untitled"
+		}
+		return title
+	},
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"title": entryDisplayTitle,
+}
+
+func mustLoadBuiltinTemplates() *templateSet {
+	set, err := parseTemplates(func(name string) (string, error) { return builtinTemplates[name], nil })
+	if err != nil {
+		panic(err)
+	}
+	return set
+}
+
+// defaultTemplatesDir is the templates directory next to config.json, used
+// when templates_dir isn't set.
+func defaultTemplatesDir() string {
+	return filepath.Join(filepath.Dir(configFile), DEFAULT_TEMPLATES_DIR)
+}
+
+// loadTemplates reads the templates in dir, using the built-in one for each
+// file that is missing. The default directory may be missing altogether.
+func loadTemplates(dir string) (*templateSet, error) {
+	if dir == "" {
+		dir = defaultTemplatesDir()
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			return outputTemplates, nil
+		}
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("templates_dir '%s' is not a directory", dir)
+	}
+
+	return parseTemplates(func(name string) (string, error) {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			return builtinTemplates[name], nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	})
+}
+
+func parseTemplates(source func(name string) (string, error)) (*templateSet, error) {
+	set := &templateSet{text: make(map[string]*texttemplate.Template)}
+	for _, name := range templateNames {
+		text, err := source(name)
+		if err != nil {
+			return nil, fmt.Errorf("reading template %s: %w", name, err)
+		}
+
+		if name == TEMPLATE_DIGEST_HTML {
+			set.html, err = htmltemplate.New(name).Funcs(htmltemplate.FuncMap{"title": entryDisplayTitle}).Parse(text)
+		} else {
+			set.text[name], err = texttemplate.New(name).Funcs(textTemplateFuncs).Parse(text)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing template %s: %w", name, err)
+		}
+	}
+	return set, nil
+}
+
+// renderText executes a text template. Trailing newlines are dropped, so
+// template files may end with one.
+func (t *templateSet) renderText(name string, data any) (string, error) {
+	var b strings.Builder
+	if err := t.text[name].Execute(&b, data); err != nil {
+		return "", fmt.Errorf("rendering template %s: %w", name, err)
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// renderMessage renders a notification template, falling back to the
+// built-in one if a custom template fails, so the message still goes out.
+func (t *templateSet) renderMessage(name string, data any) string {
+	message, err := t.renderText(name, data)
+	if err == nil {
+		return message
+	}
+	fmt.Printf("(-_-) %v; using the built-in template\n", err)
+	message, _ = mustLoadBuiltinTemplates().renderText(name, data)
+	return message
+}
+
+// writeTemplates writes the built-in templates into dir as a starting point,
+// leaving files that already exist alone.
+func writeTemplates(config Config, args []string) error {
+	dir := config.TemplatesDir
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	if dir == "" {
+		dir = defaultTemplatesDir()
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", dir, err)
+	}
+	for _, name := range templateNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			fmt.Printf("→ %s exists, left alone\n", path)
+			continue
+		}
+		if err := os.WriteFile(path, []byte(builtinTemplates[name]), 0644); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+		fmt.Printf("✓ Wrote %s\n", path)
+	}
+	return nil
+}
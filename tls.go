@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	DEFAULT_ACME_CACHE      = "certs"
+	ACME_CHALLENGE_ADDR     = ":80"
+	TLS_READ_HEADER_TIMEOUT = 10 * time.Second
+)
+
+type TLSOptions struct {
+	CertFile    string
+	KeyFile     string
+	ACMEDomains []string
+	ACMEEmail   string
+	ACMECache   string
+}
+
+func (o TLSOptions) validate() error {
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return fmt.Errorf("-tls-cert and -tls-key must be given together")
+	}
+	if o.CertFile != "" && len(o.ACMEDomains) > 0 {
+		return fmt.Errorf("use either -tls-cert/-tls-key or -acme-domain, not both")
+	}
+	return nil
+}
+
+func (o TLSOptions) listenAndServe(addr string, handler http.Handler, interval time.Duration) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: TLS_READ_HEADER_TIMEOUT,
+		MaxHeaderBytes:    SERVE_MAX_HEADER_BYTES,
+	}
+
+	switch {
+	case o.CertFile != "":
+		fmt.Printf("Serving on https://%s (check interval: %v)\n", addr, interval)
+		return server.ListenAndServeTLS(o.CertFile, o.KeyFile)
+
+	case len(o.ACMEDomains) > 0:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(o.ACMEDomains...),
+			Cache:      autocert.DirCache(o.ACMECache),
+			Email:      o.ACMEEmail,
+		}
+		server.TLSConfig = manager.TLSConfig()
+
+		// HTTP-01 challenges; other plain HTTP requests are redirected to HTTPS.
+		go func() {
+			challenge := &http.Server{
+				Addr:              ACME_CHALLENGE_ADDR,
+				Handler:           manager.HTTPHandler(nil),
+				ReadHeaderTimeout: TLS_READ_HEADER_TIMEOUT,
+			}
+			if err := challenge.ListenAndServe(); err != nil {
+				fmt.Printf("ACME challenge listener → ERROR: %v\n", err)
+			}
+		}()
+
+		fmt.Printf("Serving on https://%s for %v (check interval: %v)\n", addr, o.ACMEDomains, interval)
+		return server.ListenAndServeTLS("", "")
+
+	default:
+		fmt.Printf("Serving on http://%s (check interval: %v)\n", addr, interval)
+		return server.ListenAndServe()
+	}
+}
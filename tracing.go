@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_TRACING_SERVICE = "rss-tracker"
+	ENV_OTLP_ENDPOINT       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	ENV_OTEL_SERVICE        = "OTEL_SERVICE_NAME"
+
+	otlpSpanKindInternal = 1
+	otlpSpanKindClient   = 3
+	otlpStatusError      = 2
+)
+
+type TracingConfig struct {
+	Endpoint    string            `json:"endpoint"`
+	ServiceName string            `json:"service_name,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+// Tracer collects spans of a check cycle and exports them over OTLP/HTTP
+// (JSON encoding) when the cycle's root span ends.
+type Tracer struct {
+	config  TracingConfig
+	mu      sync.Mutex
+	pending []*Span
+}
+
+type Span struct {
+	tracer   *Tracer
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+	attrs    map[string]any
+	err      error
+}
+
+var tracer *Tracer
+
+func newTracer(config *TracingConfig) *Tracer {
+	var tc TracingConfig
+	if config != nil {
+		tc = *config
+	}
+	if tc.Endpoint == "" {
+		tc.Endpoint = os.Getenv(ENV_OTLP_ENDPOINT)
+	}
+	if tc.Endpoint == "" {
+		return nil
+	}
+	if tc.ServiceName == "" {
+		tc.ServiceName = os.Getenv(ENV_OTEL_SERVICE)
+	}
+	if tc.ServiceName == "" {
+		tc.ServiceName = DEFAULT_TRACING_SERVICE
+	}
+	return &Tracer{config: tc}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// startSpan begins a span under parent, or a new trace when parent is nil.
+// It returns nil when tracing is disabled; all Span methods accept nil.
+func startSpan(parent *Span, name string) *Span {
+	t := tracer
+	if parent != nil {
+		t = parent.tracer
+	}
+	if t == nil {
+		return nil
+	}
+
+	span := &Span{
+		tracer: t,
+		spanID: randomHex(8),
+		name:   name,
+		kind:   otlpSpanKindInternal,
+		start:  time.Now(),
+		attrs:  make(map[string]any),
+	}
+	if parent != nil {
+		span.traceID, span.parentID = parent.traceID, parent.spanID
+	} else {
+		span.traceID = randomHex(16)
+	}
+	return span
+}
+
+func (s *Span) setClient() {
+	if s != nil {
+		s.kind = otlpSpanKindClient
+	}
+}
+
+func (s *Span) set(key string, value any) {
+	if s != nil {
+		s.attrs[key] = value
+	}
+}
+
+func (s *Span) fail(err error) {
+	if s != nil && err != nil {
+		s.err = err
+	}
+}
+
+func (s *Span) finish() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+
+	t := s.tracer
+	t.mu.Lock()
+	t.pending = append(t.pending, s)
+	var batch []*Span
+	if s.parentID == "" {
+		batch, t.pending = t.pending, nil
+	}
+	t.mu.Unlock()
+
+	if batch != nil {
+		if err := t.export(batch); err != nil {
+			fmt.Printf("Tracing → ERROR: %v\n", err)
+		}
+	}
+}
+
+func otlpValue(value any) map[string]any {
+	switch v := value.(type) {
+	case string:
+		return map[string]any{"stringValue": v}
+	case bool:
+		return map[string]any{"boolValue": v}
+	case int:
+		return map[string]any{"intValue": strconv.Itoa(v)}
+	case int64:
+		return map[string]any{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		return map[string]any{"doubleValue": v}
+	default:
+		return map[string]any{"stringValue": fmt.Sprint(v)}
+	}
+}
+
+func otlpAttributes(attrs map[string]any) []map[string]any {
+	list := make([]map[string]any, 0, len(attrs))
+	for key, value := range attrs {
+		list = append(list, map[string]any{"key": key, "value": otlpValue(value)})
+	}
+	return list
+}
+
+func (t *Tracer) export(spans []*Span) error {
+	otlpSpans := make([]map[string]any, 0, len(spans))
+	for _, s := range spans {
+		span := map[string]any{
+			"traceId":           s.traceID,
+			"spanId":            s.spanID,
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attrs),
+		}
+		if s.parentID != "" {
+			span["parentSpanId"] = s.parentID
+		}
+		if s.err != nil {
+			span["status"] = map[string]any{"code": otlpStatusError, "message": s.err.Error()}
+		}
+		otlpSpans = append(otlpSpans, span)
+	}
+
+	payload := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": otlpAttributes(map[string]any{"service.name": t.config.ServiceName}),
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": DEFAULT_TRACING_SERVICE},
+				"spans": otlpSpans,
+			}},
+		}},
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshaling spans: %w", err)
+	}
+
+	endpoint := strings.TrimSuffix(t.config.Endpoint, "/")
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint += "/v1/traces"
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid endpoint: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range t.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	client := &http.Client{Timeout: httpTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("exporting spans: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
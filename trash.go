@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+const DEFAULT_TRASH_DAYS = 30
+
+type TrashedSite struct {
+	Name      string    `json:"name"`
+	Site      Site      `json:"site"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+func (c Config) trashRetention() time.Duration {
+	days := c.TrashDays
+	if days == 0 {
+		days = DEFAULT_TRASH_DAYS
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// readTrash loads the trash and drops sites kept longer than the retention period.
+func readTrash(store Store, config Config) ([]TrashedSite, error) {
+	trash, err := store.LoadTrash()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-config.trashRetention())
+	kept := trash[:0]
+	for _, item := range trash {
+		if item.DeletedAt.After(cutoff) {
+			kept = append(kept, item)
+		}
+	}
+
+	if len(kept) != len(trash) {
+		if err := store.SaveTrash(kept); err != nil {
+			return nil, fmt.Errorf("saving trash: %w", err)
+		}
+	}
+	return kept, nil
+}
+
+func removeSite(sites SiteData, config Config, args []string) error {
+	fs := flag.NewFlagSet("remove", flag.ExitOnError)
+	tag := fs.String("tag", "", "Remove every site with this tag.")
+	positional := parseInterspersed(fs, args)
+
+	if err := refuseWhileServing(); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	var names []string
+	switch {
+	case *tag != "" && len(positional) == 0:
+		tagged, err := sitesTagged(sites, *tag)
+		if err != nil {
+			return err
+		}
+		if !confirmGroup(reader, "Remove", *tag, tagged) {
+			fmt.Println("No sites removed")
+			return nil
+		}
+		names = tagged
+	case *tag == "" && len(positional) == 1:
+		name, err := resolveSiteName(sites, positional[0], reader)
+		if err != nil {
+			return err
+		}
+		if !confirmMatch(reader, positional[0], name, "Remove") {
+			fmt.Println("Site not removed")
+			return nil
+		}
+		names = []string{name}
+	default:
+		return fmt.Errorf("usage: remove <site> | remove -tag <tag>")
+	}
+
+	store := openStore(databaseFile, "")
+	trash, err := readTrash(store, config)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, name := range names {
+		trash = append(trash, TrashedSite{Name: name, Site: sites[name], DeletedAt: now})
+	}
+	if err := store.SaveTrash(trash); err != nil {
+		return fmt.Errorf("saving trash: %w", err)
+	}
+
+	for _, name := range names {
+		delete(sites, name)
+	}
+	if err := store.SaveSites(sites); err != nil {
+		return fmt.Errorf("saving sites: %w", err)
+	}
+
+	if *tag != "" {
+		fmt.Printf("✓ Moved %d sites tagged '%s' to the trash (run 'trash restore <site>' to restore one)\n", len(names), *tag)
+		return nil
+	}
+	fmt.Printf("✓ Moved '%s' to the trash (run 'undo' to restore it)\n", names[0])
+	return nil
+}
+
+func restoreSite(sites SiteData, config Config, name string) error {
+	store := openStore(databaseFile, "")
+	trash, err := readTrash(store, config)
+	if err != nil {
+		return err
+	}
+	if len(trash) == 0 {
+		return fmt.Errorf("the trash is empty")
+	}
+
+	// Without a name, restore the most recently removed site.
+	index := len(trash) - 1
+	if name != "" {
+		index = -1
+		for i := len(trash) - 1; i >= 0; i-- {
+			if trash[i].Name == name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return fmt.Errorf("'%s' is not in the trash", name)
+		}
+	}
+
+	item := trash[index]
+	if _, exists := sites[item.Name]; exists {
+		return fmt.Errorf("a site named '%s' already exists", item.Name)
+	}
+
+	sites[item.Name] = item.Site
+	if err := store.SaveSites(sites); err != nil {
+		return fmt.Errorf("saving sites: %w", err)
+	}
+
+	trash = append(trash[:index], trash[index+1:]...)
+	if err := store.SaveTrash(trash); err != nil {
+		return fmt.Errorf("saving trash: %w", err)
+	}
+
+	fmt.Printf("✓ Restored '%s'\n", item.Name)
+	return nil
+}
+
+func runUndo(sites SiteData, config Config, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: undo")
+	}
+	return restoreSite(sites, config, "")
+}
+
+func runTrash(sites SiteData, config Config, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "restore":
+			if len(args) != 2 {
+				return fmt.Errorf("usage: trash restore <site>")
+			}
+			return restoreSite(sites, config, args[1])
+		case "empty":
+			if err := openStore(databaseFile, "").SaveTrash(nil); err != nil {
+				return fmt.Errorf("saving trash: %w", err)
+			}
+			fmt.Println("✓ Trash emptied")
+			return nil
+		default:
+			return fmt.Errorf("usage: trash [restore <site>|empty]")
+		}
+	}
+
+	trash, err := readTrash(openStore(databaseFile, ""), config)
+	if err != nil {
+		return err
+	}
+	if len(trash) == 0 {
+		fmt.Println("The trash is empty")
+		return nil
+	}
+
+	sort.SliceStable(trash, func(i, j int) bool { return trash[i].DeletedAt.After(trash[j].DeletedAt) })
+	retention := config.trashRetention()
+	for _, item := range trash {
+		left := time.Until(item.DeletedAt.Add(retention))
+		fmt.Printf("%s → removed %s, purged in %d days (%s)\n",
+			item.Name, item.DeletedAt.Local().Format("2006-01-02 15:04"), int(left.Hours()/24)+1, item.Site.RSSUrl)
+	}
+	return nil
+}
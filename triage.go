@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"unicode"
+)
+
+const TRIAGE_HELP = "[o]pen  [s]tar  [r]ead  [n]ext/space skip  [m]ute feed  [d]elete  [q]uit"
+
+// keyReader reads single key presses. On a terminal it turns off line
+// buffering with stty while it is in use; otherwise, or where stty isn't
+// available, keys are read a line at a time.
+type keyReader struct {
+	reader *bufio.Reader
+	saved  string
+}
+
+func newKeyReader() *keyReader {
+	k := &keyReader{reader: bufio.NewReader(os.Stdin)}
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return k
+	}
+
+	saved, err := stty("-g")
+	if err != nil {
+		return k
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return k
+	}
+	k.saved = strings.TrimSpace(saved)
+
+	// Interrupting mustn't leave the terminal without line buffering.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		<-signals
+		k.restore()
+		fmt.Println()
+		os.Exit(130)
+	}()
+	return k
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+func (k *keyReader) restore() {
+	if k.saved != "" {
+		stty(k.saved)
+	}
+}
+
+// read returns the key pressed, lower-cased; an empty line reads as a space.
+func (k *keyReader) read() (rune, error) {
+	if k.saved != "" {
+		key, _, err := k.reader.ReadRune()
+		return unicode.ToLower(key), err
+	}
+
+	line, err := k.reader.ReadString('\n')
+	line = strings.TrimSpace(line)
+	if line == "" {
+		if err != nil {
+			return 0, err
+		}
+		return ' ', nil
+	}
+	return unicode.ToLower([]rune(line)[0]), nil
+}
+
+// deleteEntry removes an entry for good, keeping it from being recorded
+// again.
+func (s *EntryStore) deleteEntry(id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, entry := range s.Entries {
+		if entry.ID == id {
+			s.addPruned(entryKey(entry.Site, entry.GUID, entry.Link))
+			s.Entries = append(s.Entries[:i], s.Entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// runTriage steps through the unread entries in the order next takes them,
+// acting on each with a single key. Every decision is saved as it is made,
+// so quitting halfway loses nothing.
+func runTriage(sites SiteData, args []string) error {
+	fs := flag.NewFlagSet("triage", flag.ExitOnError)
+	siteQuery := fs.String("site", "", "Only triage entries from this site (name or alias).")
+	tag := fs.String("tag", "", "Only triage entries from sites with this tag.")
+	parseInterspersed(fs, args)
+
+	if err := refuseWhileServing(); err != nil {
+		return err
+	}
+
+	siteName := ""
+	if *siteQuery != "" {
+		name, err := resolveSiteName(sites, *siteQuery, bufio.NewReader(os.Stdin))
+		if err != nil {
+			return err
+		}
+		siteName = name
+	}
+
+	entries, err := readEntries()
+	if err != nil {
+		return err
+	}
+
+	unread := entries.list(func(e Entry) bool {
+		if e.Read || (siteName != "" && e.Site != siteName) {
+			return false
+		}
+		return *tag == "" || hasTag(sites[e.Site].Tags, *tag)
+	})
+	if len(unread) == 0 {
+		fmt.Println("(-_-) Nothing left to read")
+		return nil
+	}
+	sortForReading(unread)
+
+	keys := newKeyReader()
+	defer keys.restore()
+
+	fmt.Println(TRIAGE_HELP)
+	muted := make(map[string]bool)
+	counts := make(map[string]int)
+	for i, entry := range unread {
+		if muted[entry.Site] {
+			continue
+		}
+
+		fmt.Printf("\n[%d/%d] %s %s → %s%s%s\n   %s\n", i+1, len(unread), entryLabel(entry), entry.Site,
+			entryDisplayTitle(entry), formatAuthor(entry.Author), formatPublished(entry.Published, true), entry.Link)
+
+		var done string
+		for done == "" {
+			fmt.Print("> ")
+			key, err := keys.read()
+			if err != nil {
+				fmt.Println()
+				printTriageCounts(counts)
+				return nil
+			}
+
+			switch key {
+			case 'o':
+				if err := openInBrowser(entry.Link); err != nil {
+					fmt.Printf("(-_-) Couldn't open %s: %v\n", entry.Link, err)
+					continue
+				}
+				entries.update(func(e Entry) bool { return e.ID == entry.ID }, func(e *Entry) { e.Read = true })
+				done = "opened"
+			case 's':
+				entries.update(func(e Entry) bool { return e.ID == entry.ID }, func(e *Entry) { e.Saved, e.Read = true, true })
+				done = "starred"
+			case 'r':
+				entries.update(func(e Entry) bool { return e.ID == entry.ID }, func(e *Entry) { e.Read = true })
+				done = "read"
+			case 'n', ' ', '\n', '\r':
+				done = "skipped"
+			case 'm':
+				site := sites[entry.Site]
+				site.Muted = true
+				sites[entry.Site] = site
+				if err := saveSites(sites); err != nil {
+					return fmt.Errorf("saving sites: %w", err)
+				}
+				entries.update(func(e Entry) bool { return e.ID == entry.ID }, func(e *Entry) { e.Read = true })
+				muted[entry.Site] = true
+				done = "muted"
+			case 'd':
+				entries.deleteEntry(entry.ID)
+				done = "deleted"
+			case 'q':
+				fmt.Println("q")
+				printTriageCounts(counts)
+				return nil
+			default:
+				fmt.Println(string(key))
+				fmt.Println(TRIAGE_HELP)
+			}
+		}
+
+		fmt.Println(done)
+		if done == "muted" {
+			fmt.Printf("✓ Muted '%s'; its other entries are left for later\n", entry.Site)
+		}
+		counts[done]++
+		if done != "skipped" {
+			if err := entries.save(); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Println("\n✓ Nothing left to triage")
+	printTriageCounts(counts)
+	return nil
+}
+
+func printTriageCounts(counts map[string]int) {
+	var parts []string
+	for _, done := range []string{"opened", "starred", "read", "skipped", "muted", "deleted"} {
+		if counts[done] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[done], done))
+		}
+	}
+	if len(parts) > 0 {
+		fmt.Println(strings.Join(parts, ", "))
+	}
+}
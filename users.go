@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type UserConfig struct {
+	Password string   `json:"password"`
+	Tokens   []string `json:"tokens,omitempty"`
+	Database string   `json:"database,omitempty"`
+	Entries  string   `json:"entries,omitempty"`
+}
+
+type multiUserHandler struct {
+	names   []string
+	servers map[string]*Server
+	routes  map[string]http.Handler
+}
+
+func (u UserConfig) paths(name string) (database, entries string) {
+	// Users of a PostgreSQL server share its database, scoped by user name.
+	if u.Database == "" && isPostgresDSN(databaseFile) {
+		return databaseFile, ""
+	}
+
+	dir := filepath.Dir(databaseFile)
+
+	database = u.Database
+	if database == "" {
+		database = filepath.Join(dir, "sites-"+name+".json")
+	}
+	entries = u.Entries
+	if entries == "" {
+		entries = filepath.Join(dir, "entries-"+name+".json")
+	}
+	return database, entries
+}
+
+func newUserServer(name string, user UserConfig, config Config) (*Server, error) {
+	if user.Password == "" {
+		return nil, fmt.Errorf("user '%s' has no password", name)
+	}
+
+	database, entriesPath := user.paths(name)
+	store := openStore(database, entriesPath)
+	if pg, ok := store.(*postgresStore); ok {
+		pg.owner = name
+	}
+	sites, err := store.LoadSites()
+	if err != nil {
+		return nil, fmt.Errorf("user '%s': %w", name, err)
+	}
+	entries, err := store.LoadEntries()
+	if err != nil {
+		return nil, fmt.Errorf("user '%s': %w", name, err)
+	}
+
+	// Every user logs in to the Fever, Google Reader and site APIs with their own credentials.
+	config.Fever = &FeverConfig{Email: name, Password: user.Password}
+	config.GReader = &GReaderConfig{Username: name, Password: user.Password}
+	config.ServeAuth = &ServeAuthConfig{Username: name, Password: user.Password, Tokens: user.Tokens}
+	config.APIToken = ""
+
+	server := &Server{
+		sites:    newSiteRepo(sites),
+		config:   config,
+		entries:  entries,
+		store:    store,
+		database: database,
+	}
+	// Checks run against a user's database are handed to this serve, as
+	// they are with a single user.
+	if err := server.listenDaemon(database); err != nil {
+		return nil, fmt.Errorf("user '%s': %w", name, err)
+	}
+	if _, ok := store.(*postgresStore); ok {
+		server.database = "postgres"
+	}
+	return server, nil
+}
+
+func newMultiUserHandler(config Config, interval time.Duration) (*multiUserHandler, error) {
+	// A token from the identity provider can't be matched to a user.
+	if config.ServeAuth != nil && config.ServeAuth.OIDC != nil {
+		return nil, fmt.Errorf("serve_auth.oidc can't be used with users; users log in with their own passwords and tokens")
+	}
+
+	h := &multiUserHandler{
+		servers: make(map[string]*Server),
+		routes:  make(map[string]http.Handler),
+	}
+
+	for name, user := range config.Users {
+		server, err := newUserServer(name, user, config)
+		if err != nil {
+			return nil, err
+		}
+		h.names = append(h.names, name)
+		h.servers[name] = server
+		h.routes[name] = server.routes()
+	}
+	sort.Strings(h.names)
+
+	for _, name := range h.names {
+		fmt.Printf("User '%s' → %d sites (%s)\n", name, h.servers[name].sites.Len(), h.servers[name].database)
+		if interval > 0 {
+			go h.servers[name].checkLoop(interval)
+		}
+	}
+
+	return h, nil
+}
+
+func (h *multiUserHandler) identify(r *http.Request) string {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/fever/"):
+		if err := r.ParseForm(); err != nil {
+			return ""
+		}
+		for _, name := range h.names {
+			if h.servers[name].feverAuthorized(r.Form.Get("api_key")) {
+				return name
+			}
+		}
+
+	case r.URL.Path == "/accounts/ClientLogin":
+		if err := r.ParseForm(); err != nil {
+			return ""
+		}
+		if _, ok := h.servers[r.Form.Get("Email")]; ok {
+			return r.Form.Get("Email")
+		}
+
+	case strings.HasPrefix(r.URL.Path, "/reader/"):
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "GoogleLogin auth=")
+		for _, name := range h.names {
+			if secureEqual(token, h.servers[name].config.GReader.authToken()) {
+				return name
+			}
+		}
+
+	default:
+		if username, _, ok := r.BasicAuth(); ok {
+			if _, exists := h.servers[username]; exists {
+				return username
+			}
+			return ""
+		}
+		for _, name := range h.names {
+			if h.servers[name].authenticate(r) {
+				return name
+			}
+		}
+	}
+
+	return ""
+}
+
+func (h *multiUserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := h.identify(r)
+	if name == "" {
+		if strings.HasPrefix(r.URL.Path, "/fever/") {
+			writeJSON(w, http.StatusOK, map[string]int{"api_version": FEVER_API_VERSION, "auth": 0})
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="RSS Tracker"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing credentials"})
+		return
+	}
+
+	h.routes[name].ServeHTTP(w, r)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const (
+	SITE_TYPE_FEED  = ""
+	SITE_TYPE_WATCH = "watch"
+	WATCH_PREFIX    = "watch:"
+)
+
+var htmlNoisePattern = regexp.MustCompile(`(?is)<script\b.*?</script\s*>|<style\b.*?</style\s*>|<!--.*?-->`)
+
+type htmlSelector struct {
+	Tag   string
+	ID    string
+	Class string
+}
+
+func parseSelector(selector string) htmlSelector {
+	var sel htmlSelector
+	selector = strings.TrimSpace(selector)
+
+	if tag, id, ok := strings.Cut(selector, "#"); ok {
+		sel.Tag, sel.ID = tag, id
+	} else if tag, class, ok := strings.Cut(selector, "."); ok {
+		sel.Tag, sel.Class = tag, class
+	} else {
+		sel.Tag = selector
+	}
+
+	sel.Tag = strings.ToLower(sel.Tag)
+	return sel
+}
+
+func (s htmlSelector) matches(start xml.StartElement) bool {
+	if s.Tag != "" && strings.ToLower(start.Name.Local) != s.Tag {
+		return false
+	}
+
+	for _, attr := range start.Attr {
+		switch strings.ToLower(attr.Name.Local) {
+		case "id":
+			if s.ID != "" && attr.Value == s.ID {
+				return true
+			}
+		case "class":
+			if s.Class != "" {
+				for _, class := range strings.Fields(attr.Value) {
+					if class == s.Class {
+						return true
+					}
+				}
+			}
+		}
+	}
+
+	return s.ID == "" && s.Class == ""
+}
+
+func newHTMLDecoder(body []byte) *xml.Decoder {
+	body = htmlNoisePattern.ReplaceAll(body, nil)
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	return decoder
+}
+
+func extractPageText(body []byte, selector string) (title string, text string, found bool) {
+	sel := parseSelector(selector)
+	if selector == "" {
+		sel = htmlSelector{Tag: "body"}
+	}
+
+	decoder := newHTMLDecoder(body)
+	var b strings.Builder
+	depth := 0
+	inTitle := false
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err != io.EOF && !found {
+				return title, "", false
+			}
+			break
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if name == "title" && title == "" {
+				inTitle = true
+			}
+			if depth > 0 {
+				depth++
+			} else if !found && sel.matches(t) {
+				found = true
+				depth = 1
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			if name == "title" {
+				inTitle = false
+			}
+			if depth > 0 {
+				depth--
+			}
+		case xml.CharData:
+			if inTitle {
+				title += string(t)
+			}
+			if depth > 0 {
+				b.WriteString(string(t))
+				b.WriteByte(' ')
+			}
+		}
+	}
+
+	return strings.TrimSpace(title), strings.Join(strings.Fields(b.String()), " "), found
+}
+
+func htmlToText(fragment string) string {
+	if !strings.Contains(fragment, "<") && !strings.Contains(fragment, "&") {
+		return strings.Join(strings.Fields(fragment), " ")
+	}
+
+	decoder := newHTMLDecoder([]byte("<div>" + fragment + "</div>"))
+	var b strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		if data, ok := token.(xml.CharData); ok {
+			b.Write(data)
+			b.WriteByte(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func parseWatchedPage(pageURL, selector string, body []byte) (*FeedResult, error) {
+	title, text, found := extractPageText(body, selector)
+	if !found {
+		if selector != "" {
+			return nil, fmt.Errorf("selector '%s' not found on page", selector)
+		}
+		text = string(body)
+	}
+
+	sum := sha256.Sum256([]byte(text))
+	hash := hex.EncodeToString(sum[:])
+
+	if title == "" {
+		title = pageURL
+	}
+	entryTitle := fmt.Sprintf("%s changed", title)
+	link := fmt.Sprintf("%s#sha256=%s", strings.SplitN(pageURL, "#", 2)[0], hash[:16])
+
+	return &FeedResult{
+		Title:      entryTitle,
+		LatestLink: link,
+		FeedType:   FeedTypeWatch,
+		FeedTitle:  title,
+		SiteURL:    pageURL,
+		Entries: []FeedEntry{{
+			ID:    hash,
+			Title: entryTitle,
+			Link:  link,
+		}},
+	}, nil
+}
+
+func parseWatchSpec(spec string) Site {
+	fields := strings.Fields(strings.TrimPrefix(spec, WATCH_PREFIX))
+	site := Site{Type: SITE_TYPE_WATCH}
+	if len(fields) > 0 {
+		site.RSSUrl = fields[0]
+	}
+	if len(fields) > 1 {
+		site.Selector = strings.Join(fields[1:], " ")
+	}
+	return site
+}
+
+// parseSiteBody never panics: a parser bug triggered by one malformed feed
+// is reported as that site's parse error instead of aborting the check.
+func parseSiteBody(site Site, feedURL string, body []byte) (result *FeedResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, fmt.Errorf("parser panic: %v", r)
+		}
+	}()
+
+	switch site.Type {
+	case SITE_TYPE_WATCH:
+		return parseWatchedPage(feedURL, site.Selector, body)
+	case SITE_TYPE_SITEMAP:
+		return parseSitemap(body)
+	case SITE_TYPE_FEED:
+		if isGeminiSource(feedURL) && detectFeedType(body) == FeedTypeUnknown {
+			return parseGemfeed(feedURL, body)
+		}
+		return parseSiteFeed(site, body)
+	default:
+		return nil, fmt.Errorf("unknown site type '%s'", site.Type)
+	}
+}